@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/watcher"
+)
+
+// exitUpdatesAvailable is the exit code of `check --exit-code` when updates are available
+const exitUpdatesAvailable = 3
+
+// checkRow is one container in the check report
+type checkRow struct {
+	Namespace     string `json:"namespace"`
+	Kind          string `json:"kind"`
+	Workload      string `json:"workload"`
+	Container     string `json:"container"`
+	Image         string `json:"image"`
+	CurrentDigest string `json:"currentDigest,omitempty"`
+	RemoteDigest  string `json:"remoteDigest,omitempty"`
+	Status        string `json:"status"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// runCheck implements `kube-watchtower check`
+func runCheck(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	kubeContext := fs.String("context", "", "kubeconfig context of the cluster")
+	output := fs.String("output", "table", "output format: table or json")
+	all := fs.Bool("all", false, "list all checked containers, not only available updates and failures")
+	notify := fs.Bool("notify", false, "send the summary to NOTIFICATION_URL and the namespace and workload notification URLs")
+	exitCode := fs.Bool("exit-code", false, fmt.Sprintf("exit with %d when updates are available", exitUpdatesAvailable))
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kube-watchtower check [flags]")
+		fmt.Fprintln(fs.Output(), "\nChecks all monitored workloads once without updating anything and reports the available updates.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 || (*output != "table" && *output != "json") {
+		fs.Usage()
+		return 2
+	}
+
+	if *kubeContext != "" {
+		cfg = cfg.ForContext(*kubeContext)
+	}
+	cfg = monitorOnlyConfig(cfg)
+	if !*notify {
+		cfg.NotificationURL = ""
+		cfg.NotificationURLSecret = ""
+		cfg.SkipRoutedNotifications = true
+	}
+	if err := cfg.Validate(); err != nil {
+		logger.Errorf("Invalid configuration: %v", err)
+		return 1
+	}
+
+	w, err := watcher.NewWatcher(cfg)
+	if err != nil {
+		logger.Errorf("Failed to create watcher: %v", err)
+		return 1
+	}
+	defer w.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := w.CheckOnce(ctx); err != nil {
+		logger.Errorf("Check failed: %v", err)
+		return 1
+	}
+
+	rows := make([]checkRow, 0)
+	available := 0
+	for _, workload := range w.Workloads() {
+		for _, c := range workload.Containers {
+			if c.Status == watcher.ContainerPending {
+				available++
+			}
+			if !*all && c.Status != watcher.ContainerPending && c.Status != watcher.ContainerFailed {
+				continue
+			}
+			rows = append(rows, checkRow{
+				Namespace:     workload.Namespace,
+				Kind:          workload.Kind,
+				Workload:      workload.Name,
+				Container:     c.Name,
+				Image:         c.Image,
+				CurrentDigest: c.CurrentDigest,
+				RemoteDigest:  c.RemoteDigest,
+				Status:        c.Status,
+				Reason:        c.Reason,
+			})
+		}
+	}
+
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rows)
+	} else {
+		printCheckTable(rows)
+	}
+
+	if *exitCode && available > 0 {
+		return exitUpdatesAvailable
+	}
+	return 0
+}
+
+// monitorOnlyConfig returns a copy of the configuration that never changes the cluster:
+// dry-run, without state and status ConfigMaps and without the API server
+func monitorOnlyConfig(cfg *config.Config) *config.Config {
+	c := *cfg
+	c.DryRun = true
+	c.StateConfigMap = ""
+	c.StatusConfigMap = ""
+	c.CheckInterval = 0
+	c.APIAddr = ""
+	return &c
+}
+
+// printCheckTable prints the check report as a table
+func printCheckTable(rows []checkRow) {
+	if len(rows) == 0 {
+		fmt.Println("All images are up to date")
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tKIND\tWORKLOAD\tCONTAINER\tIMAGE\tCURRENT\tREMOTE\tSTATUS")
+	for _, row := range rows {
+		status := row.Status
+		if row.Reason != "" && row.Reason != "dry-run" {
+			status = fmt.Sprintf("%s (%s)", row.Status, row.Reason)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", row.Namespace, row.Kind, row.Workload, row.Container, row.Image,
+			shortDigest(row.CurrentDigest), shortDigest(row.RemoteDigest), status)
+	}
+	tw.Flush()
+}
+
+// shortDigest shortens a sha256 digest to 12 hex characters, "-" if empty
+func shortDigest(digest string) string {
+	if digest == "" {
+		return "-"
+	}
+	if len(digest) > 19 {
+		return digest[7:19]
+	}
+	return digest
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/state"
+	"github.com/qetesh/kube-watchtower/pkg/watcher"
+)
+
+// historyEntry is a history record with its ID, as printed by `history --output json`
+type historyEntry struct {
+	ID string `json:"id"`
+	state.UpdateRecord
+}
+
+// runHistory implements `kube-watchtower history [<namespace>[/<workload>]]`
+func runHistory(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	kubeContext := fs.String("context", "", "kubeconfig context of the cluster")
+	output := fs.String("output", "table", "output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kube-watchtower history [flags] [<namespace>[/<workload>]]")
+		fmt.Fprintln(fs.Output(), "\nLists the recorded updates and rollbacks, oldest first. Revert one with `rollback --record <id>`.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 || (*output != "table" && *output != "json") {
+		fs.Usage()
+		return 2
+	}
+
+	namespace, name := "", ""
+	if fs.NArg() == 1 {
+		var ok bool
+		if namespace, name, _, ok = parseWorkloadRef(fs.Arg(0)); !ok {
+			namespace = fs.Arg(0)
+		}
+	}
+
+	if *kubeContext != "" {
+		cfg = cfg.ForContext(*kubeContext)
+	}
+
+	w, err := watcher.NewWatcher(cfg)
+	if err != nil {
+		logger.Errorf("Failed to create watcher: %v", err)
+		return 1
+	}
+	defer w.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	records, err := w.History(ctx, namespace, name)
+	if err != nil {
+		logger.Errorf("Failed to read update history: %v", err)
+		return 1
+	}
+
+	if *output == "json" {
+		entries := make([]historyEntry, len(records))
+		for i, record := range records {
+			entries[i] = historyEntry{ID: record.ID(), UpdateRecord: record}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(entries)
+		return 0
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No recorded updates")
+		return 0
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIME\tNAMESPACE\tKIND\tWORKLOAD\tCONTAINER\tOLD\tNEW\tRESULT")
+	for _, record := range records {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", record.ID(), record.Time.Local().Format(time.DateTime),
+			record.Namespace, record.Kind, record.Name, record.Container,
+			shortDigest(record.OldDigest), shortDigest(record.NewDigest), historyResult(record))
+	}
+	tw.Flush()
+	return 0
+}
+
+// historyResult describes the outcome of a record
+func historyResult(record state.UpdateRecord) string {
+	result := "updated"
+	if record.Rollback {
+		result = "rolled back"
+	}
+	if !record.Success {
+		result = fmt.Sprintf("failed: %s", record.Error)
+	}
+	if len(record.Snapshots) > 0 {
+		result += fmt.Sprintf(" (snapshots: %s)", strings.Join(record.Snapshots, ", "))
+	}
+	if record.HelmRelease != "" {
+		result += fmt.Sprintf(" (Helm release %s)", record.HelmRelease)
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/watcher"
+)
+
+// runList implements `kube-watchtower list [<namespace>]`
+func runList(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	kubeContext := fs.String("context", "", "kubeconfig context of the cluster")
+	output := fs.String("output", "table", "output format: table or json")
+	excluded := fs.Bool("excluded", false, "only list excluded workloads and containers")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kube-watchtower list [flags] [<namespace>]")
+		fmt.Fprintln(fs.Output(), "\nLists the workloads and containers in scope of the checks, and why the others are excluded.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 || (*output != "table" && *output != "json") {
+		fs.Usage()
+		return 2
+	}
+	namespace := fs.Arg(0)
+
+	if *kubeContext != "" {
+		cfg = cfg.ForContext(*kubeContext)
+	}
+
+	w, err := watcher.NewWatcher(cfg)
+	if err != nil {
+		logger.Errorf("Failed to create watcher: %v", err)
+		return 1
+	}
+	defer w.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scopes, err := w.Scope(ctx)
+	if err != nil {
+		logger.Errorf("Failed to list workloads: %v", err)
+		return 1
+	}
+
+	filtered := make([]watcher.WorkloadScope, 0, len(scopes))
+	for _, scope := range scopes {
+		if namespace != "" && scope.Namespace != namespace {
+			continue
+		}
+		if *excluded && scope.Excluded == "" {
+			containers := make([]watcher.ContainerScope, 0)
+			for _, c := range scope.Containers {
+				if c.Excluded != "" {
+					containers = append(containers, c)
+				}
+			}
+			if len(containers) == 0 {
+				continue
+			}
+			scope.Containers = containers
+		}
+		filtered = append(filtered, scope)
+	}
+
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(filtered)
+		return 0
+	}
+
+	if len(filtered) == 0 {
+		fmt.Println("No workloads found")
+		return 0
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tKIND\tWORKLOAD\tCONTAINER\tIMAGE\tMONITORED\tREASON")
+	for _, scope := range filtered {
+		for _, c := range scope.Containers {
+			reason := scope.Excluded
+			if reason == "" {
+				reason = c.Excluded
+			}
+			monitored := "yes"
+			if reason != "" {
+				monitored = "no"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", scope.Namespace, scope.Kind, scope.Name, c.Name, c.Image, monitored, reason)
+		}
+	}
+	tw.Flush()
+	return 0
+}
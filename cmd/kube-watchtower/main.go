@@ -2,44 +2,124 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/qetesh/kube-watchtower/pkg/api"
 	"github.com/qetesh/kube-watchtower/pkg/config"
 	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/metrics"
 	"github.com/qetesh/kube-watchtower/pkg/watcher"
 )
 
-var version = "dev"
-
 func main() {
+	// Print the version before loading the configuration, so it works anywhere
+	if len(os.Args) > 1 && (os.Args[1] == "version" || os.Args[1] == "--version") {
+		os.Exit(runVersion(os.Args[2:]))
+	}
+
 	// Load configuration
 	cfg := config.LoadConfig()
+	cfg.UserAgent = fmt.Sprintf("kube-watchtower/%s", version)
+	cfg.Version = version
 
 	// Initialize logger
 	if err := logger.Init(cfg.LogLevel); err != nil {
 		panic("Failed to initialize logger: " + err.Error())
 	}
 	defer logger.Sync()
+	if cfg.SyslogTarget != "" {
+		if err := logger.EnableSyslog(cfg.SyslogTarget, "kube-watchtower", cfg.SyslogTLSCA); err != nil {
+			logger.Fatalf("Failed to enable syslog: %v", err)
+		}
+	}
 
+	// Dispatch subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "rollback":
+			os.Exit(runRollback(cfg, os.Args[2:]))
+		case "history":
+			os.Exit(runHistory(cfg, os.Args[2:]))
+		case "unpin":
+			os.Exit(runUnpin(cfg, os.Args[2:]))
+		case "update":
+			os.Exit(runUpdate(cfg, os.Args[2:]))
+		case "list":
+			os.Exit(runList(cfg, os.Args[2:]))
+		case "check":
+			os.Exit(runCheck(cfg, os.Args[2:]))
+		case "validate-config":
+			os.Exit(runValidateConfig(cfg, os.Args[2:]))
+		case "notify-test":
+			os.Exit(runNotifyTest(cfg, os.Args[2:]))
+		default:
+			logger.Fatalf("Unknown command: %s", os.Args[1])
+		}
+	}
+
+	run(cfg)
+}
+
+// run runs the watchers until the check (or, with CHECK_INTERVAL, a signal) completes
+func run(cfg *config.Config) {
 	// Print version
-	logger.Infof("kube-watchtower %s", version)
+	logger.Infof("kube-watchtower %s", getBuildInfo())
 
 	// Debug configuration
 	logger.Infof("Configuration loaded: DisableNamespaces=%v",
 		cfg.DisableNamespaces)
 
-	// Create watcher
-	w, err := watcher.NewWatcher(cfg)
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		logger.Fatalf("Invalid configuration: %v", err)
+	}
+	for _, problem := range cfg.EnvProblems() {
+		logger.Warnf("Configuration: %v", problem)
+	}
+
+	// Create watchers (one per cluster)
+	watchers, err := newWatchers(cfg)
 	if err != nil {
 		logger.Fatalf("Failed to create watcher: %v", err)
 	}
-	defer w.Close()
+	defer func() {
+		for _, w := range watchers {
+			w.Close()
+		}
+	}()
+
+	// Start API server
+	metrics.SetLabelLimit(cfg.MetricsLabelLimit)
+	if cfg.APIAddr != "" {
+		server, err := api.NewServer(cfg, watchers)
+		if err != nil {
+			logger.Fatalf("Failed to create API server: %v", err)
+		}
+		server.Start()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				logger.Warnf("Failed to shut down API server: %v", err)
+			}
+		}()
+	}
 
 	// Setup signal handling
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Announce the start
+	if cfg.NotificationStartup {
+		for _, w := range watchers {
+			w.NotifyStartup(ctx, version)
+		}
+	}
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
@@ -56,8 +136,8 @@ func main() {
 		close(done)
 	}()
 
-	// Run watcher
-	if err := w.Run(ctx); err != nil && err != context.Canceled {
+	// Run watchers
+	if err := runWatchers(ctx, watchers); err != nil && err != context.Canceled {
 		cancel()
 		signal.Stop(sigCh)
 		logger.Fatalf("Watcher failed: %v", err)
@@ -70,3 +150,49 @@ func main() {
 
 	logger.Info("kube-watchtower stopped")
 }
+
+// newWatchers creates one watcher per configured kubeconfig context,
+// or a single watcher for the default cluster
+func newWatchers(cfg *config.Config) ([]*watcher.Watcher, error) {
+	if len(cfg.KubeContexts) == 0 {
+		w, err := watcher.NewWatcher(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return []*watcher.Watcher{w}, nil
+	}
+
+	watchers := make([]*watcher.Watcher, 0, len(cfg.KubeContexts))
+	for _, kubeContext := range cfg.KubeContexts {
+		logger.Infof("Watching cluster: %s", kubeContext)
+		w, err := watcher.NewWatcher(cfg.ForContext(kubeContext))
+		if err != nil {
+			for _, created := range watchers {
+				created.Close()
+			}
+			return nil, fmt.Errorf("cluster %s: %w", kubeContext, err)
+		}
+		watchers = append(watchers, w)
+	}
+	return watchers, nil
+}
+
+// runWatchers runs all watchers concurrently and returns the first error
+func runWatchers(ctx context.Context, watchers []*watcher.Watcher) error {
+	errCh := make(chan error, len(watchers))
+
+	var wg sync.WaitGroup
+	for _, w := range watchers {
+		wg.Add(1)
+		go func(w *watcher.Watcher) {
+			defer wg.Done()
+			if err := w.Run(ctx); err != nil {
+				errCh <- err
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errCh)
+
+	return <-errCh
+}
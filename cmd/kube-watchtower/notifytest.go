@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/watcher"
+)
+
+// runNotifyTest implements `kube-watchtower notify-test`
+func runNotifyTest(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("notify-test", flag.ExitOnError)
+	kubeContext := fs.String("context", "", "kubeconfig context of the cluster")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kube-watchtower notify-test [flags]")
+		fmt.Fprintln(fs.Output(), "\nSends a test notification to NOTIFICATION_URL.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if *kubeContext != "" {
+		cfg = cfg.ForContext(*kubeContext)
+	}
+
+	w, err := watcher.NewWatcher(cfg)
+	if err != nil {
+		logger.Errorf("Failed to create watcher: %v", err)
+		return 1
+	}
+	defer w.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := w.NotifyTest(ctx, version); err != nil {
+		logger.Errorf("Test notification failed: %v", err)
+		return 1
+	}
+
+	fmt.Println("Test notification sent")
+	return 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/watcher"
+)
+
+// runRollback implements `kube-watchtower rollback <namespace>/<kind>/<name>[/container]` and `rollback --record <id>`
+func runRollback(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	kubeContext := fs.String("context", "", "kubeconfig context of the cluster")
+	recordID := fs.String("record", "", "revert the recorded update with this ID (see the history command) instead of the last one")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kube-watchtower rollback [flags] <namespace>/<kind>/<name>[/container]")
+		fmt.Fprintln(fs.Output(), "       kube-watchtower rollback [flags] --record <id>")
+		fmt.Fprintln(fs.Output(), "\nReverts the last (or the given) recorded update of a workload and waits for the rollout.")
+		fmt.Fprintln(fs.Output(), "<kind> is deployment, daemonset, statefulset or cronjob. Without a container, every container of the last update is reverted.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	var namespace, kind, name, container string
+	if *recordID != "" {
+		if fs.NArg() != 0 {
+			fs.Usage()
+			return 2
+		}
+	} else {
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return 2
+		}
+		parts := strings.Split(fs.Arg(0), "/")
+		if len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			fs.Usage()
+			return 2
+		}
+		namespace, kind, name = parts[0], parts[1], parts[2]
+		if len(parts) == 4 {
+			container = parts[3]
+		}
+	}
+
+	if *kubeContext != "" {
+		cfg = cfg.ForContext(*kubeContext)
+	}
+
+	w, err := watcher.NewWatcher(cfg)
+	if err != nil {
+		logger.Errorf("Failed to create watcher: %v", err)
+		return 1
+	}
+	defer w.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var results []watcher.RollbackResult
+	if *recordID != "" {
+		results, err = w.RollbackRecord(ctx, *recordID)
+	} else {
+		results, err = w.Rollback(ctx, namespace, kind, name, container)
+	}
+	if err != nil {
+		logger.Errorf("Rollback failed: %v", err)
+		return 1
+	}
+
+	for _, result := range results {
+		fmt.Printf("Rolled back %s/%s/%s (%s): %s -> %s\n", result.Namespace, result.Name, result.Container, result.Kind, result.FromImage, result.ToImage)
+	}
+	return 0
+}
+
+// parseWorkloadRef parses a <namespace>/<workload>[/container] argument
+func parseWorkloadRef(ref string) (namespace, name, container string, ok bool) {
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", false
+	}
+	if len(parts) == 3 {
+		container = parts[2]
+	}
+	return parts[0], parts[1], container, true
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/watcher"
+)
+
+// runUnpin implements `kube-watchtower unpin <namespace>/<kind>/<name>[/container]`
+func runUnpin(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("unpin", flag.ExitOnError)
+	kubeContext := fs.String("context", "", "kubeconfig context of the cluster")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kube-watchtower unpin [flags] <namespace>/<kind>/<name>[/container]")
+		fmt.Fprintln(fs.Output(), "\nRestores the repo:tag images of a workload pinned by digest and waits for the rollout.")
+		fmt.Fprintln(fs.Output(), "<kind> is deployment, daemonset, statefulset or cronjob. Without a container, every pinned container is restored.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	parts := strings.Split(fs.Arg(0), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		fs.Usage()
+		return 2
+	}
+	namespace, kind, name := parts[0], parts[1], parts[2]
+	container := ""
+	if len(parts) == 4 {
+		container = parts[3]
+	}
+
+	if *kubeContext != "" {
+		cfg = cfg.ForContext(*kubeContext)
+	}
+
+	w, err := watcher.NewWatcher(cfg)
+	if err != nil {
+		logger.Errorf("Failed to create watcher: %v", err)
+		return 1
+	}
+	defer w.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := w.Unpin(ctx, namespace, kind, name, container)
+	if err != nil {
+		logger.Errorf("Unpin failed: %v", err)
+		return 1
+	}
+
+	if len(result.Images) == 0 {
+		fmt.Printf("%s/%s (%s) has no digest pins\n", result.Namespace, result.Name, result.Kind)
+		return 0
+	}
+	for container, image := range result.Images {
+		fmt.Printf("Unpinned %s/%s/%s (%s): %s\n", result.Namespace, result.Name, container, result.Kind, image)
+	}
+	return 0
+}
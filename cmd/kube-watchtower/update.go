@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/watcher"
+)
+
+// runUpdate implements `kube-watchtower update <namespace>/<kind>/<name>`
+func runUpdate(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	kubeContext := fs.String("context", "", "kubeconfig context of the cluster")
+	container := fs.String("container", "", "only check and update this container")
+	wait := fs.Bool("wait", false, "wait for the rollout, then verify the digest and run smoke tests, hooks and cleanup")
+	dryRun := fs.Bool("dry-run", false, "only report the update")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kube-watchtower update [flags] <namespace>/<kind>/<name>")
+		fmt.Fprintln(fs.Output(), "\nChecks a workload now and updates it if a newer image is available.")
+		fmt.Fprintln(fs.Output(), "<kind> is deployment, daemonset, statefulset or cronjob.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	parts := strings.Split(fs.Arg(0), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		fs.Usage()
+		return 2
+	}
+	namespace, kind, name := parts[0], parts[1], parts[2]
+
+	if *kubeContext != "" {
+		cfg = cfg.ForContext(*kubeContext)
+	}
+
+	w, err := watcher.NewWatcher(cfg)
+	if err != nil {
+		logger.Errorf("Failed to create watcher: %v", err)
+		return 1
+	}
+	defer w.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := watcher.UpdateOptions{DryRun: *dryRun, Container: *container, NoWait: !*wait}
+	result, err := w.UpdateWorkload(ctx, namespace, kind, name, opts)
+	if err != nil {
+		logger.Errorf("Update failed: %v", err)
+		return 1
+	}
+
+	code := 0
+	for _, c := range result.Containers {
+		switch {
+		case c.Status == watcher.ContainerUpdated && !*wait:
+			fmt.Printf("%s: rollout started (%s)\n", c.Name, c.Image)
+		case c.Reason != "":
+			fmt.Printf("%s: %s (%s)\n", c.Name, c.Status, c.Reason)
+		default:
+			fmt.Printf("%s: %s\n", c.Name, c.Status)
+		}
+		if c.Status == watcher.ContainerFailed {
+			code = 1
+		}
+	}
+	if len(result.Containers) == 0 {
+		fmt.Println("No containers checked (disabled or filtered)")
+	}
+	return code
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/notifier"
+)
+
+// runValidateConfig implements `kube-watchtower validate-config`
+func runValidateConfig(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	namespaceConfig := fs.String("namespace-config", "", "also validate a namespace config, as KEY=VALUE lines (e.g. the data of its ConfigMap)")
+	quiet := fs.Bool("quiet", false, "only print problems")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kube-watchtower validate-config [flags]")
+		fmt.Fprintln(fs.Output(), "\nValidates the configuration from the environment, prints the effective configuration with")
+		fmt.Fprintln(fs.Output(), "secrets masked and exits with 1 if there are problems. Does not contact the cluster.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	problems := append(cfg.EnvProblems(), cfg.Problems()...)
+	if cfg.NotificationURL != "" {
+		if err := notifier.ValidateURL(cfg.NotificationURL); err != nil {
+			problems = append(problems, fmt.Errorf("invalid NOTIFICATION_URL: %w", err))
+		}
+	}
+	for name, path := range map[string]string{
+		"REGISTRY_CREDENTIALS_FILE": cfg.RegistryCredentialsFile,
+		"API_TLS_CERT":              cfg.APITLSCert,
+		"API_TLS_KEY":               cfg.APITLSKey,
+		"API_TLS_CLIENT_CA":         cfg.APITLSClientCA,
+		"SYSLOG_TLS_CA":             cfg.SyslogTLSCA,
+	} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, fmt.Errorf("invalid %s: %w", name, err))
+		}
+	}
+	for host, path := range cfg.RegistryRobots {
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, fmt.Errorf("invalid REGISTRY_ROBOTS entry for %s: %w", host, err))
+		}
+	}
+	for host, path := range cfg.RegistryArtifactory {
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, fmt.Errorf("invalid REGISTRY_ARTIFACTORY entry for %s: %w", host, err))
+		}
+	}
+	for _, plugin := range cfg.Plugins {
+		if _, err := exec.LookPath(plugin); err != nil {
+			problems = append(problems, fmt.Errorf("invalid PLUGINS: %w", err))
+		}
+	}
+	if (cfg.APITLSCert == "") != (cfg.APITLSKey == "") {
+		problems = append(problems, fmt.Errorf("API_TLS_CERT and API_TLS_KEY must be set together"))
+	}
+	if *namespaceConfig != "" {
+		if err := validateNamespaceConfig(*namespaceConfig); err != nil {
+			problems = append(problems, fmt.Errorf("namespace config %s: %w", *namespaceConfig, err))
+		}
+	}
+
+	if !*quiet {
+		printConfig(cfg.Masked())
+	}
+
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d problem(s) found:\n", len(problems))
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "- %v\n", problem)
+		}
+		return 1
+	}
+	if !*quiet {
+		fmt.Println("\nConfiguration is valid")
+	}
+	return 0
+}
+
+// validateNamespaceConfig parses a namespace config file of KEY=VALUE lines
+func validateNamespaceConfig(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return fmt.Errorf("expected KEY=VALUE, got %q", line)
+		}
+		data[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	_, err = config.ParseNamespaceConfig(data)
+	return err
+}
+
+// printConfig prints the exported fields of the configuration
+func printConfig(cfg *config.Config) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	v := reflect.ValueOf(*cfg)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%v\n", field.Name, v.Field(i).Interface())
+	}
+	tw.Flush()
+}
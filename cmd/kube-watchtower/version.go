@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set with -ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// buildInfo describes the binary
+type buildInfo struct {
+	Version             string `json:"version"`
+	Commit              string `json:"commit,omitempty"`
+	Date                string `json:"date,omitempty"`
+	GoVersion           string `json:"goVersion"`
+	Platform            string `json:"platform"`
+	ClientGo            string `json:"clientGo,omitempty"`
+	GoContainerRegistry string `json:"goContainerRegistry,omitempty"`
+}
+
+// getBuildInfo collects the build metadata, falling back to the VCS stamp of the Go toolchain
+func getBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range bi.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.Date == "":
+			info.Date = setting.Value
+		}
+	}
+	for _, dep := range bi.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		switch dep.Path {
+		case "k8s.io/client-go":
+			info.ClientGo = dep.Version
+		case "github.com/google/go-containerregistry":
+			info.GoContainerRegistry = dep.Version
+		}
+	}
+	return info
+}
+
+// String formats the build metadata as one line
+func (b buildInfo) String() string {
+	s := b.Version
+	if b.Commit != "" {
+		s += fmt.Sprintf(" (commit %.12s", b.Commit)
+		if b.Date != "" {
+			s += ", built " + b.Date
+		}
+		s += ")"
+	}
+	return s
+}
+
+// runVersion implements `kube-watchtower version`
+func runVersion(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	output := fs.String("output", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kube-watchtower version [flags]")
+		fmt.Fprintln(fs.Output(), "\nPrints the version and build metadata.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 || (*output != "text" && *output != "json") {
+		fs.Usage()
+		return 2
+	}
+
+	info := getBuildInfo()
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(info)
+		return 0
+	}
+
+	fmt.Printf("kube-watchtower %s\n", info.Version)
+	fmt.Printf("  Commit:                %s\n", orUnknown(info.Commit))
+	fmt.Printf("  Built:                 %s\n", orUnknown(info.Date))
+	fmt.Printf("  Go:                    %s %s\n", info.GoVersion, info.Platform)
+	fmt.Printf("  client-go:             %s\n", orUnknown(info.ClientGo))
+	fmt.Printf("  go-containerregistry:  %s\n", orUnknown(info.GoContainerRegistry))
+	return 0
+}
+
+// orUnknown returns the value or "unknown" if empty
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
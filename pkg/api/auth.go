@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// Access scopes of the API, checked as verbs by Kubernetes authorization
+const (
+	scopeRead  = "get"
+	scopeWrite = "update"
+)
+
+// reviewCacheTTL is how long Kubernetes review results are reused
+const reviewCacheTTL = time.Minute
+
+// authenticator checks the bearer token of API requests
+type authenticator struct {
+	token     string      // Grants all scopes
+	readToken string      // Grants the read scope
+	client    *k8s.Client // Reviews other tokens, nil if Kubernetes authentication is disabled
+
+	mu    sync.Mutex
+	cache map[reviewKey]reviewResult
+}
+
+type reviewKey struct {
+	token     [sha256.Size]byte
+	scope     string
+	namespace string
+}
+
+type reviewResult struct {
+	allowed bool
+	expires time.Time
+}
+
+// newAuthenticator creates the authenticator of the API configuration, nil if no authentication is configured
+func newAuthenticator(cfg *config.Config) (*authenticator, error) {
+	if cfg.APIToken == "" && cfg.APIReadToken == "" && !cfg.APIAuthKubernetes {
+		logger.Warnf("API server has no authentication, set API_TOKEN or API_AUTH_KUBERNETES")
+		return nil, nil
+	}
+
+	a := &authenticator{
+		token:     cfg.APIToken,
+		readToken: cfg.APIReadToken,
+		cache:     make(map[reviewKey]reviewResult),
+	}
+	if cfg.APIAuthKubernetes {
+		client, err := k8s.NewClientWithOptions("", k8s.ClientOptions{UserAgent: cfg.UserAgent})
+		if err != nil {
+			return nil, err
+		}
+		a.client = client
+	}
+	return a, nil
+}
+
+// protect requires a token with the scope, in the namespace of the request path if it has one
+func (s *Server) protect(scope string, next http.Handler) http.Handler {
+	if s.auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			writeError(rw, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		if !s.auth.allowed(r.Context(), token, scope, r.PathValue("namespace")) {
+			writeError(rw, http.StatusForbidden, "forbidden")
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// allowed checks the static tokens, then asks Kubernetes
+func (a *authenticator) allowed(ctx context.Context, token, scope, namespace string) bool {
+	if a.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1 {
+		return true
+	}
+	if a.readToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.readToken)) == 1 {
+		return scope == scopeRead
+	}
+	if a.client == nil {
+		return false
+	}
+
+	key := reviewKey{token: sha256.Sum256([]byte(token)), scope: scope, namespace: namespace}
+	now := time.Now()
+	a.mu.Lock()
+	cached, ok := a.cache[key]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.allowed
+	}
+
+	user, allowed, err := a.client.AuthorizeToken(ctx, token, scope, namespace)
+	if err != nil {
+		logger.Warnf("Failed to authorize API request: %v", err)
+		return false
+	}
+	if !allowed {
+		logger.Infof("Denied API access (%s in namespace %q) to user %q", scope, namespace, user)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for k, result := range a.cache {
+		if now.After(result.expires) {
+			delete(a.cache, k)
+		}
+	}
+	a.cache[key] = reviewResult{allowed: allowed, expires: now.Add(reviewCacheTTL)}
+	return allowed
+}
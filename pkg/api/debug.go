@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerPprof serves the net/http/pprof profiles on /debug/pprof/, with the write scope
+func (s *Server) registerPprof(mux *http.ServeMux) {
+	mux.Handle("GET /debug/pprof/", s.protect(scopeWrite, http.HandlerFunc(pprof.Index)))
+	mux.Handle("GET /debug/pprof/cmdline", s.protect(scopeWrite, http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("GET /debug/pprof/profile", s.protect(scopeWrite, http.HandlerFunc(pprof.Profile)))
+	mux.Handle("GET /debug/pprof/symbol", s.protect(scopeWrite, http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("POST /debug/pprof/symbol", s.protect(scopeWrite, http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("GET /debug/pprof/trace", s.protect(scopeWrite, http.HandlerFunc(pprof.Trace)))
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/watcher"
+)
+
+// eventKeepAlive is the interval of comments keeping idle event streams open through proxies
+const eventKeepAlive = 30 * time.Second
+
+// handleEvents streams the watcher events as server-sent events, of all clusters or the ?cluster= one
+func (s *Server) handleEvents(rw http.ResponseWriter, r *http.Request) {
+	targets, ok := s.watchersFor(r)
+	if !ok {
+		writeError(rw, http.StatusNotFound, "unknown cluster")
+		return
+	}
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		writeError(rw, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	events := make(chan watcher.Event)
+	for _, w := range targets {
+		ch, unsubscribe := w.Subscribe()
+		defer unsubscribe()
+		go func() {
+			for event := range ch {
+				select {
+				case events <- event:
+				case <-r.Context().Done():
+				}
+			}
+		}()
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(rw, ": keep-alive\n\n")
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/metrics"
+	"github.com/qetesh/kube-watchtower/pkg/watcher"
+)
+
+// operationTimeout bounds the updates, rollbacks and unpins requested through the API, including their rollout wait
+const operationTimeout = 30 * time.Minute
+
+// Server exposes the HTTP API
+type Server struct {
+	server   *http.Server
+	watchers map[string]*watcher.Watcher // Keyed by cluster name, "" is the default cluster
+	fallback *watcher.Watcher            // Used when no cluster is requested
+	auth     *authenticator              // Nil without authentication
+	done     chan struct{}               // Closed on shutdown, ends event streams
+}
+
+// NewServer creates a new API server on cfg.APIAddr
+func NewServer(cfg *config.Config, watchers []*watcher.Watcher) (*Server, error) {
+	auth, err := newAuthenticator(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up API authentication: %w", err)
+	}
+
+	s := &Server{
+		watchers: make(map[string]*watcher.Watcher, len(watchers)),
+		auth:     auth,
+		done:     make(chan struct{}),
+	}
+	for _, w := range watchers {
+		s.watchers[w.ClusterName()] = w
+	}
+	if len(watchers) > 0 {
+		s.fallback = watchers[0]
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /v1/workloads", s.protect(scopeRead, http.HandlerFunc(s.handleWorkloads)))
+	mux.Handle("POST /v1/workloads/{namespace}/{kind}/{name}/update", s.protect(scopeWrite, http.HandlerFunc(s.handleUpdate)))
+	mux.Handle("POST /v1/workloads/{namespace}/{kind}/{name}/rollback", s.protect(scopeWrite, http.HandlerFunc(s.handleRollback)))
+	mux.Handle("POST /v1/workloads/{namespace}/{kind}/{name}/unpin", s.protect(scopeWrite, http.HandlerFunc(s.handleUnpin)))
+	mux.Handle("GET /v1/events", s.protect(scopeRead, http.HandlerFunc(s.handleEvents)))
+	mux.Handle("GET /v1/pause", s.protect(scopeRead, http.HandlerFunc(s.handlePauseStatus)))
+	mux.Handle("POST /v1/pause", s.protect(scopeWrite, http.HandlerFunc(s.handlePause)))
+	mux.Handle("POST /v1/resume", s.protect(scopeWrite, http.HandlerFunc(s.handleResume)))
+	mux.Handle("GET /metrics", s.protect(scopeRead, metrics.Handler()))
+	mux.HandleFunc("GET /healthz", s.handleHealthz) // Unauthenticated for probes
+	if cfg.APIPprof {
+		s.registerPprof(mux)
+	}
+
+	tlsCfg, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API TLS configuration: %w", err)
+	}
+
+	s.server = &http.Server{
+		Addr:              cfg.APIAddr,
+		Handler:           mux,
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+// Start starts serving in the background
+func (s *Server) Start() {
+	go func() {
+		var err error
+		if s.server.TLSConfig != nil {
+			logger.Infof("API server listening on %s (TLS)", s.server.Addr)
+			err = s.server.ListenAndServeTLS("", "")
+		} else {
+			logger.Infof("API server listening on %s", s.server.Addr)
+			err = s.server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("API server failed: %v", err)
+		}
+	}()
+}
+
+// Shutdown gracefully stops the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	close(s.done)
+	return s.server.Shutdown(ctx)
+}
+
+// handleWorkloads lists the workloads of the last check cycle of each cluster, optionally of one ?namespace=
+func (s *Server) handleWorkloads(rw http.ResponseWriter, r *http.Request) {
+	targets, ok := s.watchersFor(r)
+	if !ok {
+		writeError(rw, http.StatusNotFound, "unknown cluster")
+		return
+	}
+
+	namespace := r.URL.Query().Get("namespace")
+	result := make(map[string][]watcher.WorkloadStatus, len(targets))
+	for _, w := range targets {
+		workloads := make([]watcher.WorkloadStatus, 0)
+		for _, workload := range w.Workloads() {
+			if namespace == "" || workload.Namespace == namespace {
+				workloads = append(workloads, workload)
+			}
+		}
+		result[w.ClusterName()] = workloads
+	}
+	writeJSON(rw, http.StatusOK, result)
+}
+
+// handleUpdate checks and updates a single workload (or its ?container=), with ?dry-run=true only reporting the update
+func (s *Server) handleUpdate(rw http.ResponseWriter, r *http.Request) {
+	w, ok := s.watcherFor(r)
+	if !ok {
+		writeError(rw, http.StatusNotFound, "unknown cluster")
+		return
+	}
+
+	opts := watcher.UpdateOptions{
+		DryRun:    r.URL.Query().Get("dry-run") == "true",
+		Container: r.URL.Query().Get("container"),
+	}
+	ctx, cancel := operationContext(r)
+	defer cancel()
+	result, err := w.UpdateWorkload(ctx, r.PathValue("namespace"), r.PathValue("kind"), r.PathValue("name"), opts)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(rw, http.StatusOK, result)
+}
+
+// handleRollback reverts the last update of a workload, all of its updated containers unless one is given
+func (s *Server) handleRollback(rw http.ResponseWriter, r *http.Request) {
+	w, ok := s.watcherFor(r)
+	if !ok {
+		writeError(rw, http.StatusNotFound, "unknown cluster")
+		return
+	}
+
+	ctx, cancel := operationContext(r)
+	defer cancel()
+	result, err := w.Rollback(ctx, r.PathValue("namespace"), r.PathValue("kind"), r.PathValue("name"), r.URL.Query().Get("container"))
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(rw, http.StatusOK, result)
+}
+
+// handleUnpin restores the repo:tag images of a workload pinned by digest
+func (s *Server) handleUnpin(rw http.ResponseWriter, r *http.Request) {
+	w, ok := s.watcherFor(r)
+	if !ok {
+		writeError(rw, http.StatusNotFound, "unknown cluster")
+		return
+	}
+
+	ctx, cancel := operationContext(r)
+	defer cancel()
+	result, err := w.Unpin(ctx, r.PathValue("namespace"), r.PathValue("kind"), r.PathValue("name"), r.URL.Query().Get("container"))
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(rw, http.StatusOK, result)
+}
+
+// operationContext detaches an operation changing the cluster from the request, so a client disconnecting
+// doesn't cut its rollout wait, verification, smoke tests and hooks short
+func operationContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), operationTimeout)
+}
+
+// handlePauseStatus reports the pause status of each cluster
+func (s *Server) handlePauseStatus(rw http.ResponseWriter, r *http.Request) {
+	targets, ok := s.watchersFor(r)
+	if !ok {
+		writeError(rw, http.StatusNotFound, "unknown cluster")
+		return
+	}
+
+	status := make(map[string]watcher.PauseStatus, len(targets))
+	for _, w := range targets {
+		status[w.ClusterName()] = w.PauseStatus()
+	}
+	writeJSON(rw, http.StatusOK, status)
+}
+
+// handlePause pauses updates, until the optional ?until= time (RFC 3339) or duration (e.g. 2h)
+func (s *Server) handlePause(rw http.ResponseWriter, r *http.Request) {
+	targets, ok := s.watchersFor(r)
+	if !ok {
+		writeError(rw, http.StatusNotFound, "unknown cluster")
+		return
+	}
+
+	until, err := parseUntil(r.URL.Query().Get("until"))
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, w := range targets {
+		w.Pause(until)
+	}
+	s.handlePauseStatus(rw, r)
+}
+
+// handleResume resumes updates paused through the API
+func (s *Server) handleResume(rw http.ResponseWriter, r *http.Request) {
+	targets, ok := s.watchersFor(r)
+	if !ok {
+		writeError(rw, http.StatusNotFound, "unknown cluster")
+		return
+	}
+
+	for _, w := range targets {
+		w.Resume()
+	}
+	s.handlePauseStatus(rw, r)
+}
+
+// handleHealthz reports 503 while the check cycle of a cluster is stuck
+func (s *Server) handleHealthz(rw http.ResponseWriter, r *http.Request) {
+	problems := make(map[string]string)
+	for cluster, w := range s.watchers {
+		if err := w.Healthy(); err != nil {
+			problems[cluster] = err.Error()
+		}
+	}
+	if len(problems) > 0 {
+		writeJSON(rw, http.StatusServiceUnavailable, problems)
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// parseUntil parses an RFC 3339 time or a duration from now, empty is the zero time
+func parseUntil(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if until, err := time.Parse(time.RFC3339, value); err == nil {
+		return until, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return time.Time{}, fmt.Errorf("invalid until %q: expected an RFC 3339 time or a positive duration", value)
+	}
+	return time.Now().Add(duration), nil
+}
+
+// watchersFor selects the watcher for the ?cluster= query parameter, or all watchers
+func (s *Server) watchersFor(r *http.Request) ([]*watcher.Watcher, bool) {
+	if r.URL.Query().Get("cluster") != "" {
+		w, ok := s.watcherFor(r)
+		return []*watcher.Watcher{w}, ok
+	}
+
+	watchers := make([]*watcher.Watcher, 0, len(s.watchers))
+	for _, w := range s.watchers {
+		watchers = append(watchers, w)
+	}
+	return watchers, true
+}
+
+// watcherFor selects the watcher for the ?cluster= query parameter
+func (s *Server) watcherFor(r *http.Request) (*watcher.Watcher, bool) {
+	cluster := r.URL.Query().Get("cluster")
+	if cluster == "" {
+		return s.fallback, s.fallback != nil
+	}
+	w, ok := s.watchers[cluster]
+	return w, ok
+}
+
+// writeJSON writes a JSON response
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		logger.Debugf("Failed to write API response: %v", err)
+	}
+}
+
+// writeError writes a JSON error response
+func writeError(rw http.ResponseWriter, status int, message string) {
+	writeJSON(rw, status, map[string]string{"error": message})
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// tlsConfig returns the TLS configuration of the API server, nil to serve plain HTTP
+func tlsConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.APITLSCert == "" && cfg.APITLSKey == "" {
+		if cfg.APITLSClientCA != "" {
+			return nil, fmt.Errorf("API_TLS_CLIENT_CA requires API_TLS_CERT and API_TLS_KEY")
+		}
+		return nil, nil
+	}
+	if cfg.APITLSCert == "" || cfg.APITLSKey == "" {
+		return nil, fmt.Errorf("API_TLS_CERT and API_TLS_KEY must be set together")
+	}
+
+	reloader := &certReloader{certFile: cfg.APITLSCert, keyFile: cfg.APITLSKey}
+	if _, err := reloader.certificate(); err != nil {
+		return nil, err
+	}
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return reloader.certificate()
+		},
+	}
+
+	if cfg.APITLSClientCA != "" {
+		pem, err := os.ReadFile(cfg.APITLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", cfg.APITLSClientCA)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// certReloader loads the server certificate again when the files change, e.g. renewed by cert-manager
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// certificate returns the current certificate, checking the files at most every 10 seconds
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cert != nil && time.Since(r.checked) < 10*time.Second {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+
+	modTime := latestModTime(r.certFile, r.keyFile)
+	if r.cert != nil && !modTime.After(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			logger.Warnf("Failed to reload API certificate, keeping the previous one: %v", err)
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load API certificate: %w", err)
+	}
+	if r.cert != nil {
+		logger.Infof("Reloaded API certificate %s", r.certFile)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return r.cert, nil
+}
+
+// latestModTime returns the latest modification time of the files
+func latestModTime(files ...string) time.Time {
+	var latest time.Time
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
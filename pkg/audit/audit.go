@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/state"
+)
+
+// exportTimeout bounds the export of one record
+const exportTimeout = 15 * time.Second
+
+// sink stores audit records
+type sink interface {
+	// write stores one JSON line, name is a unique object name for object stores
+	write(ctx context.Context, name string, line []byte) error
+}
+
+// Exporter appends a JSON line per update or rollback to an audit log outside the cluster
+type Exporter struct {
+	sink    sink
+	cluster string
+}
+
+// Record is an exported audit record
+type Record struct {
+	Cluster string `json:"cluster,omitempty"`
+	state.UpdateRecord
+}
+
+// NewExporter creates an exporter for the audit log destination, nil if dest is empty (disabled)
+// All methods are safe to call on a nil Exporter.
+// Destinations: a file path (e.g. on a PVC), s3://bucket/prefix (endpoint overridable for S3-compatible
+// stores such as GCS or MinIO) or an Azure Blob container URL with a SAS token.
+func NewExporter(dest, s3Endpoint, cluster string) (*Exporter, error) {
+	if dest == "" {
+		return nil, nil
+	}
+
+	var (
+		s   sink
+		err error
+	)
+	switch {
+	case strings.HasPrefix(dest, "s3://"):
+		s, err = newS3Sink(dest, s3Endpoint)
+	case strings.HasPrefix(dest, "https://") && strings.Contains(dest, ".blob.core.windows.net"):
+		s, err = newAzureSink(dest)
+	case strings.HasPrefix(dest, "file://"):
+		s, err = newFileSink(strings.TrimPrefix(dest, "file://"))
+	case strings.Contains(dest, "://"):
+		return nil, fmt.Errorf("unsupported audit log destination %q", redact(dest))
+	default:
+		s, err = newFileSink(dest)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Exporting audit log to %s", redact(dest))
+	return &Exporter{sink: s, cluster: cluster}, nil
+}
+
+// Export writes an update record to the audit log, failures are logged
+func (e *Exporter) Export(ctx context.Context, record state.UpdateRecord) {
+	if e == nil {
+		return
+	}
+
+	line, err := json.Marshal(Record{Cluster: e.cluster, UpdateRecord: record})
+	if err != nil {
+		logger.Warnf("Failed to encode audit record: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), exportTimeout)
+	defer cancel()
+	if err := e.sink.write(ctx, objectName(e.cluster, record), line); err != nil {
+		logger.Warnf("Failed to export audit record of %s/%s/%s: %v", record.Namespace, record.Name, record.Container, err)
+	}
+}
+
+// objectName builds a unique, time-ordered object name, e.g.
+// 2024/06/02/20240602T100003.123456789Z-cluster-shop-web-nginx.json
+func objectName(cluster string, record state.UpdateRecord) string {
+	t := record.Time.UTC()
+	parts := []string{t.Format("20060102T150405.000000000Z")}
+	for _, part := range []string{cluster, record.Namespace, record.Name, record.Container} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return fmt.Sprintf("%s/%s.json", t.Format("2006/01/02"), strings.Join(parts, "-"))
+}
+
+// redact removes the query (e.g. a SAS token) and credentials from a destination URL
+func redact(dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme == "" {
+		return dest
+	}
+	u.RawQuery = ""
+	u.User = nil
+	return u.String()
+}
@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fileSink appends records to a file
+type fileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	f.Close()
+	return &fileSink{path: path}, nil
+}
+
+func (s *fileSink) write(_ context.Context, _ string, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// s3Sink writes one object per record, objects are never overwritten
+type s3Sink struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func newS3Sink(dest, endpoint string) (*s3Sink, error) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid audit log destination %q: expected s3://bucket/prefix", dest)
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &s3Sink{client: client, bucket: u.Host, prefix: strings.Trim(u.Path, "/")}, nil
+}
+
+func (s *s3Sink) write(ctx context.Context, name string, line []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(path.Join(s.prefix, name)),
+		Body:        bytes.NewReader(line),
+		ContentType: aws.String("application/x-ndjson"),
+		IfNoneMatch: aws.String("*"),
+	})
+	return err
+}
+
+// azureSink writes one block blob per record through a container SAS URL
+type azureSink struct {
+	container *url.URL
+}
+
+func newAzureSink(dest string) (*azureSink, error) {
+	u, err := url.Parse(dest)
+	if err != nil || u.RawQuery == "" {
+		return nil, fmt.Errorf("invalid audit log destination: expected an Azure Blob container URL with a SAS token")
+	}
+	return &azureSink{container: u}, nil
+}
+
+func (s *azureSink) write(ctx context.Context, name string, line []byte) error {
+	blob := *s.container
+	blob.Path = path.Join(blob.Path, name)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, blob.String(), bytes.NewReader(line))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-version", "2021-08-06")
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("If-None-Match", "*")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("blob storage returned status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
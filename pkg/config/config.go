@@ -1,83 +1,795 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
 )
 
+// Registry credential lookup modes
+const (
+	RegistryAuthPullSecrets = "pullsecrets"
+	RegistryAuthK8sChain    = "k8schain"
+)
+
+// Update modes: how a new image is written to the workload
+const (
+	UpdateModeDigest  = "digest"  // repo:tag@digest
+	UpdateModeTag     = "tag"     // repo:newtag, the newest version tag
+	UpdateModeRestart = "restart" // Unchanged image, the pods are restarted to pull the tag again
+)
+
+// Handling of workloads owned by an operator or custom controller, which reverts or is confused by image edits
+const (
+	OwnedWorkloadsDefer  = "defer"  // New images are reported as deferred updates
+	OwnedWorkloadsSkip   = "skip"   // Not checked
+	OwnedWorkloadsUpdate = "update" // Updated like any other workload
+)
+
+// Handling of workloads installed by Helm, whose next helm upgrade reverts the image
+const (
+	HelmWorkloadsUpdate = "update" // Updated like any other workload
+	HelmWorkloadsWarn   = "warn"   // Updated, the notification warns about the revert
+	HelmWorkloadsSkip   = "skip"   // Not checked
+)
+
+// DefaultProtectedNamespaces are the control-plane and cluster networking namespaces excluded by default,
+// an update there can restart CNI, CSI or DNS components cluster-wide
+var DefaultProtectedNamespaces = []string{"kube-system", "kube-public", "kube-node-lease", "calico-system", "tigera-operator", "kube-flannel"}
+
+// DefaultSidecarContainers are the container names of sidecars injected by service meshes and runtimes,
+// updating them fights the injector and can break the proxy/control-plane version alignment
+var DefaultSidecarContainers = []string{"istio-proxy", "istio-validation", "linkerd-proxy", "linkerd-init", "envoy", "envoy-sidecar", "daprd", "kuma-sidecar", "consul-dataplane"}
+
+// DefaultSidecarImages are the image repositories of injected sidecars, matched whatever the container is named
+var DefaultSidecarImages = []string{"*istio/proxyv2", "*istio-release/proxyv2", "*linkerd/proxy", "*linkerd/proxy-init", "*envoyproxy/envoy", "*daprio/daprd", "*kumahq/kuma-dp", "*hashicorp/consul-dataplane"}
+
+// sidecarInjectionKeys are the pod template annotations and labels enabling the sidecar injection
+// of the service meshes and runtimes of DefaultSidecarContainers
+var sidecarInjectionKeys = []string{"sidecar.istio.io/inject", "inject.istio.io/templates", "linkerd.io/inject", "dapr.io/enabled", "kuma.io/sidecar-injection", "consul.hashicorp.com/connect-inject"}
+
+// DefaultWorkloadNotifyAllow are the schemes of chat services with fixed endpoints, allowed for workload notification URLs
+// URLs with a host chosen by the workload (webhooks, self-hosted services) need an explicit scheme://host entry.
+var DefaultWorkloadNotifyAllow = []string{"slack", "discord", "teams", "telegram", "googlechat", "pushover"}
+
+// DefaultPromotionStages are the promotion stages, from the first one receiving new digests to production
+var DefaultPromotionStages = []string{"dev", "staging", "prod"}
+
+// IsUpdateMode checks if mode is a known update mode
+func IsUpdateMode(mode string) bool {
+	return mode == UpdateModeDigest || mode == UpdateModeTag || mode == UpdateModeRestart
+}
+
 // Config stores application configuration
 type Config struct {
 
-	// Notification URL (shoutrrr format) (default: "")
+	// Notification URLs (space separated): shoutrrr format, webhook+https://, file:// or kubernetes:// (default: "")
 	NotificationURL string
 
-	// Notification cluster name (default: "kubernetes")
+	// Secret holding the notification URL as namespace/name/key, re-read every check (default: "")
+	NotificationURLSecret string
+
+	// Notification cluster name, detected from the cluster if empty (default: "")
 	NotificationCluster string
 
+	// Send a notification when kube-watchtower starts (default: false)
+	NotificationStartup bool
+
+	// Delivery attempts per notification, transient errors (timeouts, 429, 5xx) are retried (default: 3)
+	NotificationRetries int
+
+	// Delay before the first notification retry, doubled after each attempt (default: 2s)
+	NotificationRetryDelay time.Duration
+
+	// Summary notifications per hour and notification URL, held back updates are counted in the next summary (default: 0, no limit)
+	NotificationRateLimit int
+
+	// Summary title template with {{.Cluster}} and {{.DryRun}} (default: "kube-watchtower updates on {{.Cluster}}")
+	NotificationTitle string
+
+	// Context line template appended to summaries, with {{.Cluster}}, {{.Environment}}, {{.ServerVersion}}, {{.Nodes}}, {{.Version}} and {{.DryRun}} (default: "")
+	NotificationFooter string
+
+	// Environment label of the cluster for the footer template, e.g. production (default: "")
+	NotificationEnvironment string
+
+	// Markers replacing the default emoji, by name (name=emoji, comma separated) (default: "")
+	NotificationEmoji map[string]string
+
+	// Leave emoji out of notifications, e.g. for email or ticketing systems (default: false)
+	NotificationNoEmoji bool
+
+	// With several notification URLs, try the next one only if the previous failed instead of sending to all (default: false)
+	NotificationFailover bool
+
+	// Honor the kube-watchtower.io/notify-url and notify-url-secret annotations of workloads (default: false)
+	WorkloadNotifyURLs bool
+
+	// Schemes ("slack") or scheme://host patterns ("webhook+https://*.example.com") allowed for namespace and workload
+	// notification URLs (comma separated) (default: DefaultWorkloadNotifyAllow)
+	WorkloadNotifyAllow []string
+
+	// Leave out the namespace and workload notification URLs, e.g. for a one-off check (set by the check command)
+	SkipRoutedNotifications bool
+
+	// Kubeconfig contexts to watch, one watcher per context (comma separated) (default: "")
+	KubeContexts []string
+
+	// Kubeconfig context used by this watcher (set by ForContext)
+	KubeContext string
+
 	// Kubernetes disable namespaces (comma separated) (default: "")
 	DisableNamespaces []string
 
 	// Kubernetes enable namespaces (comma separated) (default: "")
 	EnableNamespaces []string
 
+	// Namespaces never monitored unless named exactly in EnableNamespaces (default: DefaultProtectedNamespaces)
+	ProtectedNamespaces []string
+
+	// Kubernetes namespace label selector (default: "")
+	NamespaceSelector string
+
+	// Container disable list (comma separated) (default: "")
+	DisableContainers []string
+
+	// Container enable list (comma separated) (default: "")
+	EnableContainers []string
+
+	// Container names of injected sidecars, never updated (comma separated) (default: DefaultSidecarContainers)
+	SidecarContainers []string
+
+	// Image repositories of injected sidecars, never updated (comma separated) (default: DefaultSidecarImages)
+	SidecarImages []string
+
+	// Whether SidecarContainers and SidecarImages hold the defaults, which only apply to pods
+	// with other containers or sidecar injection enabled (set by LoadConfig)
+	defaultSidecarContainers bool
+	defaultSidecarImages     bool
+
+	// Handling of workloads owned by an operator or custom controller: defer, skip or update (default: defer)
+	OwnedWorkloads string
+
+	// Owner kind (Kind.group) or kind/name patterns whose workloads are updated anyway (comma separated) (default: "")
+	OwnedWorkloadsAllow []string
+
+	// Handling of workloads installed by Helm: update, warn or skip (default: update)
+	HelmWorkloads string
+
+	// Check Deployments and StatefulSets scaled to zero, patching the image of their template (default: false)
+	ScaledToZero bool
+
+	// Check CronJobs, patching the image of their job template (default: false)
+	CronJobs bool
+
+	// Update the job template of suspended CronJobs, reported as not run yet (default: true)
+	UpdateSuspendedCronJobs bool
+
+	// Promote digests through the stages of PromotionStages, validated by a soak in the previous stage (default: false)
+	Promotion bool
+
+	// Promotion stages in order, the values of PromotionLabel (comma separated) (default: DefaultPromotionStages)
+	PromotionStages []string
+
+	// Workload label holding the promotion stage (default: stage)
+	PromotionLabel string
+
+	// Time a digest runs in a stage before it is promoted to the next one (default: 1h)
+	PromotionSoak time.Duration
+
+	// Workload name include/exclude patterns (INCLUDE_WORKLOADS / EXCLUDE_WORKLOADS) (default: "")
+	WorkloadFilter Filter
+
+	// Image repository include/exclude patterns (INCLUDE_IMAGES / EXCLUDE_IMAGES) (default: "")
+	ImageFilter Filter
+
 	// Log level (default: info)
 	LogLevel string
 
+	// Syslog server additionally receiving the log in RFC 5424 format: udp://, tcp:// or tls://host:port (default: "")
+	SyslogTarget string
+
+	// CA file verifying the syslog server of a tls:// target, system roots if empty (default: "")
+	SyslogTLSCA string
+
 	// Dry-run mode (default: false)
 	DryRun bool
+
+	// Namespace kube-watchtower runs in (default: POD_NAMESPACE or service account namespace)
+	PodNamespace string
+
+	// Pod kube-watchtower runs in, to find its own workload (default: POD_NAME or the hostname)
+	PodName string
+
+	// Name of the ConfigMap persisting digests and update history, empty disables (default: "")
+	StateConfigMap string
+
+	// Name of the ConfigMap holding the status of the last check cycle, empty disables (default: "")
+	StatusConfigMap string
+
+	// Maximum number of update history records kept in the state ConfigMap (default: 100)
+	StateHistoryLimit int
+
+	// Wait before retrying a container after a failure, doubled with every consecutive failure
+	// up to a day, 0 retries every check (default: 5m)
+	FailureBackoff time.Duration
+
+	// Audit log destination receiving a JSON line per update and rollback: a file path,
+	// s3://bucket/prefix or an Azure Blob container SAS URL, empty disables (default: "")
+	AuditLog string
+
+	// Endpoint of an S3-compatible store for AUDIT_LOG, e.g. https://storage.googleapis.com (default: "")
+	AuditLogS3Endpoint string
+
+	// Interval between checks, 0 runs a single check and exits (default: 0)
+	CheckInterval time.Duration
+
+	// Random delay up to this duration before each check, 0 disables (default: 0)
+	CheckJitter time.Duration
+
+	// Address of the HTTP API server, empty disables (default: "")
+	APIAddr string
+
+	// Certificate and key files of the API server, serves HTTPS when set (default: "")
+	APITLSCert string
+	APITLSKey  string
+
+	// CA file verifying client certificates of the API server, required when set (default: "")
+	APITLSClientCA string
+
+	// Serve net/http/pprof profiles on /debug/pprof/ of the API server (default: false)
+	APIPprof bool
+
+	// Bearer token granting full access to the API server (default: "")
+	APIToken string
+
+	// Bearer token granting read-only access to the API server (default: "")
+	APIReadToken string
+
+	// Authorize other bearer tokens with Kubernetes TokenReviews and SubjectAccessReviews (default: false)
+	APIAuthKubernetes bool
+
+	// Stuck check cycle limit as a multiple of the last cycle duration, fails /healthz, 0 disables (default: 0)
+	WatchdogFactor float32
+
+	// Minimum time without progress before a check cycle counts as stuck (default: 10m)
+	WatchdogMinTimeout time.Duration
+
+	// Exit when a check cycle is stuck, so the pod is restarted (default: false)
+	WatchdogExit bool
+
+	// Distinct namespaces, registry hosts and workloads labeled in metrics, others are labeled "other" (default: 50)
+	MetricsLabelLimit int
+
+	// Webhook called before each update, a non-2xx response vetoes it (default: "")
+	PreUpdateWebhook string
+
+	// Webhook called after each update (default: "")
+	PostUpdateWebhook string
+
+	// Executables run at the pre-check, pre-update, post-update and on-failure stages (default: none)
+	Plugins []string
+
+	// Time a plugin may run before it counts as failed (default: 30s)
+	PluginTimeout time.Duration
+
+	// OPA data API URL of the update policy rule (default: "")
+	PolicyURL string
+
+	// Timeout for webhook and policy requests (default: 10s)
+	WebhookTimeout time.Duration
+
+	// Time in-flight updates may take to finish on shutdown (default: 5m)
+	DrainTimeout time.Duration
+
+	// Number of workloads checked in parallel (default: 1)
+	CheckConcurrency int
+
+	// Maximum number of workloads being updated at once, 0 is unlimited (default: 1)
+	MaxConcurrentRollouts int
+
+	// Maximum number of workloads being updated at once per namespace, 0 is unlimited (default: 0)
+	MaxConcurrentRolloutsPerNamespace int
+
+	// Defer updates while an attached HPA scaled within this window, 0 disables (default: 5m)
+	HPAStabilizationWindow time.Duration
+
+	// Deployments with fewer replicas are only reported, not updated (default: 0)
+	MinReplicas int
+
+	// StatefulSets with fewer replicas are only reported, not updated (default: 2)
+	MinStatefulSetReplicas int
+
+	// Snapshot the PVCs of StatefulSets before updating them (default: false)
+	VolumeSnapshots bool
+
+	// VolumeSnapshotClass of the snapshots, empty for the cluster default (default: "")
+	VolumeSnapshotClass string
+
+	// Time the volume snapshots may take to be cut before the update is aborted (default: 5m)
+	VolumeSnapshotTimeout time.Duration
+
+	// Defer updates whose new image lacks a platform of the nodes running the workload (default: true)
+	PlatformCheck bool
+
+	// Delete the DaemonSet pods still running an outdated digest of an up-to-date image (default: false)
+	RestartStalePods bool
+
+	// Name of the per-namespace ConfigMap holding local policy (default: "kube-watchtower")
+	NamespaceConfigName string
+
+	// Remove superseded images from the nodes after an update (default: false)
+	Cleanup bool
+
+	// Image of the node cleanup Jobs, needs nsenter (default: busybox:stable)
+	CleanupImage string
+
+	// Old ReplicaSets / ControllerRevisions kept on cleanup, negative keeps all (default: -1)
+	CleanupRevisions int
+
+	// Read the image digests cached on nodes from the node status, best effort as the kubelet
+	// lists only the largest images (default: false)
+	NodeImageDiscovery bool
+
+	// Report newer version tags of images pinned by digest or version tag (default: false)
+	TagAdvisory bool
+
+	// Kubernetes API client queries per second, 0 uses the client-go default (default: 0)
+	K8sQPS float32
+
+	// Kubernetes API client burst, 0 uses the client-go default (default: 0)
+	K8sBurst int
+
+	// Per-registry proxy overrides, registry host -> proxy URL or "direct" (REGISTRY_PROXIES, comma separated host=proxy) (default: "")
+	RegistryProxies map[string]string
+
+	// How updates are written: "digest" pins repo:tag@digest, "tag" writes the newest version tag,
+	// "restart" keeps the image and restarts the pods (default: digest)
+	UpdateMode string
+
+	// Update groups in order (kube-watchtower.io/update-group), a group is updated once the previous one
+	// completed successfully (comma separated) (default: "")
+	UpdateGroups []string
+
+	// Update group of workloads without the annotation, empty leaves them out of the order (default: "")
+	UpdateGroupDefault string
+
+	// Registry credential lookup: "pullsecrets" matches the imagePullSecrets of the workload,
+	// "k8schain" also uses service accounts and cloud provider credentials (default: pullsecrets)
+	RegistryAuth string
+
+	// Docker config.json with registry credentials used when no imagePullSecret matches,
+	// e.g. a mounted kubernetes.io/dockerconfigjson Secret (default: "")
+	RegistryCredentialsFile string
+
+	// Robot account files per registry host, Harbor (name, secret, expires_at) or Quay (name, token) JSON
+	// (REGISTRY_ROBOTS, comma separated host=path) (default: "")
+	RegistryRobots map[string]string
+
+	// JFrog Artifactory access token or API key files per registry host (REGISTRY_ARTIFACTORY, comma separated host=path) (default: "")
+	RegistryArtifactory map[string]string
+
+	// Time a registry lookup may take before the check fails, 0 relies on the transport timeouts (default: 30s)
+	RegistryTimeout time.Duration
+
+	// Consecutive failures after which the images of a registry are skipped for RegistryCircuitCooldown, 0 disables (default: 3)
+	RegistryCircuitThreshold int
+
+	// Time the images of a failing registry are skipped (default: 5m)
+	RegistryCircuitCooldown time.Duration
+
+	// Time registry tokens are reused across checks, 0 authenticates every request (default: 5m)
+	RegistryTokenTTL time.Duration
+
+	// Objects requested per Kubernetes List call (default: 500)
+	K8sPageSize int
+
+	// User-Agent of Kubernetes API requests, includes the version (set by main) (default: kube-watchtower)
+	UserAgent string
+
+	// kube-watchtower version, shown in notifications (set by main) (default: dev)
+	Version string
+
+	// Malformed environment values found while loading
+	envProblems []error
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
+	env := &envReader{}
 	config := &Config{
 		LogLevel:            getEnv("LOG_LEVEL", "info"),
+		SyslogTarget:        getEnv("SYSLOG_TARGET", ""),
+		SyslogTLSCA:         getEnv("SYSLOG_TLS_CA", ""),
 		NotificationURL:     getEnv("NOTIFICATION_URL", ""),
-		NotificationCluster: getEnv("NOTIFICATION_CLUSTER", "kubernetes"),
-		DryRun:              getEnvBool("DRY_RUN", false),
+		NotificationCluster: getEnv("NOTIFICATION_CLUSTER", ""),
+		DryRun:              env.getBool("DRY_RUN", false),
+		NamespaceSelector:   getEnv("NAMESPACE_SELECTOR", ""),
+		NamespaceConfigName: getEnv("NAMESPACE_CONFIG_NAME", "kube-watchtower"),
+		PodNamespace:        getEnv("POD_NAMESPACE", serviceAccountNamespace()),
+		PodName:             getEnv("POD_NAME", hostname()),
+		StateConfigMap:      getEnv("STATE_CONFIGMAP", ""),
+		StateHistoryLimit:   env.getInt("STATE_HISTORY_LIMIT", 100),
+		FailureBackoff:      env.getDuration("FAILURE_BACKOFF", 5*time.Minute),
+		AuditLog:            getEnv("AUDIT_LOG", ""),
+		AuditLogS3Endpoint:  getEnv("AUDIT_LOG_S3_ENDPOINT", ""),
+		StatusConfigMap:     getEnv("STATUS_CONFIGMAP", ""),
+		CheckInterval:       env.getDuration("CHECK_INTERVAL", 0),
+		CheckJitter:         env.getDuration("CHECK_JITTER", 0),
+		APIAddr:             getEnv("API_ADDR", ""),
+		APITLSCert:          getEnv("API_TLS_CERT", ""),
+		APITLSKey:           getEnv("API_TLS_KEY", ""),
+		APITLSClientCA:      getEnv("API_TLS_CLIENT_CA", ""),
+		APIPprof:            env.getBool("API_PPROF", false),
+		APIToken:            getEnv("API_TOKEN", ""),
+		APIReadToken:        getEnv("API_READ_TOKEN", ""),
+		APIAuthKubernetes:   env.getBool("API_AUTH_KUBERNETES", false),
+		MetricsLabelLimit:   env.getInt("METRICS_LABEL_LIMIT", 50),
+		WatchdogFactor:      env.getFloat("WATCHDOG_FACTOR", 0),
+		WatchdogMinTimeout:  env.getDuration("WATCHDOG_MIN_TIMEOUT", 10*time.Minute),
+		WatchdogExit:        env.getBool("WATCHDOG_EXIT", false),
+		PreUpdateWebhook:    getEnv("PRE_UPDATE_WEBHOOK", ""),
+		PostUpdateWebhook:   getEnv("POST_UPDATE_WEBHOOK", ""),
+		WebhookTimeout:      env.getDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		PolicyURL:           getEnv("POLICY_URL", ""),
+		Plugins:             getEnvList("PLUGINS"),
+		PluginTimeout:       env.getDuration("PLUGIN_TIMEOUT", 30*time.Second),
+
+		NotificationURLSecret:   getEnv("NOTIFICATION_URL_SECRET", ""),
+		NotificationStartup:     env.getBool("NOTIFICATION_STARTUP", false),
+		NotificationRetries:     env.getInt("NOTIFICATION_RETRIES", 3),
+		NotificationRetryDelay:  env.getDuration("NOTIFICATION_RETRY_DELAY", 2*time.Second),
+		NotificationRateLimit:   env.getInt("NOTIFICATION_RATE_LIMIT", 0),
+		NotificationTitle:       getEnv("NOTIFICATION_TITLE", ""),
+		NotificationFooter:      getEnv("NOTIFICATION_FOOTER", ""),
+		NotificationEnvironment: getEnv("NOTIFICATION_ENVIRONMENT", ""),
+		NotificationNoEmoji:     env.getBool("NOTIFICATION_NO_EMOJI", false),
+		NotificationFailover:    env.getBool("NOTIFICATION_FAILOVER", false),
+
+		HPAStabilizationWindow: env.getDuration("HPA_STABILIZATION_WINDOW", 5*time.Minute),
+		MinReplicas:            env.getInt("MIN_REPLICAS", 0),
+		MinStatefulSetReplicas: env.getInt("MIN_STATEFULSET_REPLICAS", 2),
+		VolumeSnapshots:        env.getBool("VOLUME_SNAPSHOTS", false),
+		VolumeSnapshotClass:    getEnv("VOLUME_SNAPSHOT_CLASS", ""),
+		VolumeSnapshotTimeout:  env.getDuration("VOLUME_SNAPSHOT_TIMEOUT", 5*time.Minute),
+		PlatformCheck:          env.getBool("PLATFORM_CHECK", true),
+		RestartStalePods:       env.getBool("RESTART_STALE_PODS", false),
+
+		DrainTimeout:                      env.getDuration("DRAIN_TIMEOUT", 5*time.Minute),
+		CheckConcurrency:                  env.getInt("CHECK_CONCURRENCY", 1),
+		MaxConcurrentRollouts:             env.getInt("MAX_CONCURRENT_ROLLOUTS", 1),
+		MaxConcurrentRolloutsPerNamespace: env.getInt("MAX_CONCURRENT_ROLLOUTS_PER_NAMESPACE", 0),
+
+		Cleanup:      env.getBool("CLEANUP", false),
+		CleanupImage: getEnv("CLEANUP_IMAGE", "busybox:stable"),
+
+		CleanupRevisions:   env.getInt("CLEANUP_REVISIONS", -1),
+		NodeImageDiscovery: env.getBool("NODE_IMAGE_DISCOVERY", false),
+		TagAdvisory:        env.getBool("TAG_ADVISORY", false),
+
+		K8sQPS:      env.getFloat("K8S_QPS", 0),
+		K8sBurst:    env.getInt("K8S_BURST", 0),
+		K8sPageSize: env.getInt("K8S_PAGE_SIZE", 500),
+
+		UpdateMode:              getEnv("UPDATE_MODE", UpdateModeDigest),
+		RegistryAuth:            getEnv("REGISTRY_AUTH", RegistryAuthPullSecrets),
+		RegistryCredentialsFile: getEnv("REGISTRY_CREDENTIALS_FILE", ""),
+		RegistryTokenTTL:        env.getDuration("REGISTRY_TOKEN_TTL", 5*time.Minute),
+
+		RegistryTimeout:          env.getDuration("REGISTRY_TIMEOUT", 30*time.Second),
+		RegistryCircuitThreshold: env.getInt("REGISTRY_CIRCUIT_THRESHOLD", 3),
+		RegistryCircuitCooldown:  env.getDuration("REGISTRY_CIRCUIT_COOLDOWN", 5*time.Minute),
+		UserAgent:                "kube-watchtower",
+		Version:                  "dev",
 	}
 
-	// Parse disabled namespaces list
-	disableNamespacesStr := getEnv("DISABLE_NAMESPACES", "")
-	if disableNamespacesStr != "" {
-		config.DisableNamespaces = strings.Split(disableNamespacesStr, ",")
-		for i := range config.DisableNamespaces {
-			config.DisableNamespaces[i] = strings.TrimSpace(config.DisableNamespaces[i])
-		}
+	// Parse registry proxy overrides
+	config.RegistryProxies = getEnvMap("REGISTRY_PROXIES")
+	config.RegistryRobots = getEnvMap("REGISTRY_ROBOTS")
+	config.RegistryArtifactory = getEnvMap("REGISTRY_ARTIFACTORY")
+	config.NotificationEmoji = getEnvMap("NOTIFICATION_EMOJI")
+
+	// Parse kubeconfig contexts
+	config.KubeContexts = getEnvList("KUBE_CONTEXTS")
+
+	// Parse namespace lists
+	config.DisableNamespaces = getEnvList("DISABLE_NAMESPACES")
+	config.EnableNamespaces = getEnvList("ENABLE_NAMESPACES")
+	config.ProtectedNamespaces = DefaultProtectedNamespaces
+	if value, ok := os.LookupEnv("PROTECTED_NAMESPACES"); ok {
+		config.ProtectedNamespaces = splitList(value)
 	}
 
-	// Parse enabled namespaces list
-	enableNamespacesStr := getEnv("ENABLE_NAMESPACES", "")
-	if enableNamespacesStr != "" {
-		config.EnableNamespaces = strings.Split(enableNamespacesStr, ",")
-		for i := range config.EnableNamespaces {
-			config.EnableNamespaces[i] = strings.TrimSpace(config.EnableNamespaces[i])
-		}
+	// Parse container lists
+	config.DisableContainers = getEnvList("DISABLE_CONTAINERS")
+	config.EnableContainers = getEnvList("ENABLE_CONTAINERS")
+	config.SidecarContainers, config.defaultSidecarContainers = DefaultSidecarContainers, true
+	if value, ok := os.LookupEnv("SIDECAR_CONTAINERS"); ok {
+		config.SidecarContainers, config.defaultSidecarContainers = splitList(value), false
+	}
+	config.SidecarImages, config.defaultSidecarImages = DefaultSidecarImages, true
+	if value, ok := os.LookupEnv("SIDECAR_IMAGES"); ok {
+		config.SidecarImages, config.defaultSidecarImages = splitList(value), false
 	}
+
+	// Parse the handling of operator-owned and Helm-managed workloads
+	config.OwnedWorkloads = getEnv("OWNED_WORKLOADS", OwnedWorkloadsDefer)
+	config.OwnedWorkloadsAllow = getEnvList("OWNED_WORKLOADS_ALLOW")
+	config.HelmWorkloads = getEnv("HELM_WORKLOADS", HelmWorkloadsUpdate)
+	config.ScaledToZero = env.getBool("SCALED_TO_ZERO", false)
+	config.CronJobs = env.getBool("CRONJOBS", false)
+	config.UpdateSuspendedCronJobs = env.getBool("UPDATE_SUSPENDED_CRONJOBS", true)
+
+	// Parse the promotion pipeline
+	config.Promotion = env.getBool("PROMOTION", false)
+	config.PromotionStages = DefaultPromotionStages
+	if value, ok := os.LookupEnv("PROMOTION_STAGES"); ok {
+		config.PromotionStages = splitList(value)
+	}
+	config.PromotionLabel = getEnv("PROMOTION_LABEL", "stage")
+	config.PromotionSoak = env.getDuration("PROMOTION_SOAK", time.Hour)
+
+	// Parse the workload notification URLs
+	config.WorkloadNotifyURLs = env.getBool("WORKLOAD_NOTIFY_URLS", false)
+	config.WorkloadNotifyAllow = DefaultWorkloadNotifyAllow
+	if value, ok := os.LookupEnv("WORKLOAD_NOTIFY_ALLOW"); ok {
+		config.WorkloadNotifyAllow = splitList(value)
+	}
+
+	// Parse the update group order
+	config.UpdateGroups = getEnvList("UPDATE_GROUPS")
+	config.UpdateGroupDefault = getEnv("UPDATE_GROUP_DEFAULT", "")
+
+	// Parse workload and image filters
+	config.WorkloadFilter = Filter{
+		Include: getEnvList("INCLUDE_WORKLOADS"),
+		Exclude: getEnvList("EXCLUDE_WORKLOADS"),
+	}
+	config.ImageFilter = Filter{
+		Include: getEnvList("INCLUDE_IMAGES"),
+		Exclude: getEnvList("EXCLUDE_IMAGES"),
+	}
+	config.envProblems = env.problems
 	return config
 }
 
+// EnvProblems lists the malformed environment values, which were replaced by defaults
+func (c *Config) EnvProblems() []error {
+	return c.envProblems
+}
+
+// envReader reads typed environment values, collecting the malformed ones for the Config being loaded
+type envReader struct {
+	problems []error
+}
+
+// problem records a malformed environment value and the value used instead
+func (e *envReader) problem(key, value string, used interface{}) {
+	e.problems = append(e.problems, fmt.Errorf("invalid %s %q, using %v", key, value, used))
+}
+
+// ForContext returns a copy of the configuration bound to a kubeconfig context
+// The context name is used as the notification cluster name
+func (c *Config) ForContext(kubeContext string) *Config {
+	cfg := *c
+	cfg.KubeContext = kubeContext
+	cfg.NotificationCluster = kubeContext
+	return &cfg
+}
+
 // IsNamespaceAllowed checks if a namespace should be monitored
 // If EnableNamespaces is not empty, only namespaces in the list are allowed (whitelist mode)
 // If EnableNamespaces is empty, all namespaces except those in DisableNamespaces are allowed (blacklist mode)
+// Protected namespaces are excluded in both modes.
+// List entries may be exact names, globs ("team-*") or regular expressions ("/^kube-.*/")
 func (c *Config) IsNamespaceAllowed(namespace string) bool {
+	if c.IsNamespaceProtected(namespace) {
+		return false
+	}
+
 	// Whitelist mode: if EnableNamespaces is set, only allow those namespaces
 	if len(c.EnableNamespaces) > 0 {
-		for _, enabled := range c.EnableNamespaces {
-			if enabled == namespace {
+		return MatchAnyPattern(c.EnableNamespaces, namespace)
+	}
+
+	// Blacklist mode: allow all except disabled namespaces
+	return !MatchAnyPattern(c.DisableNamespaces, namespace)
+}
+
+// IsNamespaceProtected checks if a namespace is protected and not explicitly enabled by its exact name
+func (c *Config) IsNamespaceProtected(namespace string) bool {
+	if !MatchAnyPattern(c.ProtectedNamespaces, namespace) {
+		return false
+	}
+	for _, enabled := range c.EnableNamespaces {
+		if enabled == namespace {
+			return false
+		}
+	}
+	return true
+}
+
+// IsContainerDisabled checks if a container should be skipped
+// If EnableContainers is not empty, only containers in the list are monitored
+// Otherwise containers in DisableContainers are skipped
+func (c *Config) IsContainerDisabled(container string) bool {
+	if len(c.EnableContainers) > 0 {
+		return !MatchAnyPattern(c.EnableContainers, container)
+	}
+	return MatchAnyPattern(c.DisableContainers, container)
+}
+
+// IsSidecar checks if a container is an injected sidecar by its name or image repository
+// The default patterns only match when the pod has other containers or sidecar injection enabled (injected),
+// so that e.g. a standalone Envoy Deployment is still updated; configured patterns always match.
+func (c *Config) IsSidecar(container, repository string, injected bool) bool {
+	if MatchAnyPattern(c.SidecarContainers, container) && (injected || !c.defaultSidecarContainers) {
+		return true
+	}
+	return MatchAnyPattern(c.SidecarImages, repository) && (injected || !c.defaultSidecarImages)
+}
+
+// IsSidecarInjected checks if the annotations or labels of a pod template enable the sidecar injection
+// of a service mesh or runtime
+func IsSidecarInjected(annotations, labels map[string]string) bool {
+	for _, key := range sidecarInjectionKeys {
+		for _, values := range []map[string]string{annotations, labels} {
+			switch strings.ToLower(values[key]) {
+			case "", "false", "disabled":
+			default:
 				return true
 			}
 		}
-		return false
 	}
+	return false
+}
 
-	// Blacklist mode: allow all except disabled namespaces
-	for _, disabled := range c.DisableNamespaces {
-		if disabled == namespace {
+// IsWorkloadNotifyURLAllowed checks if a namespace or workload may route its notifications to the URLs (space separated)
+// Files and Kubernetes Events are never allowed, they write with the watcher's permissions.
+func (c *Config) IsWorkloadNotifyURLAllowed(rawURLs string) bool {
+	fields := strings.Fields(rawURLs)
+	for _, rawURL := range fields {
+		if !c.isWorkloadNotifyURLAllowed(rawURL) {
 			return false
 		}
 	}
-	return true
+	return len(fields) > 0
+}
+
+// isWorkloadNotifyURLAllowed checks one namespace or workload notification URL against WorkloadNotifyAllow
+func (c *Config) isWorkloadNotifyURLAllowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "" || scheme == "file" || scheme == "kubernetes" {
+		return false
+	}
+	for _, entry := range c.WorkloadNotifyAllow {
+		entryScheme, host, found := strings.Cut(entry, "://")
+		if !strings.EqualFold(entryScheme, scheme) {
+			continue
+		}
+		if !found || MatchPattern(host, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOwnerAllowed checks if the workloads of an owner are updated despite OwnedWorkloads,
+// by its kind qualified with the API group or by kind/name
+func (c *Config) IsOwnerAllowed(kind, name string) bool {
+	return MatchAnyPattern(c.OwnedWorkloadsAllow, kind) || MatchAnyPattern(c.OwnedWorkloadsAllow, kind+"/"+name)
+}
+
+// Validate checks the configuration for malformed values
+func (c *Config) Validate() error {
+	return errors.Join(c.Problems()...)
+}
+
+// Problems lists all malformed values of the configuration
+func (c *Config) Problems() []error {
+	var problems []error
+	for _, pattern := range append(append(append([]string{}, c.EnableNamespaces...), c.DisableNamespaces...), c.ProtectedNamespaces...) {
+		if err := ValidatePattern(pattern); err != nil {
+			problems = append(problems, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err))
+		}
+	}
+	for _, pattern := range append(append(append([]string{}, c.EnableContainers...), c.DisableContainers...), c.SidecarContainers...) {
+		if err := ValidatePattern(pattern); err != nil {
+			problems = append(problems, fmt.Errorf("invalid container pattern %q: %w", pattern, err))
+		}
+	}
+	if err := c.WorkloadFilter.Validate(); err != nil {
+		problems = append(problems, fmt.Errorf("invalid workload filter: %w", err))
+	}
+	for _, pattern := range c.OwnedWorkloadsAllow {
+		if err := ValidatePattern(pattern); err != nil {
+			problems = append(problems, fmt.Errorf("invalid owned workloads pattern %q: %w", pattern, err))
+		}
+	}
+	switch c.OwnedWorkloads {
+	case OwnedWorkloadsDefer, OwnedWorkloadsSkip, OwnedWorkloadsUpdate:
+	default:
+		problems = append(problems, fmt.Errorf("invalid owned workloads handling %q: expected %s, %s or %s", c.OwnedWorkloads, OwnedWorkloadsDefer, OwnedWorkloadsSkip, OwnedWorkloadsUpdate))
+	}
+	switch c.HelmWorkloads {
+	case HelmWorkloadsUpdate, HelmWorkloadsWarn, HelmWorkloadsSkip:
+	default:
+		problems = append(problems, fmt.Errorf("invalid Helm workloads handling %q: expected %s, %s or %s", c.HelmWorkloads, HelmWorkloadsUpdate, HelmWorkloadsWarn, HelmWorkloadsSkip))
+	}
+	if c.Promotion && len(c.PromotionStages) < 2 {
+		problems = append(problems, fmt.Errorf("promotion needs at least two stages, got %q", strings.Join(c.PromotionStages, ",")))
+	}
+	if c.Promotion && c.StateConfigMap == "" {
+		problems = append(problems, errors.New("promotion needs STATE_CONFIGMAP to remember the validated digests"))
+	}
+	for _, pattern := range c.SidecarImages {
+		if err := ValidatePattern(pattern); err != nil {
+			problems = append(problems, fmt.Errorf("invalid sidecar image pattern %q: %w", pattern, err))
+		}
+	}
+	if err := c.ImageFilter.Validate(); err != nil {
+		problems = append(problems, fmt.Errorf("invalid image filter: %w", err))
+	}
+	if c.NotificationURLSecret != "" {
+		if _, _, _, err := c.NotificationSecretRef(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if _, err := template.New("title").Parse(c.NotificationTitle); err != nil {
+		problems = append(problems, fmt.Errorf("invalid notification title: %w", err))
+	}
+	if _, err := template.New("footer").Parse(c.NotificationFooter); err != nil {
+		problems = append(problems, fmt.Errorf("invalid notification footer: %w", err))
+	}
+	if !IsUpdateMode(c.UpdateMode) {
+		problems = append(problems, fmt.Errorf("invalid update mode %q: expected %s, %s or %s", c.UpdateMode, UpdateModeDigest, UpdateModeTag, UpdateModeRestart))
+	}
+	for _, entry := range c.WorkloadNotifyAllow {
+		if _, host, found := strings.Cut(entry, "://"); found {
+			if err := ValidatePattern(host); err != nil {
+				problems = append(problems, fmt.Errorf("invalid workload notification host pattern %q: %w", entry, err))
+			}
+		}
+	}
+	if c.UpdateGroupDefault != "" && !slices.Contains(c.UpdateGroups, c.UpdateGroupDefault) {
+		problems = append(problems, fmt.Errorf("default update group %q is not in UPDATE_GROUPS", c.UpdateGroupDefault))
+	}
+	if c.RegistryAuth != RegistryAuthPullSecrets && c.RegistryAuth != RegistryAuthK8sChain {
+		problems = append(problems, fmt.Errorf("invalid registry auth %q: expected %s or %s", c.RegistryAuth, RegistryAuthPullSecrets, RegistryAuthK8sChain))
+	}
+	for registry, proxy := range c.RegistryProxies {
+		if proxy == "direct" {
+			continue
+		}
+		if proxyURL, err := url.Parse(proxy); err != nil || proxyURL.Host == "" {
+			problems = append(problems, fmt.Errorf("invalid proxy %q for registry %s", proxy, registry))
+		}
+	}
+	return problems
+}
+
+// NotificationSecretRef splits NotificationURLSecret into namespace, name and key
+func (c *Config) NotificationSecretRef() (string, string, string, error) {
+	parts := strings.Split(c.NotificationURLSecret, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid notification URL secret %q: expected namespace/name/key", c.NotificationURLSecret)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// GetNamespaceSelector returns the namespace label selector
+func (c *Config) GetNamespaceSelector() string {
+	return c.NamespaceSelector
 }
 
 // getEnv gets environment variable, returns default if not exists
@@ -88,23 +800,107 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-// getEnvBool gets boolean environment variable
-func getEnvBool(key string, defaultValue bool) bool {
+// getEnvList gets comma separated environment variable as a trimmed list
+func getEnvList(key string) []string {
+	return splitList(os.Getenv(key))
+}
+
+// splitList splits a comma separated string, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvMap gets a comma separated list of key=value pairs
+func getEnvMap(key string) map[string]string {
+	result := make(map[string]string)
+	for _, item := range getEnvList(key) {
+		k, v, _ := strings.Cut(item, "=")
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return result
+}
+
+// getBool gets boolean environment variable, the default if it is malformed
+func (e *envReader) getBool(key string, defaultValue bool) bool {
 	value := os.Getenv(key)
 	if value == "" {
 		return defaultValue
 	}
+	switch value {
+	case "true", "1", "yes", "false", "0", "no":
+	default:
+		e.problem(key, value, defaultValue)
+		return defaultValue
+	}
+	return parseBool(value)
+}
+
+// parseBool parses a boolean string value
+func parseBool(value string) bool {
 	return value == "true" || value == "1" || value == "yes"
 }
 
-// getEnvDuration gets duration environment variable
-func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+// getInt gets integer environment variable
+func (e *envReader) getInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		e.problem(key, value, defaultValue)
+		return defaultValue
+	}
+	return i
+}
+
+// getFloat gets float environment variable with default value
+func (e *envReader) getFloat(key string, defaultValue float32) float32 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		e.problem(key, value, defaultValue)
+		return defaultValue
+	}
+	return float32(f)
+}
+
+// serviceAccountNamespace returns the namespace of the mounted service account
+func serviceAccountNamespace() string {
+	data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		return "kube-watchtower"
+	}
+	if namespace := strings.TrimSpace(string(data)); namespace != "" {
+		return namespace
+	}
+	return "kube-watchtower"
+}
+
+// hostname returns the hostname, the pod name in a cluster
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+// getDuration gets duration environment variable
+func (e *envReader) getDuration(key string, defaultValue time.Duration) time.Duration {
 	value := os.Getenv(key)
 	if value == "" {
 		return defaultValue
 	}
 	duration, err := time.ParseDuration(value)
 	if err != nil {
+		e.problem(key, value, defaultValue)
 		return defaultValue
 	}
 	return duration
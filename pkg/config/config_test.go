@@ -0,0 +1,77 @@
+package config
+
+import "testing"
+
+func TestIsContainerDisabled(t *testing.T) {
+	tests := []struct {
+		name      string
+		disable   string
+		enable    string
+		container string
+		want      bool
+	}{
+		{"no lists", "", "", "app", false},
+
+		{"disabled by exact name", "istio-proxy,linkerd-proxy", "", "istio-proxy", true},
+		{"exact name does not match prefix", "istio-proxy", "", "istio-proxy-init", false},
+		{"disabled list with spaces", " istio-proxy , linkerd-proxy ", "", "linkerd-proxy", true},
+		{"not in disabled list", "istio-proxy", "", "app", false},
+		{"disabled by glob", "*-proxy", "", "envoy-proxy", true},
+		{"disabled glob mismatch", "*-proxy", "", "proxy-app", false},
+		{"disabled by regex", "/^sidecar-/", "", "sidecar-logs", true},
+
+		{"enabled by exact name", "", "app,worker", "worker", false},
+		{"not in enabled list", "", "app,worker", "cron", true},
+		{"enabled by glob", "", "app-*", "app-web", false},
+		{"enabled glob mismatch", "", "app-*", "web-app", true},
+
+		{"enabled list wins over disabled list", "app", "app", "app", false},
+		{"disabled list ignored with enabled list", "istio-proxy", "app", "istio-proxy", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DISABLE_CONTAINERS", tt.disable)
+			t.Setenv("ENABLE_CONTAINERS", tt.enable)
+
+			cfg := LoadConfig()
+			if got := cfg.IsContainerDisabled(tt.container); got != tt.want {
+				t.Errorf("IsContainerDisabled(%q) with DISABLE_CONTAINERS=%q ENABLE_CONTAINERS=%q = %v, want %v",
+					tt.container, tt.disable, tt.enable, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBool(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		defaultValue bool
+		want         bool
+		problem      bool
+	}{
+		{"unset uses default", "", true, true, false},
+		{"true", "true", false, true, false},
+		{"yes", "yes", false, true, false},
+		{"1", "1", false, true, false},
+		{"false", "false", true, false, false},
+		{"no", "no", true, false, false},
+		{"malformed keeps true default", "ture", true, true, true},
+		{"malformed keeps false default", "on", false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KUBE_WATCHTOWER_TEST_BOOL", tt.value)
+
+			env := &envReader{}
+			if got := env.getBool("KUBE_WATCHTOWER_TEST_BOOL", tt.defaultValue); got != tt.want {
+				t.Errorf("getBool(%q, %v) = %v, want %v", tt.value, tt.defaultValue, got, tt.want)
+			}
+			if problem := len(env.problems) > 0; problem != tt.problem {
+				t.Errorf("getBool(%q) reported problems %v, want problem %v", tt.value, env.problems, tt.problem)
+			}
+		})
+	}
+}
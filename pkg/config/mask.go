@@ -0,0 +1,63 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// secretMask replaces secret values
+const secretMask = "****"
+
+// Masked returns a copy of the configuration safe to print: tokens are replaced, notification URLs
+// keep only their service and other URLs lose their passwords and query strings (e.g. SAS tokens)
+func (c *Config) Masked() *Config {
+	masked := *c
+	masked.NotificationURL = maskServiceURL(c.NotificationURL)
+	masked.APIToken = maskValue(c.APIToken)
+	masked.APIReadToken = maskValue(c.APIReadToken)
+	masked.AuditLog = maskURL(c.AuditLog)
+	masked.PreUpdateWebhook = maskURL(c.PreUpdateWebhook)
+	masked.PostUpdateWebhook = maskURL(c.PostUpdateWebhook)
+	masked.PolicyURL = maskURL(c.PolicyURL)
+	masked.SyslogTarget = maskURL(c.SyslogTarget)
+
+	masked.RegistryProxies = make(map[string]string, len(c.RegistryProxies))
+	for registry, proxy := range c.RegistryProxies {
+		masked.RegistryProxies[registry] = maskURL(proxy)
+	}
+	return &masked
+}
+
+// maskValue masks a non-empty secret
+func maskValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return secretMask
+}
+
+// maskServiceURL keeps only the schemes of shoutrrr URLs (space separated), whose hosts and paths usually hold tokens
+func maskServiceURL(value string) string {
+	urls := strings.Fields(value)
+	for i, u := range urls {
+		scheme, _, found := strings.Cut(u, "://")
+		if !found {
+			urls[i] = maskValue(u)
+			continue
+		}
+		urls[i] = scheme + "://" + secretMask
+	}
+	return strings.Join(urls, " ")
+}
+
+// maskURL redacts the password and query string of a URL, other values are returned as is
+func maskURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" {
+		return value
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = secretMask
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// NamespaceConfig stores namespace-local policy read from an in-namespace ConfigMap.
+// Unset fields fall back to the global configuration.
+type NamespaceConfig struct {
+	// Whether the namespace is monitored at all (ENABLED)
+	Enabled *bool
+
+	// Detect updates without applying them (DRY_RUN / MONITOR_ONLY)
+	DryRun *bool
+
+	// Additional notification URL for this namespace (NOTIFICATION_URL)
+	NotificationURL string
+
+	// Only tags matching this pattern are checked (TAG_PATTERN)
+	TagPattern *regexp.Regexp
+
+	// Daily time window in which updates are applied (SCHEDULE, e.g. "22:00-06:00")
+	Schedule *UpdateWindow
+
+	// Workload name filter, applied in addition to the global one (INCLUDE_WORKLOADS / EXCLUDE_WORKLOADS)
+	WorkloadFilter Filter
+
+	// Image repository filter, applied in addition to the global one (INCLUDE_IMAGES / EXCLUDE_IMAGES)
+	ImageFilter Filter
+}
+
+// UpdateWindow is a daily time window, possibly spanning midnight
+type UpdateWindow struct {
+	Start time.Duration // Offset from midnight
+	End   time.Duration // Offset from midnight
+}
+
+// ParseNamespaceConfig parses the data of a namespace ConfigMap.
+// Keys use the same names as the global environment variables.
+func ParseNamespaceConfig(data map[string]string) (*NamespaceConfig, error) {
+	nc := &NamespaceConfig{}
+
+	if value, ok := lookup(data, "ENABLED"); ok {
+		enabled := parseBool(value)
+		nc.Enabled = &enabled
+	}
+
+	if value, ok := lookup(data, "DRY_RUN", "MONITOR_ONLY"); ok {
+		dryRun := parseBool(value)
+		nc.DryRun = &dryRun
+	}
+
+	if value, ok := lookup(data, "NOTIFICATION_URL"); ok {
+		// Files are written with the permissions of kube-watchtower, only the global configuration chooses them
+		for _, rawURL := range strings.Fields(value) {
+			if scheme, _, _ := strings.Cut(rawURL, "://"); strings.EqualFold(scheme, "file") {
+				return nil, fmt.Errorf("invalid NOTIFICATION_URL: file:// is only allowed in the global NOTIFICATION_URL")
+			}
+		}
+		nc.NotificationURL = value
+	}
+
+	if value, ok := lookup(data, "TAG_PATTERN"); ok {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TAG_PATTERN %q: %w", value, err)
+		}
+		nc.TagPattern = pattern
+	}
+
+	if value, ok := lookup(data, "SCHEDULE"); ok {
+		window, err := ParseUpdateWindow(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SCHEDULE %q: %w", value, err)
+		}
+		nc.Schedule = window
+	}
+
+	nc.WorkloadFilter = Filter{
+		Include: splitList(data["INCLUDE_WORKLOADS"]),
+		Exclude: splitList(data["EXCLUDE_WORKLOADS"]),
+	}
+	if err := nc.WorkloadFilter.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid workload filter: %w", err)
+	}
+
+	nc.ImageFilter = Filter{
+		Include: splitList(data["INCLUDE_IMAGES"]),
+		Exclude: splitList(data["EXCLUDE_IMAGES"]),
+	}
+	if err := nc.ImageFilter.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid image filter: %w", err)
+	}
+
+	return nc, nil
+}
+
+// ParseUpdateWindow parses a window in "HH:MM-HH:MM" format
+func ParseUpdateWindow(value string) (*UpdateWindow, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("expected HH:MM-HH:MM")
+	}
+
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateWindow{Start: start, End: end}, nil
+}
+
+// Contains checks if t falls inside the window (in t's location)
+func (w *UpdateWindow) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+	// Window within one day
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+
+	// Window spanning midnight
+	return offset >= w.Start || offset < w.End
+}
+
+// WithNamespaceConfig returns a copy of the configuration with namespace overrides applied
+func (c *Config) WithNamespaceConfig(nc *NamespaceConfig) *Config {
+	merged := *c
+	if nc == nil {
+		return &merged
+	}
+
+	if nc.DryRun != nil {
+		merged.DryRun = merged.DryRun || *nc.DryRun
+	}
+
+	return &merged
+}
+
+// IsEnabled checks if the namespace opted out via its ConfigMap
+func (nc *NamespaceConfig) IsEnabled() bool {
+	return nc == nil || nc.Enabled == nil || *nc.Enabled
+}
+
+// IsTagAllowed checks if a tag satisfies the namespace tag constraint
+func (nc *NamespaceConfig) IsTagAllowed(tag string) bool {
+	return nc == nil || nc.TagPattern == nil || nc.TagPattern.MatchString(tag)
+}
+
+// IsWorkloadAllowed checks if a workload name passes the namespace filter
+func (nc *NamespaceConfig) IsWorkloadAllowed(name string) bool {
+	return nc == nil || nc.WorkloadFilter.Allows(name)
+}
+
+// IsImageAllowed checks if an image repository passes the namespace filter
+func (nc *NamespaceConfig) IsImageAllowed(repository string) bool {
+	return nc == nil || nc.ImageFilter.Allows(repository)
+}
+
+// IsUpdateAllowed checks if updates may be applied at time t
+func (nc *NamespaceConfig) IsUpdateAllowed(t time.Time) bool {
+	return nc == nil || nc.Schedule == nil || nc.Schedule.Contains(t)
+}
+
+// lookup returns the first non-empty value among keys
+func lookup(data map[string]string, keys ...string) (string, bool) {
+	for _, key := range keys {
+		if value := strings.TrimSpace(data[key]); value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// parseClock parses "HH:MM" into an offset from midnight
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
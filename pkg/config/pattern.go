@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	regexCache   = make(map[string]*regexp.Regexp)
+	regexCacheMu sync.Mutex
+)
+
+// MatchPattern checks if name matches pattern
+// Supported patterns:
+//   - exact names: "production"
+//   - globs: "team-*", "app-?", "node-[0-9]", "app-[!a-z]*", "registry.internal/legacy/*" ("*" also matches "/")
+//   - regular expressions wrapped in slashes: "/^kube-.*/"
+func MatchPattern(pattern, name string) bool {
+	if isRegexPattern(pattern) {
+		re := compileRegex(pattern[1 : len(pattern)-1])
+		return re != nil && re.MatchString(name)
+	}
+
+	if isGlobPattern(pattern) {
+		expr, err := globToRegex(pattern)
+		if err != nil {
+			return false
+		}
+		re := compileRegex(expr)
+		return re != nil && re.MatchString(name)
+	}
+
+	return pattern == name
+}
+
+// MatchAnyPattern checks if name matches any of the patterns
+func MatchAnyPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if MatchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePattern checks if a pattern is well-formed
+func ValidatePattern(pattern string) error {
+	if isRegexPattern(pattern) {
+		_, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		return err
+	}
+	if isGlobPattern(pattern) {
+		expr, err := globToRegex(pattern)
+		if err != nil {
+			return err
+		}
+		_, err = regexp.Compile(expr)
+		return err
+	}
+	return nil
+}
+
+// Filter selects names by include and exclude patterns
+type Filter struct {
+	Include []string // If set, only matching names are allowed
+	Exclude []string // Matching names are never allowed
+}
+
+// Allows checks if name passes the filter
+func (f Filter) Allows(name string) bool {
+	if len(f.Include) > 0 && !MatchAnyPattern(f.Include, name) {
+		return false
+	}
+	return !MatchAnyPattern(f.Exclude, name)
+}
+
+// Validate checks all filter patterns
+func (f Filter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if err := ValidatePattern(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// isRegexPattern checks if pattern is a regular expression wrapped in slashes
+func isRegexPattern(pattern string) bool {
+	return len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// isGlobPattern checks if pattern contains glob wildcards or character classes
+func isGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// globToRegex converts a glob pattern into an anchored regular expression
+// Character classes hold characters and ranges ("[a-z0-9]"), negated by a leading "!" or "^";
+// a "]" right after the opening bracket is literal.
+func globToRegex(pattern string) (string, error) {
+	runes := []rune(pattern)
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '[':
+			end := i + 1
+			if end < len(runes) && (runes[end] == '!' || runes[end] == '^') {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				return "", fmt.Errorf("unterminated character class at offset %d", i)
+			}
+			sb.WriteString(globClassToRegex(runes[i+1 : end]))
+			i = end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String(), nil
+}
+
+// globClassToRegex converts the content of a glob character class into a regular expression class
+func globClassToRegex(class []rune) string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	if len(class) > 0 && (class[0] == '!' || class[0] == '^') {
+		sb.WriteString("^")
+		class = class[1:]
+	}
+	for _, r := range class {
+		switch r {
+		case '\\', '[', ']', '^':
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// compileRegex compiles and caches a regular expression, returns nil if invalid
+func compileRegex(expr string) *regexp.Regexp {
+	regexCacheMu.Lock()
+	defer regexCacheMu.Unlock()
+
+	if re, ok := regexCache[expr]; ok {
+		return re
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		re = nil
+	}
+	regexCache[expr] = re
+	return re
+}
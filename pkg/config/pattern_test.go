@@ -0,0 +1,108 @@
+package config
+
+import "testing"
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		input   string
+		want    bool
+	}{
+		{"exact match", "production", "production", true},
+		{"exact mismatch", "production", "production-eu", false},
+		{"exact is case sensitive", "production", "Production", false},
+		{"empty pattern", "", "production", false},
+
+		{"glob star suffix", "team-*", "team-a", true},
+		{"glob star matches empty", "team-*", "team-", true},
+		{"glob star prefix", "*-system", "kube-system", true},
+		{"glob star mismatch", "team-*", "teams", false},
+		{"glob star crosses slashes", "registry.internal/legacy/*", "registry.internal/legacy/app/web", true},
+		{"glob question mark", "app-?", "app-1", true},
+		{"glob question mark needs one char", "app-?", "app-", false},
+		{"glob question mark single char only", "app-?", "app-12", false},
+		{"glob is anchored", "app-*", "my-app-1", false},
+		{"glob quotes regex metacharacters", "app.*", "app.web", true},
+		{"glob dot is literal", "app.*", "appxweb", false},
+		{"glob class", "node-[abc]", "node-b", true},
+		{"glob class mismatch", "node-[abc]", "node-d", false},
+		{"glob class range", "node-[0-9]", "node-7", true},
+		{"glob class matches one char", "node-[0-9]", "node-12", false},
+		{"glob negated class", "app-[!0-9]*", "app-web", true},
+		{"glob negated class mismatch", "app-[!0-9]*", "app-1", false},
+		{"glob caret negated class", "app-[^0-9]", "app-1", false},
+		{"glob leading bracket is literal", "tag-[]]", "tag-]", true},
+		{"glob stray bracket is literal", "tag-]*", "tag-]1", true},
+		{"glob class quotes backslash", `path-[\]`, `path-\`, true},
+		{"unterminated class never matches", "node-[0-9", "node-[0-9", false},
+
+		{"regex match", "/^kube-.*/", "kube-system", true},
+		{"regex mismatch", "/^kube-.*/", "my-kube-system", false},
+		{"regex is unanchored", "/prod/", "eu-production", true},
+		{"regex alternation", "/^(dev|staging)$/", "staging", true},
+		{"single slash is exact", "/", "/", true},
+
+		{"invalid regex never matches", "/^(kube/", "kube", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchPattern(tt.pattern, tt.input); got != tt.want {
+				t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"production", false},
+		{"team-*", false},
+		{"app-?", false},
+		{"/^kube-.*/", false},
+		{"node-[0-9]", false},
+		{"app-[!a-z]*", false},
+		{"node-[0-9", true},
+		{"node-[z-a]", true},
+		{"/^(kube/", true},
+		{"/[a-/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			err := ValidatePattern(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePattern(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilterAllows(t *testing.T) {
+	filter := Filter{
+		Include: []string{"team-*", "/^shared-/"},
+		Exclude: []string{"team-legacy"},
+	}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"team-a", true},
+		{"shared-tools", true},
+		{"team-legacy", false},
+		{"default", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.Allows(tt.name); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// scaledObjectResource is the ScaledObject resource of KEDA
+var scaledObjectResource = schema.GroupVersionResource{Group: "keda.sh", Version: "v1alpha1", Resource: "scaledobjects"}
+
+// HPAStatus describes the scaling state of a HorizontalPodAutoscaler
+type HPAStatus struct {
+	Name            string
+	CurrentReplicas int32
+	DesiredReplicas int32
+	LastScaleTime   time.Time // Zero if the HPA never scaled
+}
+
+// GetHPAStatus returns the status of the HPA targeting a workload, or nil if there is none
+func (c *Client) GetHPAStatus(ctx context.Context, workloadType WorkloadType, namespace, name string) (*HPAStatus, error) {
+	hpas, err := c.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list horizontalpodautoscalers: %w", err)
+	}
+
+	for _, hpa := range hpas.Items {
+		ref := hpa.Spec.ScaleTargetRef
+		if ref.Kind != string(workloadType) || ref.Name != name {
+			continue
+		}
+
+		status := &HPAStatus{
+			Name:            hpa.Name,
+			CurrentReplicas: hpa.Status.CurrentReplicas,
+			DesiredReplicas: hpa.Status.DesiredReplicas,
+		}
+		if hpa.Status.LastScaleTime != nil {
+			status.LastScaleTime = hpa.Status.LastScaleTime.Time
+		}
+		return status, nil
+	}
+	return nil, nil
+}
+
+// ScaledObjectStatus describes the scaling state of a KEDA ScaledObject
+type ScaledObjectStatus struct {
+	Name        string
+	Active      bool  // A scaler is active, KEDA scales the workload from zero or keeps it running
+	Paused      bool  // Autoscaling is paused
+	MinReplicas int32 // 0 if KEDA scales the workload to zero
+}
+
+// GetScaledObjectStatus returns the status of the KEDA ScaledObject targeting a workload,
+// or nil if there is none or KEDA is not installed
+func (c *Client) GetScaledObjectStatus(ctx context.Context, workloadType WorkloadType, namespace, name string) (*ScaledObjectStatus, error) {
+	if c.restConfig == nil {
+		return nil, nil
+	}
+	dynamicClient, err := c.dynamic()
+	if err != nil {
+		return nil, err
+	}
+
+	scaledObjects, err := dynamicClient.Resource(scaledObjectResource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scaledobjects: %w", err)
+	}
+
+	for _, item := range scaledObjects.Items {
+		kind, _, _ := unstructured.NestedString(item.Object, "spec", "scaleTargetRef", "kind")
+		if kind == "" {
+			kind = string(WorkloadTypeDeployment)
+		}
+		target, _, _ := unstructured.NestedString(item.Object, "spec", "scaleTargetRef", "name")
+		if kind != string(workloadType) || target != name {
+			continue
+		}
+
+		status := &ScaledObjectStatus{Name: item.GetName()}
+		if minReplicas, found, _ := unstructured.NestedInt64(item.Object, "spec", "minReplicaCount"); found {
+			status.MinReplicas = int32(minReplicas)
+		}
+		conditions, _, _ := unstructured.NestedSlice(item.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["status"] != "True" {
+				continue
+			}
+			switch condition["type"] {
+			case "Active":
+				status.Active = true
+			case "Paused":
+				status.Paused = true
+			}
+		}
+		return status, nil
+	}
+	return nil, nil
+}
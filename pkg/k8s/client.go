@@ -6,12 +6,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/qetesh/kube-watchtower/pkg/logger"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -19,39 +24,139 @@ import (
 
 // Client Kubernetes client wrapper
 type Client struct {
-	clientset *kubernetes.Clientset
+	clientset  kubernetes.Interface
+	restConfig *rest.Config
+	pageSize   int64  // Objects per List call
+	context    string // kubeconfig context, empty in-cluster
+
+	scaledToZero bool // List Deployments and StatefulSets scaled to zero replicas
+	cronJobs     bool // List CronJobs
+
+	dynamicOnce   sync.Once
+	dynamicClient dynamic.Interface // For custom resources, created on first use by dynamic
+	dynamicErr    error
 }
 
 // NewClient creates a new Kubernetes client
 func NewClient() (*Client, error) {
-	config, err := getKubeConfig()
+	return NewClientForContext("")
+}
+
+// ClientOptions tunes the Kubernetes API client, zero values keep the client-go defaults
+type ClientOptions struct {
+	QPS       float32
+	Burst     int
+	UserAgent string
+	PageSize  int64 // Objects per List call (default: 500)
+
+	// List Deployments and StatefulSets scaled to zero replicas, whose images are only patched in the template
+	ScaledToZero bool
+
+	// List CronJobs, whose images are patched in the job template
+	CronJobs bool
+}
+
+// NewClientForContext creates a new Kubernetes client for a kubeconfig context
+// An empty context uses in-cluster config or the current kubeconfig context
+func NewClientForContext(kubeContext string) (*Client, error) {
+	return NewClientWithOptions(kubeContext, ClientOptions{})
+}
+
+// NewClientWithOptions creates a new Kubernetes client for a kubeconfig context with client options
+func NewClientWithOptions(kubeContext string, opts ClientOptions) (*Client, error) {
+	config, contextName, err := getKubeConfig(kubeContext)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
 	}
 
+	if opts.QPS > 0 {
+		config.QPS = opts.QPS
+	}
+	if opts.Burst > 0 {
+		config.Burst = opts.Burst
+	}
+	if opts.UserAgent != "" {
+		config.UserAgent = opts.UserAgent
+	}
+
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %w", err)
 	}
 
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
 	return &Client{
-		clientset: clientset,
+		clientset:  clientset,
+		restConfig: config,
+		pageSize:   pageSize,
+		context:    contextName,
+
+		scaledToZero: opts.ScaledToZero,
+		cronJobs:     opts.CronJobs,
 	}, nil
 }
 
-// getKubeConfig gets Kubernetes configuration
-func getKubeConfig() (*rest.Config, error) {
-	// Try in-cluster config first
-	config, err := rest.InClusterConfig()
-	if err == nil {
-		return config, nil
+// NewClientFromClientset wraps an existing clientset, e.g. of a program embedding kube-watchtower
+// restConfig is only needed to run hook commands in pods and may be nil.
+func NewClientFromClientset(clientset kubernetes.Interface, restConfig *rest.Config, opts ClientOptions) *Client {
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	return &Client{
+		clientset:  clientset,
+		restConfig: restConfig,
+		pageSize:   pageSize,
+
+		scaledToZero: opts.ScaledToZero,
+		cronJobs:     opts.CronJobs,
+	}
+}
+
+// dynamic returns the client for custom resources, created once and shared by all calls
+func (c *Client) dynamic() (dynamic.Interface, error) {
+	c.dynamicOnce.Do(func() {
+		if c.restConfig == nil {
+			c.dynamicErr = fmt.Errorf("custom resources need a REST config")
+			return
+		}
+		c.dynamicClient, c.dynamicErr = dynamic.NewForConfig(c.restConfig)
+		if c.dynamicErr != nil {
+			c.dynamicErr = fmt.Errorf("failed to create dynamic client: %w", c.dynamicErr)
+		}
+	})
+	return c.dynamicClient, c.dynamicErr
+}
+
+// getKubeConfig gets Kubernetes configuration and the name of the kubeconfig context used,
+// which is empty for the in-cluster config
+func getKubeConfig(kubeContext string) (*rest.Config, string, error) {
+	// Try in-cluster config first, unless a specific context is requested
+	if kubeContext == "" {
+		config, err := rest.InClusterConfig()
+		if err == nil {
+			return config, "", nil
+		}
 	}
 
 	// Fallback to kubeconfig file
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	configOverrides := &clientcmd.ConfigOverrides{}
+	configOverrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
 	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
-	return kubeConfig.ClientConfig()
+	config, err := kubeConfig.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	if kubeContext == "" {
+		if raw, err := kubeConfig.RawConfig(); err == nil {
+			kubeContext = raw.CurrentContext
+		}
+	}
+	return config, kubeContext, nil
 }
 
 // WorkloadType defines the type of Kubernetes workload
@@ -61,6 +166,7 @@ const (
 	WorkloadTypeDeployment  WorkloadType = "Deployment"
 	WorkloadTypeDaemonSet   WorkloadType = "DaemonSet"
 	WorkloadTypeStatefulSet WorkloadType = "StatefulSet"
+	WorkloadTypeCronJob     WorkloadType = "CronJob"
 )
 
 // WorkloadInfo contains workload information
@@ -70,6 +176,19 @@ type WorkloadInfo struct {
 	Namespace        string
 	Containers       []ContainerInfo
 	ImagePullSecrets []string // Names of image pull secrets
+	ServiceAccount   string   // Service account of the Pods
+	Annotations      map[string]string
+	Labels           map[string]string
+	PodAnnotations   map[string]string     // Annotations of the pod template
+	PodLabels        map[string]string     // Labels of the pod template
+	PodContainers    int                   // Containers of the pod template, whatever their pull policy
+	Owner            *Owner                // Controller owning the workload, nil if none
+	HelmRelease      string                // Helm release that installed the workload, empty if none
+	Selector         *metav1.LabelSelector // Pod label selector, nil for CronJobs
+	Replicas         int32                 // Desired replicas, scheduled pods for DaemonSets, 0 for CronJobs
+	OnDelete         bool                  // DaemonSet with the OnDelete update strategy
+	RollingOut       bool                  // DaemonSet whose rollout is not complete
+	Suspended        bool                  // Suspended CronJob, whose new image does not run until it is resumed
 }
 
 // ContainerInfo contains container information
@@ -77,85 +196,188 @@ type ContainerInfo struct {
 	Name            string
 	Image           string
 	ImagePullPolicy corev1.PullPolicy
-	CurrentDigest   string // Current running container image digest
-	Tag             string // Image tag
+	CurrentDigest   string         // Current running container image digest, the one most replicas run
+	RunningDigests  map[string]int // Running pods per image digest, more than one entry when replicas disagree
+	Tag             string         // Image tag
+	SkewOnly        bool           // IfNotPresent container of a DaemonSet, only checked for node cache skew
 }
 
 // NamespaceFilter defines namespace filtering logic
 type NamespaceFilter interface {
 	IsNamespaceAllowed(namespace string) bool
+	GetNamespaceSelector() string
+}
+
+// selectorFilter combines a NamespaceFilter with the namespaces matching its label selector
+type selectorFilter struct {
+	NamespaceFilter
+	selected map[string]bool
 }
 
-// ListWorkloads lists all workloads (Deployments, DaemonSets, StatefulSets) to monitor
+// IsNamespaceAllowed checks the wrapped filter and label selector membership
+func (f *selectorFilter) IsNamespaceAllowed(namespace string) bool {
+	return f.selected[namespace] && f.NamespaceFilter.IsNamespaceAllowed(namespace)
+}
+
+// ListWorkloads lists all workloads (Deployments, DaemonSets, StatefulSets and, if enabled, CronJobs) to monitor
 func (c *Client) ListWorkloads(ctx context.Context, nsFilter NamespaceFilter) ([]WorkloadInfo, error) {
 	// Always list all namespaces
 	namespace := corev1.NamespaceAll
 
 	var result []WorkloadInfo
 
-	// List Deployments
-	deployments, err := c.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	// Resolve namespace label selector
+	if nsFilter != nil && nsFilter.GetNamespaceSelector() != "" {
+		selected, err := c.ListNamespacesBySelector(ctx, nsFilter.GetNamespaceSelector())
+		if err != nil {
+			return nil, err
+		}
+		logger.Debugf("Namespace selector %q matched %d namespaces", nsFilter.GetNamespaceSelector(), len(selected))
+		nsFilter = &selectorFilter{NamespaceFilter: nsFilter, selected: selected}
 	}
-	for _, deploy := range deployments.Items {
-		// Only process deployments with available replicas
-		if deploy.Status.AvailableReplicas <= 0 {
-			logger.Debugf("Skipping deployment: %s/%s (available replicas: %d)", deploy.Namespace, deploy.Name, deploy.Status.AvailableReplicas)
-			continue
+
+	// List Deployments
+	err := c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+		deployments, err := c.clientset.AppsV1().Deployments(namespace).List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list deployments: %w", err)
 		}
-		if workload := c.processWorkload(ctx, WorkloadTypeDeployment, deploy.Name, deploy.Namespace, &deploy.Spec.Template.Spec, deploy.Spec.Selector, nsFilter); workload != nil {
-			result = append(result, *workload)
+		for _, deploy := range deployments.Items {
+			// Only process deployments with available replicas, or scaled to zero if enabled
+			if deploy.Status.AvailableReplicas <= 0 && !c.isScaledToZero(deploy.Spec.Replicas) {
+				logger.Debugf("Skipping deployment: %s/%s (available replicas: %d)", deploy.Namespace, deploy.Name, deploy.Status.AvailableReplicas)
+				continue
+			}
+			if workload := c.processWorkload(ctx, WorkloadTypeDeployment, &deploy.ObjectMeta, &deploy.Spec.Template, deploy.Spec.Selector, desiredReplicas(deploy.Spec.Replicas), nsFilter); workload != nil {
+				result = append(result, *workload)
+			}
 		}
+		return deployments.Continue, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// List DaemonSets
-	daemonsets, err := c.clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
-	}
-	for _, ds := range daemonsets.Items {
-		// Only process daemonsets with available replicas
-		if ds.Status.NumberAvailable <= 0 {
-			logger.Debugf("Skipping daemonset: %s/%s (available replicas: %d)", ds.Namespace, ds.Name, ds.Status.NumberAvailable)
-			continue
+	err = c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+		daemonsets, err := c.clientset.AppsV1().DaemonSets(namespace).List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list daemonsets: %w", err)
 		}
-		if workload := c.processWorkload(ctx, WorkloadTypeDaemonSet, ds.Name, ds.Namespace, &ds.Spec.Template.Spec, ds.Spec.Selector, nsFilter); workload != nil {
-			result = append(result, *workload)
+		for _, ds := range daemonsets.Items {
+			// Only process daemonsets with available replicas
+			if ds.Status.NumberAvailable <= 0 {
+				logger.Debugf("Skipping daemonset: %s/%s (available replicas: %d)", ds.Namespace, ds.Name, ds.Status.NumberAvailable)
+				continue
+			}
+			if workload := c.processWorkload(ctx, WorkloadTypeDaemonSet, &ds.ObjectMeta, &ds.Spec.Template, ds.Spec.Selector, ds.Status.DesiredNumberScheduled, nsFilter); workload != nil {
+				setDaemonSetRollout(workload, &ds)
+				result = append(result, *workload)
+			}
 		}
+		return daemonsets.Continue, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// List StatefulSets
-	statefulsets, err := c.clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	err = c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+		statefulsets, err := c.clientset.AppsV1().StatefulSets(namespace).List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list statefulsets: %w", err)
+		}
+		for _, sts := range statefulsets.Items {
+			// Only process statefulsets with available replicas, or scaled to zero if enabled
+			if sts.Status.AvailableReplicas <= 0 && !c.isScaledToZero(sts.Spec.Replicas) {
+				logger.Debugf("Skipping statefulset: %s/%s (available replicas: %d)", sts.Namespace, sts.Name, sts.Status.AvailableReplicas)
+				continue
+			}
+			if workload := c.processWorkload(ctx, WorkloadTypeStatefulSet, &sts.ObjectMeta, &sts.Spec.Template, sts.Spec.Selector, desiredReplicas(sts.Spec.Replicas), nsFilter); workload != nil {
+				result = append(result, *workload)
+			}
+		}
+		return statefulsets.Continue, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+		return nil, err
 	}
-	for _, sts := range statefulsets.Items {
-		// Only process statefulsets with available replicas
-		if sts.Status.AvailableReplicas <= 0 {
-			logger.Debugf("Skipping statefulset: %s/%s (available replicas: %d)", sts.Namespace, sts.Name, sts.Status.AvailableReplicas)
-			continue
-		}
-		if workload := c.processWorkload(ctx, WorkloadTypeStatefulSet, sts.Name, sts.Namespace, &sts.Spec.Template.Spec, sts.Spec.Selector, nsFilter); workload != nil {
-			result = append(result, *workload)
+
+	// List CronJobs, which have no long-running pods: only the job template is patched
+	if c.cronJobs {
+		err = c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+			cronjobs, err := c.clientset.BatchV1().CronJobs(namespace).List(ctx, opts)
+			if err != nil {
+				return "", fmt.Errorf("failed to list cronjobs: %w", err)
+			}
+			for _, cj := range cronjobs.Items {
+				if workload := c.processCronJob(ctx, &cj, nsFilter); workload != nil {
+					result = append(result, *workload)
+				}
+			}
+			return cronjobs.Continue, nil
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	return result, nil
 }
 
+// processCronJob processes a CronJob, whose containers are those of its job template
+func (c *Client) processCronJob(ctx context.Context, cronjob *batchv1.CronJob, nsFilter NamespaceFilter) *WorkloadInfo {
+	workload := c.processWorkload(ctx, WorkloadTypeCronJob, &cronjob.ObjectMeta, &cronjob.Spec.JobTemplate.Spec.Template, nil, 0, nsFilter)
+	if workload != nil {
+		workload.Suspended = cronjob.Spec.Suspend != nil && *cronjob.Spec.Suspend
+	}
+	return workload
+}
+
+// ListNamespacesBySelector lists the names of namespaces matching a label selector
+func (c *Client) ListNamespacesBySelector(ctx context.Context, selector string) (map[string]bool, error) {
+	if _, err := labels.Parse(selector); err != nil {
+		return nil, fmt.Errorf("invalid namespace selector %q: %w", selector, err)
+	}
+
+	namespaces, err := c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	selected := make(map[string]bool, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		selected[ns.Name] = true
+	}
+	return selected, nil
+}
+
+// GetNamespaceAnnotations returns the annotations of a namespace
+func (c *Client) GetNamespaceAnnotations(ctx context.Context, name string) (map[string]string, error) {
+	namespace, err := c.clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get namespace: %w", err)
+	}
+	return namespace.Annotations, nil
+}
+
 // processWorkload processes a workload and extracts container information
-func (c *Client) processWorkload(ctx context.Context, workloadType WorkloadType, name, namespace string, podSpec *corev1.PodSpec, selector *metav1.LabelSelector, nsFilter NamespaceFilter) *WorkloadInfo {
+func (c *Client) processWorkload(ctx context.Context, workloadType WorkloadType, meta *metav1.ObjectMeta, template *corev1.PodTemplateSpec, selector *metav1.LabelSelector, replicas int32, nsFilter NamespaceFilter) *WorkloadInfo {
+	name, namespace := meta.Name, meta.Namespace
+	podSpec := &template.Spec
+
 	// Check if namespace is allowed
 	if nsFilter != nil && !nsFilter.IsNamespaceAllowed(namespace) {
 		logger.Debugf("Skipping namespace: %s (filtered)", namespace)
 		return nil
 	}
 
-	// Extract containers with Always pull policy
+	// Extract containers with Always pull policy, and those of DaemonSets for the digest skew check
 	var containers []ContainerInfo
 	for _, container := range podSpec.Containers {
-		if container.ImagePullPolicy == corev1.PullAlways {
+		if container.ImagePullPolicy == corev1.PullAlways || workloadType == WorkloadTypeDaemonSet {
 			tag := extractImageTag(container.Image)
 
 			containers = append(containers, ContainerInfo{
@@ -163,6 +385,7 @@ func (c *Client) processWorkload(ctx context.Context, workloadType WorkloadType,
 				Image:           container.Image,
 				ImagePullPolicy: container.ImagePullPolicy,
 				Tag:             tag,
+				SkewOnly:        container.ImagePullPolicy != corev1.PullAlways,
 			})
 		} else {
 			logger.Debugf("Skipping container: %s/%s (image pull policy: %s)", namespace, name, container.ImagePullPolicy)
@@ -173,9 +396,11 @@ func (c *Client) processWorkload(ctx context.Context, workloadType WorkloadType,
 		return nil
 	}
 
-	// Get actual running pod info and extract current digest
-	if err := c.fillCurrentDigestsFromSelector(ctx, namespace, selector, containers); err != nil {
-		logger.Debugf("Warning: unable to get current digest for %s/%s: %v", namespace, name, err)
+	// Get actual running pod info and extract current digest, CronJobs have no selector
+	if selector != nil {
+		if err := c.fillCurrentDigestsFromSelector(ctx, namespace, selector, containers); err != nil {
+			logger.Debugf("Warning: unable to get current digest for %s/%s: %v", namespace, name, err)
+		}
 	}
 
 	// Extract ImagePullSecrets
@@ -190,9 +415,32 @@ func (c *Client) processWorkload(ctx context.Context, workloadType WorkloadType,
 		Namespace:        namespace,
 		Containers:       containers,
 		ImagePullSecrets: imagePullSecrets,
+		ServiceAccount:   podSpec.ServiceAccountName,
+		Annotations:      meta.Annotations,
+		Labels:           meta.Labels,
+		PodAnnotations:   template.Annotations,
+		PodLabels:        template.Labels,
+		PodContainers:    len(podSpec.Containers),
+		Owner:            getOwner(meta),
+		HelmRelease:      getHelmRelease(meta),
+		Selector:         selector,
+		Replicas:         replicas,
 	}
 }
 
+// isScaledToZero checks if a Deployment or StatefulSet with the spec replicas is scaled to zero and should be listed
+func (c *Client) isScaledToZero(replicas *int32) bool {
+	return c.scaledToZero && desiredReplicas(replicas) == 0
+}
+
+// desiredReplicas returns the replicas of a Deployment or StatefulSet spec, which default to 1
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
 // ListDeployments lists all deployments to monitor (deprecated, use ListWorkloads)
 func (c *Client) ListDeployments(ctx context.Context) ([]WorkloadInfo, error) {
 	return c.ListWorkloads(ctx, nil)
@@ -205,10 +453,9 @@ func extractImageTag(image string) string {
 		image = image[:idx]
 	}
 
-	// Extract tag
-	parts := strings.Split(image, ":")
-	if len(parts) > 1 {
-		return parts[len(parts)-1]
+	// Extract tag, a ":" before the last "/" is a registry port
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
 	}
 
 	return "latest" // Default tag
@@ -230,69 +477,106 @@ func extractDigestFromImageID(imageID string) string {
 
 // fillCurrentDigestsFromSelector fills container current digest information using label selector
 func (c *Client) fillCurrentDigestsFromSelector(ctx context.Context, namespace string, selector *metav1.LabelSelector, containers []ContainerInfo) error {
-	// Get pods using label selector
-	labelSelector := metav1.FormatLabelSelector(selector)
-	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: labelSelector,
+	// Count the digests of every running pod, mid-rollout or with node cache skew replicas may disagree
+	counts := make(map[string]map[string]int) // Container name -> digest -> pods
+	found := false
+	err := c.listPages(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(selector),
+		FieldSelector: runningPodFieldSelector,
+	}, func(opts metav1.ListOptions) (string, error) {
+		pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list pods: %w", err)
+		}
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			found = true
+			for _, status := range pod.Status.ContainerStatuses {
+				digest := extractDigestFromImageID(status.ImageID)
+				if digest == "" {
+					continue
+				}
+				if counts[status.Name] == nil {
+					counts[status.Name] = make(map[string]int)
+				}
+				counts[status.Name][digest]++
+			}
+		}
+		return pods.Continue, nil
 	})
 	if err != nil {
-		return fmt.Errorf("failed to list pods: %w", err)
+		return err
 	}
 
-	if len(pods.Items) == 0 {
-		return fmt.Errorf("no pods found")
+	if !found {
+		return fmt.Errorf("no running pods found")
 	}
 
-	// Use first running pod
-	var selectedPod *corev1.Pod
-	for i := range pods.Items {
-		if pods.Items[i].Status.Phase == corev1.PodRunning {
-			selectedPod = &pods.Items[i]
-			break
+	// Fill digest information with the majority digest
+	for i := range containers {
+		if digests, ok := counts[containers[i].Name]; ok {
+			containers[i].RunningDigests = digests
+			containers[i].CurrentDigest = majorityDigest(digests)
 		}
 	}
 
-	if selectedPod == nil {
-		return fmt.Errorf("no running pods found")
-	}
-
-	// Create container name to status mapping
-	containerStatusMap := make(map[string]string)
-	for _, status := range selectedPod.Status.ContainerStatuses {
-		containerStatusMap[status.Name] = status.ImageID
-	}
+	return nil
+}
 
-	// Fill digest information
-	for i := range containers {
-		if imageID, ok := containerStatusMap[containers[i].Name]; ok {
-			containers[i].CurrentDigest = extractDigestFromImageID(imageID)
+// majorityDigest returns the digest run by most pods, ties go to the lowest digest
+func majorityDigest(counts map[string]int) string {
+	majority := ""
+	for digest, count := range counts {
+		if majority == "" || count > counts[majority] || (count == counts[majority] && digest < majority) {
+			majority = digest
 		}
 	}
+	return majority
+}
 
-	return nil
+// Annotations written by kube-watchtower
+const (
+	AnnotationUpdatedAt            = "kube-watchtower.io/updated-at"
+	AnnotationRestartedAt          = "kubectl.kubernetes.io/restartedAt" // Same annotation as kubectl rollout restart
+	AnnotationPreviousImagePrefix  = "kube-watchtower.io/previous-image."
+	AnnotationPreviousDigestPrefix = "kube-watchtower.io/previous-digest."
+)
+
+// PreviousImageAnnotation returns the annotation key holding a container's previous image
+func PreviousImageAnnotation(containerName string) string {
+	return containerAnnotation(AnnotationPreviousImagePrefix, containerName)
 }
 
-// UpdateWorkloadImage updates workload image
-func (c *Client) UpdateWorkloadImage(ctx context.Context, workloadType WorkloadType, namespace, name, containerName, newImage string) error {
-	annotation := map[string]string{
-		"kube-watchtower.io/updated-at": time.Now().Format(time.RFC3339),
+// PreviousDigestAnnotation returns the annotation key holding a container's previous digest
+func PreviousDigestAnnotation(containerName string) string {
+	return containerAnnotation(AnnotationPreviousDigestPrefix, containerName)
+}
+
+// containerAnnotation builds a per-container annotation key,
+// truncating the container name to the 63 character limit of the key name
+func containerAnnotation(prefix, containerName string) string {
+	slash := strings.Index(prefix, "/")
+	maxLen := 63 - (len(prefix) - slash - 1)
+	if len(containerName) > maxLen {
+		containerName = containerName[:maxLen]
 	}
+	return prefix + containerName
+}
 
+// UpdateWorkloadImage updates workload image
+// The replaced image and its running digest (if known) are recorded in annotations
+func (c *Client) UpdateWorkloadImage(ctx context.Context, workloadType WorkloadType, namespace, name, containerName, newImage, previousDigest string) error {
 	switch workloadType {
 	case WorkloadTypeDeployment:
 		deployment, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
 		if err != nil {
 			return fmt.Errorf("failed to get deployment: %w", err)
 		}
-		if err := updateContainerImage(&deployment.Spec.Template.Spec, containerName, newImage); err != nil {
+		if err := updatePodTemplate(&deployment.Spec.Template, containerName, newImage, previousDigest); err != nil {
 			return err
 		}
-		if deployment.Spec.Template.Annotations == nil {
-			deployment.Spec.Template.Annotations = make(map[string]string)
-		}
-		for k, v := range annotation {
-			deployment.Spec.Template.Annotations[k] = v
-		}
 		_, err = c.clientset.AppsV1().Deployments(namespace).Update(ctx, deployment, metav1.UpdateOptions{})
 		return err
 
@@ -301,15 +585,9 @@ func (c *Client) UpdateWorkloadImage(ctx context.Context, workloadType WorkloadT
 		if err != nil {
 			return fmt.Errorf("failed to get daemonset: %w", err)
 		}
-		if err := updateContainerImage(&daemonset.Spec.Template.Spec, containerName, newImage); err != nil {
+		if err := updatePodTemplate(&daemonset.Spec.Template, containerName, newImage, previousDigest); err != nil {
 			return err
 		}
-		if daemonset.Spec.Template.Annotations == nil {
-			daemonset.Spec.Template.Annotations = make(map[string]string)
-		}
-		for k, v := range annotation {
-			daemonset.Spec.Template.Annotations[k] = v
-		}
 		_, err = c.clientset.AppsV1().DaemonSets(namespace).Update(ctx, daemonset, metav1.UpdateOptions{})
 		return err
 
@@ -318,16 +596,21 @@ func (c *Client) UpdateWorkloadImage(ctx context.Context, workloadType WorkloadT
 		if err != nil {
 			return fmt.Errorf("failed to get statefulset: %w", err)
 		}
-		if err := updateContainerImage(&statefulset.Spec.Template.Spec, containerName, newImage); err != nil {
+		if err := updatePodTemplate(&statefulset.Spec.Template, containerName, newImage, previousDigest); err != nil {
 			return err
 		}
-		if statefulset.Spec.Template.Annotations == nil {
-			statefulset.Spec.Template.Annotations = make(map[string]string)
+		_, err = c.clientset.AppsV1().StatefulSets(namespace).Update(ctx, statefulset, metav1.UpdateOptions{})
+		return err
+
+	case WorkloadTypeCronJob:
+		cronjob, err := c.clientset.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get cronjob: %w", err)
 		}
-		for k, v := range annotation {
-			statefulset.Spec.Template.Annotations[k] = v
+		if err := updatePodTemplate(&cronjob.Spec.JobTemplate.Spec.Template, containerName, newImage, previousDigest); err != nil {
+			return err
 		}
-		_, err = c.clientset.AppsV1().StatefulSets(namespace).Update(ctx, statefulset, metav1.UpdateOptions{})
+		_, err = c.clientset.BatchV1().CronJobs(namespace).Update(ctx, cronjob, metav1.UpdateOptions{})
 		return err
 
 	default:
@@ -335,6 +618,36 @@ func (c *Client) UpdateWorkloadImage(ctx context.Context, workloadType WorkloadT
 	}
 }
 
+// updatePodTemplate sets a container image and records the update in template annotations
+// An unchanged image restarts the pods, like kubectl rollout restart
+func updatePodTemplate(template *corev1.PodTemplateSpec, containerName, newImage, previousDigest string) error {
+	previousImage := ""
+	for _, container := range template.Spec.Containers {
+		if container.Name == containerName {
+			previousImage = container.Image
+		}
+	}
+
+	if err := updateContainerImage(&template.Spec, containerName, newImage); err != nil {
+		return err
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	template.Annotations[AnnotationUpdatedAt] = time.Now().Format(time.RFC3339)
+	if previousImage == newImage {
+		template.Annotations[AnnotationRestartedAt] = time.Now().Format(time.RFC3339)
+	}
+	template.Annotations[PreviousImageAnnotation(containerName)] = previousImage
+	if previousDigest != "" {
+		template.Annotations[PreviousDigestAnnotation(containerName)] = previousDigest
+	} else {
+		delete(template.Annotations, PreviousDigestAnnotation(containerName))
+	}
+	return nil
+}
+
 // updateContainerImage updates container image in pod spec
 func updateContainerImage(podSpec *corev1.PodSpec, containerName, newImage string) error {
 	for i := range podSpec.Containers {
@@ -346,9 +659,33 @@ func updateContainerImage(podSpec *corev1.PodSpec, containerName, newImage strin
 	return fmt.Errorf("container %s not found", containerName)
 }
 
+// PreviousImage is the image recorded before the last update of a container
+type PreviousImage struct {
+	Container string
+	Image     string
+	Digest    string
+}
+
+// GetPreviousImages returns the previous images recorded in a pod template's annotations
+func GetPreviousImages(template *corev1.PodTemplateSpec) []PreviousImage {
+	var previous []PreviousImage
+	for _, container := range template.Spec.Containers {
+		image, ok := template.Annotations[PreviousImageAnnotation(container.Name)]
+		if !ok || image == "" {
+			continue
+		}
+		previous = append(previous, PreviousImage{
+			Container: container.Name,
+			Image:     image,
+			Digest:    template.Annotations[PreviousDigestAnnotation(container.Name)],
+		})
+	}
+	return previous
+}
+
 // UpdateDeploymentImage updates deployment image (deprecated, use UpdateWorkloadImage)
 func (c *Client) UpdateDeploymentImage(ctx context.Context, namespace, deploymentName, containerName, newImage string) error {
-	return c.UpdateWorkloadImage(ctx, WorkloadTypeDeployment, namespace, deploymentName, containerName, newImage)
+	return c.UpdateWorkloadImage(ctx, WorkloadTypeDeployment, namespace, deploymentName, containerName, newImage, "")
 }
 
 // WaitForRollout waits for workload rollout to complete
@@ -399,6 +736,10 @@ func (c *Client) isRolloutComplete(ctx context.Context, workloadType WorkloadTyp
 		}
 		return isStatefulSetRolloutComplete(statefulset), nil
 
+	case WorkloadTypeCronJob:
+		// Nothing rolls out, the next Job runs the new image
+		return true, nil
+
 	default:
 		return false, fmt.Errorf("unsupported workload type: %s", workloadType)
 	}
@@ -421,6 +762,12 @@ func isDeploymentRolloutComplete(deployment *appsv1.Deployment) bool {
 	return false
 }
 
+// setDaemonSetRollout records the update strategy and rollout state of a DaemonSet
+func setDaemonSetRollout(workload *WorkloadInfo, daemonset *appsv1.DaemonSet) {
+	workload.OnDelete = daemonset.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType
+	workload.RollingOut = !isDaemonSetRolloutComplete(daemonset)
+}
+
 // isDaemonSetRolloutComplete checks if daemonset rollout is complete
 func isDaemonSetRolloutComplete(daemonset *appsv1.DaemonSet) bool {
 	if daemonset.Generation <= daemonset.Status.ObservedGeneration {
@@ -450,12 +797,48 @@ func isStatefulSetRolloutComplete(statefulset *appsv1.StatefulSet) bool {
 	return false
 }
 
-// getOwnerName gets the owner name from owner references
-func getOwnerName(ownerRefs []metav1.OwnerReference) string {
-	if len(ownerRefs) > 0 {
-		return ownerRefs[0].Name
+// GetConfigMapData retrieves the data of a ConfigMap, returns nil if it does not exist
+func (c *Client) GetConfigMapData(ctx context.Context, namespace, name string) (map[string]string, error) {
+	configMap, err := c.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get configmap: %w", err)
+	}
+	return configMap.Data, nil
+}
+
+// SaveConfigMapData creates or replaces the data of a ConfigMap
+func (c *Client) SaveConfigMapData(ctx context.Context, namespace, name string, data map[string]string) error {
+	configMaps := c.clientset.CoreV1().ConfigMaps(namespace)
+
+	configMap, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get configmap: %w", err)
+		}
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "kube-watchtower",
+				},
+			},
+			Data: data,
+		}
+		if _, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create configmap: %w", err)
+		}
+		return nil
+	}
+
+	configMap.Data = data
+	if _, err := configMaps.Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update configmap: %w", err)
 	}
-	return ""
+	return nil
 }
 
 // DockerConfigJSON represents the structure of .dockerconfigjson
@@ -468,6 +851,8 @@ type DockerAuthConfig struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Auth     string `json:"auth"`
+
+	RegistryToken string `json:"registrytoken,omitempty"` // Bearer token, used instead of username/password
 }
 
 // RegistryAuth contains registry authentication information
@@ -475,6 +860,38 @@ type RegistryAuth struct {
 	Registry string
 	Username string
 	Password string
+	Token    string // Bearer token
+}
+
+// GetSecretValue returns one key of a Secret
+func (c *Client) GetSecretValue(ctx context.Context, namespace, name, key string) (string, error) {
+	secret, err := c.clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret: %w", err)
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s does not contain %s", namespace, name, key)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// GetServiceAccountPullSecrets returns the imagePullSecrets of a service account ("default" if empty),
+// which the kubelet uses for the Pods of the service account in addition to their own
+func (c *Client) GetServiceAccountPullSecrets(ctx context.Context, namespace, serviceAccount string) ([]string, error) {
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	sa, err := c.clientset.CoreV1().ServiceAccounts(namespace).Get(ctx, serviceAccount, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+
+	secrets := make([]string, 0, len(sa.ImagePullSecrets))
+	for _, ref := range sa.ImagePullSecrets {
+		secrets = append(secrets, ref.Name)
+	}
+	return secrets, nil
 }
 
 // GetImagePullSecret retrieves and parses an image pull secret
@@ -495,8 +912,13 @@ func (c *Client) GetImagePullSecret(ctx context.Context, namespace, secretName s
 		return nil, fmt.Errorf("secret %s does not contain .dockerconfigjson", secretName)
 	}
 
+	return ParseDockerConfig(dockerConfigData)
+}
+
+// ParseDockerConfig parses a .dockerconfigjson / config.json document
+func ParseDockerConfig(data []byte) ([]RegistryAuth, error) {
 	var dockerConfig DockerConfigJSON
-	if err := json.Unmarshal(dockerConfigData, &dockerConfig); err != nil {
+	if err := json.Unmarshal(data, &dockerConfig); err != nil {
 		return nil, fmt.Errorf("failed to parse docker config: %w", err)
 	}
 
@@ -524,6 +946,7 @@ func (c *Client) GetImagePullSecret(ctx context.Context, namespace, secretName s
 			Registry: registry,
 			Username: username,
 			Password: password,
+			Token:    authConfig.RegistryToken,
 		})
 	}
 
@@ -0,0 +1,124 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// DefaultClusterName is used when no cluster name can be detected
+const DefaultClusterName = "kubernetes"
+
+// clusterNameLabels are node labels set to the cluster name by managed platforms and installers
+var clusterNameLabels = []string{
+	"alpha.eksctl.io/cluster-name",
+	"cluster.x-k8s.io/cluster-name",
+	"kubernetes.azure.com/cluster",
+}
+
+// DetectClusterName derives a name for the cluster, tried in order:
+// the kubeconfig context name, the cluster of the kube-public/cluster-info ConfigMap,
+// well-known node labels, the UID of the kube-system namespace and a hash of the API server URL.
+func (c *Client) DetectClusterName(ctx context.Context) string {
+	if c.context != "" {
+		return c.context
+	}
+	if name := c.clusterInfoName(ctx); name != "" {
+		return name
+	}
+	if name := c.nodeLabelClusterName(ctx); name != "" {
+		return name
+	}
+	namespace, err := c.clientset.CoreV1().Namespaces().Get(ctx, "kube-system", metav1.GetOptions{})
+	if err == nil && namespace.UID != "" {
+		return "cluster-" + shortID(string(namespace.UID))
+	}
+	logger.Debugf("Failed to get kube-system namespace for the cluster name: %v", err)
+	if c.restConfig != nil && c.restConfig.Host != "" {
+		sum := sha256.Sum256([]byte(c.restConfig.Host))
+		return "cluster-" + shortID(hex.EncodeToString(sum[:]))
+	}
+	return DefaultClusterName
+}
+
+// clusterInfoName returns the cluster name of the kubeconfig published in kube-public/cluster-info
+// kubeadm leaves it empty, other installers set it
+func (c *Client) clusterInfoName(ctx context.Context) string {
+	configMap, err := c.clientset.CoreV1().ConfigMaps("kube-public").Get(ctx, "cluster-info", metav1.GetOptions{})
+	if err != nil {
+		logger.Debugf("Failed to get kube-public/cluster-info for the cluster name: %v", err)
+		return ""
+	}
+	if name := configMap.Data["cluster-name"]; name != "" {
+		return name
+	}
+	kubeconfig, err := clientcmd.Load([]byte(configMap.Data["kubeconfig"]))
+	if err != nil {
+		return ""
+	}
+	names := make([]string, 0, len(kubeconfig.Clusters))
+	for name := range kubeconfig.Clusters {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// nodeLabelClusterName returns the cluster name from the labels of a node
+func (c *Client) nodeLabelClusterName(ctx context.Context) string {
+	nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		logger.Debugf("Failed to list nodes for the cluster name: %v", err)
+		return ""
+	}
+	for _, node := range nodes.Items {
+		for _, label := range clusterNameLabels {
+			if name := node.Labels[label]; name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// shortID shortens a UID or hash to a readable identifier
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// ServerVersion returns the version of the API server, e.g. v1.31.2
+func (c *Client) ServerVersion() (string, error) {
+	info, err := c.clientset.Discovery().ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to get server version: %w", err)
+	}
+	return info.GitVersion, nil
+}
+
+// CountNodes returns the number of nodes of the cluster
+func (c *Client) CountNodes(ctx context.Context) (int, error) {
+	count := 0
+	err := c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+		nodes, err := c.clientset.CoreV1().Nodes().List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list nodes: %w", err)
+		}
+		count += len(nodes.Items)
+		return nodes.Continue, nil
+	})
+	return count, err
+}
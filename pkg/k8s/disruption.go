@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PDBStatus describes the disruption budget left by a PodDisruptionBudget
+type PDBStatus struct {
+	Name               string
+	DisruptionsAllowed int32
+	CurrentHealthy     int32
+	DesiredHealthy     int32
+}
+
+// GetPDBStatuses returns the status of the PodDisruptionBudgets covering the pods matching selector
+func (c *Client) GetPDBStatuses(ctx context.Context, namespace string, selector *metav1.LabelSelector) ([]PDBStatus, error) {
+	pdbs, err := c.clientset.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list poddisruptionbudgets: %w", err)
+	}
+	if len(pdbs.Items) == 0 {
+		return nil, nil
+	}
+
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(selector),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var statuses []PDBStatus
+	for _, pdb := range pdbs.Items {
+		// A nil selector selects no pods, an empty one all pods of the namespace
+		if pdb.Spec.Selector == nil {
+			continue
+		}
+		pdbSelector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			continue
+		}
+		for _, pod := range pods.Items {
+			if pdbSelector.Matches(labels.Set(pod.Labels)) {
+				statuses = append(statuses, PDBStatus{
+					Name:               pdb.Name,
+					DisruptionsAllowed: pdb.Status.DisruptionsAllowed,
+					CurrentHealthy:     pdb.Status.CurrentHealthy,
+					DesiredHealthy:     pdb.Status.DesiredHealthy,
+				})
+				break
+			}
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RecordWorkloadEvent creates an Event on a workload, shown by kubectl describe
+func (c *Client) RecordWorkloadEvent(ctx context.Context, workloadType WorkloadType, namespace, name, eventType, reason, message string) error {
+	uid, err := c.workloadUID(ctx, workloadType, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	now := metav1.NewTime(time.Now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: name + ".",
+			Namespace:    namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       string(workloadType),
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Source:         corev1.EventSource{Component: "kube-watchtower"},
+	}
+	if _, err := c.clientset.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create event: %w", err)
+	}
+	return nil
+}
+
+// workloadUID returns the UID of a workload, so its Events are listed with it
+func (c *Client) workloadUID(ctx context.Context, workloadType WorkloadType, namespace, name string) (types.UID, error) {
+	var meta *metav1.ObjectMeta
+	switch workloadType {
+	case WorkloadTypeDeployment:
+		deploy, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get deployment: %w", err)
+		}
+		meta = &deploy.ObjectMeta
+	case WorkloadTypeDaemonSet:
+		ds, err := c.clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get daemonset: %w", err)
+		}
+		meta = &ds.ObjectMeta
+	case WorkloadTypeStatefulSet:
+		sts, err := c.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get statefulset: %w", err)
+		}
+		meta = &sts.ObjectMeta
+	case WorkloadTypeCronJob:
+		cj, err := c.clientset.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get cronjob: %w", err)
+		}
+		meta = &cj.ObjectMeta
+	default:
+		return "", fmt.Errorf("unsupported workload type %q", workloadType)
+	}
+	return meta.UID, nil
+}
@@ -0,0 +1,242 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// ExecInPods runs a command in a container of every running pod matching selector
+// Returns an error naming each pod where the command failed
+func (c *Client) ExecInPods(ctx context.Context, namespace string, selector *metav1.LabelSelector, containerName string, command []string) error {
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(selector),
+		FieldSelector: runningPodFieldSelector,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var failures []string
+	executed := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		executed++
+
+		if err := c.execInPod(ctx, pod, containerName, command); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", pod.Name, err))
+		}
+	}
+
+	if executed == 0 {
+		return fmt.Errorf("no running pods found")
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("command failed in %d/%d pods: %s", len(failures), executed, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// execInPod runs a command in a container of a pod
+func (c *Client) execInPod(ctx context.Context, pod *corev1.Pod, containerName string, command []string) error {
+	if c.restConfig == nil {
+		return fmt.Errorf("running commands in pods needs a REST config")
+	}
+
+	req := c.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: containerName,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(c.restConfig, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if out := strings.TrimSpace(stdout.String()); out != "" {
+		logger.Debugf("  [%s] stdout: %s", pod.Name, out)
+	}
+	if out := strings.TrimSpace(stderr.String()); out != "" {
+		logger.Debugf("  [%s] stderr: %s", pod.Name, out)
+	}
+	return err
+}
+
+// GetRunningPodIPs returns the IPs of running pods matching selector
+func (c *Client) GetRunningPodIPs(ctx context.Context, namespace string, selector *metav1.LabelSelector) ([]string, error) {
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(selector),
+		FieldSelector: runningPodFieldSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var ips []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil && pod.Status.PodIP != "" {
+			ips = append(ips, pod.Status.PodIP)
+		}
+	}
+	return ips, nil
+}
+
+// GetPodNodes returns the names of the nodes running pods matching selector
+func (c *Client) GetPodNodes(ctx context.Context, namespace string, selector *metav1.LabelSelector) ([]string, error) {
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(selector),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var nodes []string
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" && !seen[pod.Spec.NodeName] {
+			seen[pod.Spec.NodeName] = true
+			nodes = append(nodes, pod.Spec.NodeName)
+		}
+	}
+	return nodes, nil
+}
+
+// GetRunningDigests returns the image digest a container runs with in each running pod matching selector,
+// keyed by pod name (empty if the runtime reports no digest)
+func (c *Client) GetRunningDigests(ctx context.Context, namespace string, selector *metav1.LabelSelector, containerName string) (map[string]string, error) {
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(selector),
+		FieldSelector: runningPodFieldSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	digests := make(map[string]string)
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == containerName {
+				digests[pod.Name] = extractDigestFromImageID(status.ImageID)
+			}
+		}
+	}
+	return digests, nil
+}
+
+// StalePod is a running pod whose container runs a different digest than expected
+type StalePod struct {
+	Name   string
+	Node   string
+	Digest string // Empty if the runtime reports no digest
+}
+
+// GetStalePods returns the running pods matching selector whose container does not run the expected digest
+func (c *Client) GetStalePods(ctx context.Context, namespace string, selector *metav1.LabelSelector, containerName, expected string) ([]StalePod, error) {
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(selector),
+		FieldSelector: runningPodFieldSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var stale []StalePod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != containerName {
+				continue
+			}
+			if digest := extractDigestFromImageID(status.ImageID); digest != expected {
+				stale = append(stale, StalePod{Name: pod.Name, Node: pod.Spec.NodeName, Digest: digest})
+			}
+		}
+	}
+	return stale, nil
+}
+
+// ErrEvictionBlocked is returned by EvictPod when a PodDisruptionBudget allows no disruption
+var ErrEvictionBlocked = errors.New("eviction blocked by a PodDisruptionBudget")
+
+// EvictPod evicts a pod through the Eviction API, honoring PodDisruptionBudgets, letting its controller recreate it
+func (c *Client) EvictPod(ctx context.Context, namespace, name string) error {
+	eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	err := c.clientset.PolicyV1().Evictions(namespace).Evict(ctx, eviction)
+	if apierrors.IsTooManyRequests(err) {
+		return fmt.Errorf("failed to evict pod %s: %w", name, ErrEvictionBlocked)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to evict pod %s: %w", name, err)
+	}
+	return nil
+}
+
+// WaitForNodePod waits until a pod matching selector, other than the replaced one, is ready on node
+func (c *Client) WaitForNodePod(ctx context.Context, namespace string, selector *metav1.LabelSelector, node, replaced string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout waiting for the replacement of pod %s on node %s", replaced, node)
+		case <-ticker.C:
+			pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+				LabelSelector: metav1.FormatLabelSelector(selector),
+				FieldSelector: "spec.nodeName=" + node,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list pods: %w", err)
+			}
+			for _, pod := range pods.Items {
+				if pod.Name != replaced && pod.DeletionTimestamp == nil && isPodReady(&pod) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// isPodReady checks the Ready condition of a pod
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
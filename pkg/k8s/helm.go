@@ -0,0 +1,31 @@
+package k8s
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Marks of resources installed by Helm
+const (
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	managedByLabel                 = "app.kubernetes.io/managed-by"
+	instanceLabel                  = "app.kubernetes.io/instance"
+)
+
+// getHelmRelease returns the Helm release that installed an object, empty if it is not managed by Helm
+// Releases of another namespace are named namespace/name.
+func getHelmRelease(meta *metav1.ObjectMeta) string {
+	if name := meta.Annotations[helmReleaseNameAnnotation]; name != "" {
+		if namespace := meta.Annotations[helmReleaseNamespaceAnnotation]; namespace != "" && namespace != meta.Namespace {
+			return namespace + "/" + name
+		}
+		return name
+	}
+	if meta.Labels[managedByLabel] != "Helm" {
+		return ""
+	}
+	if name := meta.Labels[instanceLabel]; name != "" {
+		return name
+	}
+	return "unknown"
+}
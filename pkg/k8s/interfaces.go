@@ -0,0 +1,23 @@
+package k8s
+
+import (
+	"context"
+	"time"
+)
+
+// WorkloadLister lists the workloads to check, with their running digests
+type WorkloadLister interface {
+	ListWorkloads(ctx context.Context, nsFilter NamespaceFilter) ([]WorkloadInfo, error)
+}
+
+// Updater writes new images to workloads and awaits their rollouts
+type Updater interface {
+	// UpdateWorkloadImage sets the image of a container, previousDigest is recorded for rollbacks
+	UpdateWorkloadImage(ctx context.Context, workloadType WorkloadType, namespace, name, containerName, newImage, previousDigest string) error
+	WaitForRollout(ctx context.Context, workloadType WorkloadType, namespace, name string, timeout time.Duration) error
+}
+
+var (
+	_ WorkloadLister = (*Client)(nil)
+	_ Updater        = (*Client)(nil)
+)
@@ -0,0 +1,120 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkloadSummary describes a workload as listed, before any filtering
+type WorkloadSummary struct {
+	Type              WorkloadType
+	Name              string
+	Namespace         string
+	Annotations       map[string]string
+	PodAnnotations    map[string]string // Annotations of the pod template
+	PodLabels         map[string]string // Labels of the pod template
+	Owner             *Owner            // Controller owning the workload, nil if none
+	HelmRelease       string            // Helm release that installed the workload, empty if none
+	Replicas          int32             // Desired replicas, scheduled pods for DaemonSets, 0 for CronJobs
+	AvailableReplicas int32
+	Suspended         bool            // Suspended CronJob
+	Containers        []ContainerInfo // All containers, whatever their pull policy, without running digests
+}
+
+// ListAllWorkloads lists all Deployments, DaemonSets, StatefulSets and, if enabled, CronJobs,
+// including those ListWorkloads skips
+func (c *Client) ListAllWorkloads(ctx context.Context) ([]WorkloadSummary, error) {
+	var result []WorkloadSummary
+
+	err := c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+		deployments, err := c.clientset.AppsV1().Deployments(corev1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list deployments: %w", err)
+		}
+		for _, deploy := range deployments.Items {
+			result = append(result, summarizeWorkload(WorkloadTypeDeployment, &deploy.ObjectMeta, &deploy.Spec.Template, desiredReplicas(deploy.Spec.Replicas), deploy.Status.AvailableReplicas))
+		}
+		return deployments.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+		daemonsets, err := c.clientset.AppsV1().DaemonSets(corev1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list daemonsets: %w", err)
+		}
+		for _, ds := range daemonsets.Items {
+			result = append(result, summarizeWorkload(WorkloadTypeDaemonSet, &ds.ObjectMeta, &ds.Spec.Template, ds.Status.DesiredNumberScheduled, ds.Status.NumberAvailable))
+		}
+		return daemonsets.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+		statefulsets, err := c.clientset.AppsV1().StatefulSets(corev1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list statefulsets: %w", err)
+		}
+		for _, sts := range statefulsets.Items {
+			result = append(result, summarizeWorkload(WorkloadTypeStatefulSet, &sts.ObjectMeta, &sts.Spec.Template, desiredReplicas(sts.Spec.Replicas), sts.Status.AvailableReplicas))
+		}
+		return statefulsets.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.cronJobs {
+		err = c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+			cronjobs, err := c.clientset.BatchV1().CronJobs(corev1.NamespaceAll).List(ctx, opts)
+			if err != nil {
+				return "", fmt.Errorf("failed to list cronjobs: %w", err)
+			}
+			for _, cj := range cronjobs.Items {
+				summary := summarizeWorkload(WorkloadTypeCronJob, &cj.ObjectMeta, &cj.Spec.JobTemplate.Spec.Template, 0, 0)
+				summary.Suspended = cj.Spec.Suspend != nil && *cj.Spec.Suspend
+				result = append(result, summary)
+			}
+			return cronjobs.Continue, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// summarizeWorkload extracts the summary of a listed workload
+func summarizeWorkload(workloadType WorkloadType, meta *metav1.ObjectMeta, template *corev1.PodTemplateSpec, replicas, available int32) WorkloadSummary {
+	podSpec := &template.Spec
+	containers := make([]ContainerInfo, 0, len(podSpec.Containers))
+	for _, container := range podSpec.Containers {
+		containers = append(containers, ContainerInfo{
+			Name:            container.Name,
+			Image:           container.Image,
+			ImagePullPolicy: container.ImagePullPolicy,
+			Tag:             extractImageTag(container.Image),
+		})
+	}
+	return WorkloadSummary{
+		Type:              workloadType,
+		Name:              meta.Name,
+		Namespace:         meta.Namespace,
+		Annotations:       meta.Annotations,
+		PodAnnotations:    template.Annotations,
+		PodLabels:         template.Labels,
+		Owner:             getOwner(meta),
+		HelmRelease:       getHelmRelease(meta),
+		Replicas:          replicas,
+		AvailableReplicas: available,
+		Containers:        containers,
+	}
+}
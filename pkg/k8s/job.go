@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// jobDeleteTimeout bounds the deletion of a Job that failed or timed out
+const jobDeleteTimeout = 30 * time.Second
+
+// RunJob creates a Job and waits until it succeeds, fails or timeout expires
+// A Job that fails or times out is deleted together with its pods
+func (c *Client) RunJob(ctx context.Context, job *batchv1.Job, timeout time.Duration) error {
+	created, err := c.clientset.BatchV1().Jobs(job.Namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+	logger.Infof("Created job %s/%s, waiting for completion", created.Namespace, created.Name)
+
+	if err := c.waitForJob(ctx, created, timeout); err != nil {
+		c.deleteJob(ctx, created)
+		return err
+	}
+	return nil
+}
+
+// waitForJob waits until a Job succeeds, fails or timeout expires
+func (c *Client) waitForJob(ctx context.Context, job *batchv1.Job, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout waiting for job %s", job.Name)
+		case <-ticker.C:
+			current, err := c.clientset.BatchV1().Jobs(job.Namespace).Get(ctx, job.Name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to get job: %w", err)
+			}
+			for _, condition := range current.Status.Conditions {
+				if condition.Status != corev1.ConditionTrue {
+					continue
+				}
+				switch condition.Type {
+				case batchv1.JobComplete:
+					return nil
+				case batchv1.JobFailed:
+					return fmt.Errorf("job %s failed: %s", job.Name, condition.Message)
+				}
+			}
+		}
+	}
+}
+
+// deleteJob deletes a Job and, in the background, its pods so that a stuck Job does not keep running
+// It also runs when ctx is already cancelled
+func (c *Client) deleteJob(ctx context.Context, job *batchv1.Job) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jobDeleteTimeout)
+	defer cancel()
+
+	propagation := metav1.DeletePropagationBackground
+	err := c.clientset.BatchV1().Jobs(job.Namespace).Delete(ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		logger.Warnf("Failed to delete job %s/%s: %v", job.Namespace, job.Name, err)
+		return
+	}
+	logger.Infof("Deleted job %s/%s", job.Namespace, job.Name)
+}
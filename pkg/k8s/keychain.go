@@ -0,0 +1,23 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+)
+
+// Keychain returns the k8schain keychain of a Pod: the pull secrets of the Pod and its
+// service account, the docker config and the cloud provider credentials (GCR, ECR, ACR)
+func (c *Client) Keychain(ctx context.Context, namespace, serviceAccount string, pullSecrets []string) (authn.Keychain, error) {
+	keychain, err := k8schain.New(ctx, c.clientset, k8schain.Options{
+		Namespace:          namespace,
+		ServiceAccountName: serviceAccount,
+		ImagePullSecrets:   pullSecrets,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create keychain: %w", err)
+	}
+	return keychain, nil
+}
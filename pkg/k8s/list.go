@@ -0,0 +1,32 @@
+package k8s
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultPageSize is the number of objects requested per List call
+const defaultPageSize = 500
+
+// runningPodFieldSelector restricts pod lists to running pods on the server side
+const runningPodFieldSelector = "status.phase=Running"
+
+// listPages calls list for every page of a paginated List request.
+// list receives the options of the page to fetch and returns the continue token of the next page.
+func (c *Client) listPages(ctx context.Context, opts metav1.ListOptions, list func(opts metav1.ListOptions) (string, error)) error {
+	opts.Limit = c.pageSize
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		next, err := list(opts)
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		opts.Continue = next
+	}
+}
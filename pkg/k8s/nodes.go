@@ -0,0 +1,112 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxNodeStatusImages is the kubelet default of --node-status-max-images,
+// a node reporting that many images may hold more than it reports
+const maxNodeStatusImages = 50
+
+// NodeImages holds the image digests cached by the container runtime of a node,
+// as reported by the kubelet in the node status
+// The kubelet reports the largest images only, so this is a best-effort view of the runtime's cache.
+type NodeImages struct {
+	Node      string
+	Digests   map[string]bool // "repository@digest" references
+	Truncated bool            // The kubelet reported only part of the cached images
+}
+
+// Has checks if the node status lists repository@digest
+// An image missing from a truncated list may still be cached.
+func (n *NodeImages) Has(repository, digest string) bool {
+	return n.Digests[repository+"@"+digest] || n.Digests[normalizeRepository(repository)+"@"+digest]
+}
+
+// GetNodeImages returns the cached images of all nodes
+func (c *Client) GetNodeImages(ctx context.Context) (map[string]*NodeImages, error) {
+	result := make(map[string]*NodeImages)
+	err := c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+		nodes, err := c.clientset.CoreV1().Nodes().List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list nodes: %w", err)
+		}
+		for _, node := range nodes.Items {
+			images := &NodeImages{
+				Node:      node.Name,
+				Digests:   make(map[string]bool),
+				Truncated: len(node.Status.Images) >= maxNodeStatusImages,
+			}
+			for _, image := range node.Status.Images {
+				for _, name := range image.Names {
+					if strings.Contains(name, "@") {
+						images.Digests[name] = true
+					}
+				}
+			}
+			result[node.Name] = images
+		}
+		return nodes.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// normalizeRepository expands a Docker Hub short name the way runtimes report it
+// (e.g. nginx -> docker.io/library/nginx)
+func normalizeRepository(repository string) string {
+	parts := strings.SplitN(repository, "/", 2)
+	if len(parts) == 1 {
+		return "docker.io/library/" + repository
+	}
+	if !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		return "docker.io/" + repository
+	}
+	return repository
+}
+
+// GetPodPlatforms returns the platforms ("os/arch") of the nodes running pods matching selector
+func (c *Client) GetPodPlatforms(ctx context.Context, namespace string, selector *metav1.LabelSelector) ([]string, error) {
+	nodeNames, err := c.GetPodNodes(ctx, namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodeNames) == 0 {
+		return nil, nil
+	}
+	wanted := make(map[string]bool, len(nodeNames))
+	for _, name := range nodeNames {
+		wanted[name] = true
+	}
+
+	seen := make(map[string]bool)
+	var platforms []string
+	err = c.listPages(ctx, metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+		nodes, err := c.clientset.CoreV1().Nodes().List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list nodes: %w", err)
+		}
+		for _, node := range nodes.Items {
+			info := node.Status.NodeInfo
+			if !wanted[node.Name] || info.OperatingSystem == "" || info.Architecture == "" {
+				continue
+			}
+			platform := info.OperatingSystem + "/" + info.Architecture
+			if !seen[platform] {
+				seen[platform] = true
+				platforms = append(platforms, platform)
+			}
+		}
+		return nodes.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return platforms, nil
+}
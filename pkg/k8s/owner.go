@@ -0,0 +1,62 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Owner is the controller owning a workload, e.g. the custom resource of an operator
+type Owner struct {
+	Kind string // Kind qualified by the API group, e.g. Cluster.postgresql.cnpg.io
+	Name string
+}
+
+// String formats the owner as kind/name
+func (o *Owner) String() string {
+	return o.Kind + "/" + o.Name
+}
+
+// getOwner returns the controller owning an object, nil if it has none
+func getOwner(meta *metav1.ObjectMeta) *Owner {
+	ref := metav1.GetControllerOfNoCopy(meta)
+	if ref == nil {
+		return nil
+	}
+	kind := ref.Kind
+	if gv, err := schema.ParseGroupVersion(ref.APIVersion); err == nil && gv.Group != "" {
+		kind += "." + gv.Group
+	}
+	return &Owner{Kind: kind, Name: ref.Name}
+}
+
+// GetPodWorkload returns the Deployment, DaemonSet or StatefulSet owning a pod
+// Returns an empty name if the pod has no such owner, e.g. when it belongs to a Job.
+func (c *Client) GetPodWorkload(ctx context.Context, namespace, podName string) (WorkloadType, string, error) {
+	pod, err := c.clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get pod: %w", err)
+	}
+
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "", "", nil
+	}
+	switch owner.Kind {
+	case "DaemonSet":
+		return WorkloadTypeDaemonSet, owner.Name, nil
+	case "StatefulSet":
+		return WorkloadTypeStatefulSet, owner.Name, nil
+	case "ReplicaSet":
+		rs, err := c.clientset.AppsV1().ReplicaSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get replicaset: %w", err)
+		}
+		if deploy := metav1.GetControllerOf(rs); deploy != nil && deploy.Kind == "Deployment" {
+			return WorkloadTypeDeployment, deploy.Name, nil
+		}
+	}
+	return "", "", nil
+}
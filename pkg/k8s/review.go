@@ -0,0 +1,56 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// APIGroup and APIResource are the virtual resource API access is authorized against,
+// e.g. a Role granting verb "get" on resource "api" of group "kube-watchtower.io"
+const (
+	APIGroup    = "kube-watchtower.io"
+	APIResource = "api"
+)
+
+// AuthorizeToken authenticates a bearer token with a TokenReview and checks with a SubjectAccessReview
+// whether its user may perform verb on the kube-watchtower API in namespace ("" for cluster-wide)
+// Returns the user name and whether the access is allowed.
+func (c *Client) AuthorizeToken(ctx context.Context, token, verb, namespace string) (string, bool, error) {
+	review, err := c.clientset.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return "", false, nil
+	}
+	user := review.Status.User
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+	access, err := c.clientset.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:     APIGroup,
+				Resource:  APIResource,
+				Verb:      verb,
+				Namespace: namespace,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return user.Username, false, fmt.Errorf("failed to review access: %w", err)
+	}
+	return user.Username, access.Status.Allowed, nil
+}
@@ -0,0 +1,130 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// deploymentRevisionAnnotation holds the rollout revision of a ReplicaSet
+const deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+// PruneRevisions deletes the old revisions of a workload beyond keep:
+// scaled-down ReplicaSets of a Deployment, or ControllerRevisions of a StatefulSet.
+// Returns the number of deleted revisions. DaemonSets are not pruned.
+func (c *Client) PruneRevisions(ctx context.Context, workloadType WorkloadType, namespace, name string, keep int) (int, error) {
+	switch workloadType {
+	case WorkloadTypeDeployment:
+		return c.pruneReplicaSets(ctx, namespace, name, keep)
+	case WorkloadTypeStatefulSet:
+		return c.pruneControllerRevisions(ctx, namespace, name, keep)
+	default:
+		return 0, nil
+	}
+}
+
+// pruneReplicaSets deletes the oldest scaled-down ReplicaSets of a Deployment beyond keep
+func (c *Client) pruneReplicaSets(ctx context.Context, namespace, name string, keep int) (int, error) {
+	deployment, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	replicaSets, err := c.clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+
+	// Old ReplicaSets are those owned by the deployment and scaled to zero
+	var old []appsv1.ReplicaSet
+	for _, rs := range replicaSets.Items {
+		if !isOwnedBy(rs.OwnerReferences, deployment.UID) || rs.DeletionTimestamp != nil {
+			continue
+		}
+		if (rs.Spec.Replicas != nil && *rs.Spec.Replicas > 0) || rs.Status.Replicas > 0 {
+			continue
+		}
+		old = append(old, rs)
+	}
+
+	// Newest first
+	sort.Slice(old, func(i, j int) bool {
+		return replicaSetRevision(&old[i]) > replicaSetRevision(&old[j])
+	})
+
+	deleted := 0
+	for i := keep; i < len(old); i++ {
+		if err := c.clientset.AppsV1().ReplicaSets(namespace).Delete(ctx, old[i].Name, metav1.DeleteOptions{}); err != nil {
+			return deleted, fmt.Errorf("failed to delete replicaset %s: %w", old[i].Name, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// pruneControllerRevisions deletes the oldest unused ControllerRevisions of a StatefulSet beyond keep
+func (c *Client) pruneControllerRevisions(ctx context.Context, namespace, name string, keep int) (int, error) {
+	statefulset, err := c.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get statefulset: %w", err)
+	}
+
+	revisions, err := c.clientset.AppsV1().ControllerRevisions(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(statefulset.Spec.Selector),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list controllerrevisions: %w", err)
+	}
+
+	// Never delete the revisions pods are running or being updated to
+	var old []appsv1.ControllerRevision
+	for _, revision := range revisions.Items {
+		if !isOwnedBy(revision.OwnerReferences, statefulset.UID) || revision.DeletionTimestamp != nil {
+			continue
+		}
+		if revision.Name == statefulset.Status.CurrentRevision || revision.Name == statefulset.Status.UpdateRevision {
+			continue
+		}
+		old = append(old, revision)
+	}
+
+	// Newest first
+	sort.Slice(old, func(i, j int) bool {
+		return old[i].Revision > old[j].Revision
+	})
+
+	deleted := 0
+	for i := keep; i < len(old); i++ {
+		if err := c.clientset.AppsV1().ControllerRevisions(namespace).Delete(ctx, old[i].Name, metav1.DeleteOptions{}); err != nil {
+			return deleted, fmt.Errorf("failed to delete controllerrevision %s: %w", old[i].Name, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// isOwnedBy checks if an object is controlled by the owner with uid
+func isOwnedBy(ownerRefs []metav1.OwnerReference, uid types.UID) bool {
+	for _, ref := range ownerRefs {
+		if ref.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// replicaSetRevision returns the rollout revision of a ReplicaSet, 0 if unknown
+func replicaSetRevision(rs *appsv1.ReplicaSet) int64 {
+	revision, err := strconv.ParseInt(rs.Annotations[deploymentRevisionAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return revision
+}
@@ -0,0 +1,126 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// volumeSnapshotResource is the VolumeSnapshot resource of the CSI external-snapshotter
+var volumeSnapshotResource = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"}
+
+// SnapshotStatefulSetVolumes creates a VolumeSnapshot of every PVC of a StatefulSet's volumeClaimTemplates
+// and waits until each one is cut (creationTime set) or timeout expires
+// An empty snapshotClass uses the default VolumeSnapshotClass. Returns the names of the created snapshots.
+func (c *Client) SnapshotStatefulSetVolumes(ctx context.Context, namespace, name, snapshotClass string, timeout time.Duration) ([]string, error) {
+	if c.restConfig == nil {
+		return nil, fmt.Errorf("creating volume snapshots needs a REST config")
+	}
+	dynamicClient, err := c.dynamic()
+	if err != nil {
+		return nil, err
+	}
+
+	sts, err := c.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statefulset: %w", err)
+	}
+
+	suffix := time.Now().UTC().Format("20060102150405")
+	snapshots := dynamicClient.Resource(volumeSnapshotResource).Namespace(namespace)
+	// Pods, and their PVCs, are numbered from spec.ordinals.start
+	var start int32
+	if sts.Spec.Ordinals != nil {
+		start = sts.Spec.Ordinals.Start
+	}
+	var created []string
+	for _, template := range sts.Spec.VolumeClaimTemplates {
+		for ordinal := start; ordinal < start+desiredReplicas(sts.Spec.Replicas); ordinal++ {
+			pvc := fmt.Sprintf("%s-%s-%d", template.Name, name, ordinal)
+			if _, err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, pvc, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return created, fmt.Errorf("failed to get persistentvolumeclaim %s: %w", pvc, err)
+			}
+
+			spec := map[string]interface{}{
+				"source": map[string]interface{}{"persistentVolumeClaimName": pvc},
+			}
+			if snapshotClass != "" {
+				spec["volumeSnapshotClassName"] = snapshotClass
+			}
+			snapshot := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "snapshot.storage.k8s.io/v1",
+				"kind":       "VolumeSnapshot",
+				"metadata": map[string]interface{}{
+					"name":      truncateName(pvc+"-"+suffix, 253),
+					"namespace": namespace,
+					"labels": map[string]interface{}{
+						"app.kubernetes.io/managed-by": "kube-watchtower",
+					},
+				},
+				"spec": spec,
+			}}
+			result, err := snapshots.Create(ctx, snapshot, metav1.CreateOptions{})
+			if err != nil {
+				return created, fmt.Errorf("failed to create volume snapshot of %s: %w", pvc, err)
+			}
+			logger.Infof("Created volume snapshot %s/%s of %s", namespace, result.GetName(), pvc)
+			created = append(created, result.GetName())
+		}
+	}
+
+	if err := c.waitForSnapshots(ctx, snapshots, created, timeout); err != nil {
+		return created, err
+	}
+	return created, nil
+}
+
+// waitForSnapshots waits until the snapshots are cut, failing on snapshot errors
+func (c *Client) waitForSnapshots(ctx context.Context, snapshots dynamic.ResourceInterface, names []string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	pending := append([]string{}, names...)
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout waiting for volume snapshots %v", pending)
+		case <-ticker.C:
+		}
+
+		var remaining []string
+		for _, name := range pending {
+			snapshot, err := snapshots.Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to get volume snapshot %s: %w", name, err)
+			}
+			if message, found, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); found && message != "" {
+				return fmt.Errorf("volume snapshot %s failed: %s", name, message)
+			}
+			if creationTime, found, _ := unstructured.NestedString(snapshot.Object, "status", "creationTime"); !found || creationTime == "" {
+				remaining = append(remaining, name)
+			}
+		}
+		pending = remaining
+	}
+	return nil
+}
+
+// truncateName shortens an object name to n characters
+func truncateName(name string, n int) string {
+	if len(name) <= n {
+		return name
+	}
+	return name[:n]
+}
@@ -0,0 +1,99 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ParseWorkloadType parses a workload kind, case-insensitive and with the kubectl short names
+func ParseWorkloadType(kind string) (WorkloadType, bool) {
+	switch strings.ToLower(kind) {
+	case "deployment", "deployments", "deploy":
+		return WorkloadTypeDeployment, true
+	case "daemonset", "daemonsets", "ds":
+		return WorkloadTypeDaemonSet, true
+	case "statefulset", "statefulsets", "sts":
+		return WorkloadTypeStatefulSet, true
+	case "cronjob", "cronjobs", "cj":
+		return WorkloadTypeCronJob, true
+	default:
+		return "", false
+	}
+}
+
+// GetWorkload gets a single workload with its running digests, regardless of namespace filters and available replicas
+func (c *Client) GetWorkload(ctx context.Context, workloadType WorkloadType, namespace, name string) (*WorkloadInfo, error) {
+	var workload *WorkloadInfo
+	switch workloadType {
+	case WorkloadTypeDeployment:
+		deploy, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deployment: %w", err)
+		}
+		workload = c.processWorkload(ctx, workloadType, &deploy.ObjectMeta, &deploy.Spec.Template, deploy.Spec.Selector, desiredReplicas(deploy.Spec.Replicas), nil)
+	case WorkloadTypeDaemonSet:
+		ds, err := c.clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get daemonset: %w", err)
+		}
+		workload = c.processWorkload(ctx, workloadType, &ds.ObjectMeta, &ds.Spec.Template, ds.Spec.Selector, ds.Status.DesiredNumberScheduled, nil)
+		if workload != nil {
+			setDaemonSetRollout(workload, ds)
+		}
+	case WorkloadTypeStatefulSet:
+		sts, err := c.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get statefulset: %w", err)
+		}
+		workload = c.processWorkload(ctx, workloadType, &sts.ObjectMeta, &sts.Spec.Template, sts.Spec.Selector, desiredReplicas(sts.Spec.Replicas), nil)
+	case WorkloadTypeCronJob:
+		cj, err := c.clientset.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cronjob: %w", err)
+		}
+		workload = c.processCronJob(ctx, cj, nil)
+	default:
+		return nil, fmt.Errorf("unsupported workload type %q", workloadType)
+	}
+
+	if workload == nil {
+		return nil, fmt.Errorf("%s %s/%s has no containers with imagePullPolicy Always", workloadType, namespace, name)
+	}
+	return workload, nil
+}
+
+// GetWorkloadTemplate gets the pod template of a workload
+func (c *Client) GetWorkloadTemplate(ctx context.Context, workloadType WorkloadType, namespace, name string) (*corev1.PodTemplateSpec, error) {
+	switch workloadType {
+	case WorkloadTypeDeployment:
+		deploy, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deployment: %w", err)
+		}
+		return &deploy.Spec.Template, nil
+	case WorkloadTypeDaemonSet:
+		ds, err := c.clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get daemonset: %w", err)
+		}
+		return &ds.Spec.Template, nil
+	case WorkloadTypeStatefulSet:
+		sts, err := c.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get statefulset: %w", err)
+		}
+		return &sts.Spec.Template, nil
+	case WorkloadTypeCronJob:
+		cj, err := c.clientset.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cronjob: %w", err)
+		}
+		return &cj.Spec.JobTemplate.Spec.Template, nil
+	default:
+		return nil, fmt.Errorf("unsupported workload type %q", workloadType)
+	}
+}
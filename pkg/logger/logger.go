@@ -11,6 +11,8 @@ import (
 
 var (
 	log          *zap.SugaredLogger
+	core         zapcore.Core  // Console core, extended by EnableSyslog
+	minLevel     zapcore.Level // Minimum level of all cores
 	colorEnabled bool
 )
 
@@ -105,7 +107,8 @@ func Init(level string) error {
 	}
 
 	// Create core
-	core := zapcore.NewCore(
+	minLevel = zapLevel
+	core = zapcore.NewCore(
 		zapcore.NewConsoleEncoder(encoderConfig),
 		ws,
 		zapLevel,
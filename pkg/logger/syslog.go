@@ -0,0 +1,225 @@
+package logger
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// syslogFacility is the facility of all messages (daemon)
+const syslogFacility = 3
+
+// syslogTimeout bounds dialing and writing a message
+const syslogTimeout = 5 * time.Second
+
+// syslogQueueSize is the number of messages waiting to be sent, further messages are dropped
+const syslogQueueSize = 1000
+
+// ansiEscape matches the color codes of console messages
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// EnableSyslog additionally sends log messages to a syslog server in RFC 5424 format
+// The target is udp://host:port, tcp://host:port or tls://host:port, caFile optionally verifies the TLS server.
+func EnableSyslog(target, appName, caFile string) error {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid syslog target %q: expected udp://, tcp:// or tls://host:port", target)
+	}
+
+	w := &syslogWriter{network: u.Scheme, addr: u.Host, appName: appName, pid: os.Getpid(), queue: make(chan string, syslogQueueSize)}
+	w.hostname, _ = os.Hostname()
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "tls":
+		w.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12, ServerName: u.Hostname()}
+		if caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return fmt.Errorf("failed to read syslog CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificates found in syslog CA %s", caFile)
+			}
+			w.tlsConfig.RootCAs = pool
+		}
+	default:
+		return fmt.Errorf("unsupported syslog protocol %q: expected udp, tcp or tls", u.Scheme)
+	}
+
+	encoder := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
+		MessageKey:     "msg",
+		EncodeDuration: zapcore.StringDurationEncoder,
+		LineEnding:     zapcore.DefaultLineEnding,
+	})
+	syslog := &syslogCore{LevelEnabler: minLevel, encoder: encoder, writer: w}
+	go w.run()
+
+	log = zap.New(zapcore.NewTee(core, syslog)).Sugar()
+	return nil
+}
+
+// syslogCore writes log entries to a syslogWriter
+type syslogCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	writer  *syslogWriter
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	encoder := c.encoder.Clone()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+	return &syslogCore{LevelEnabler: c.LevelEnabler, encoder: encoder, writer: c.writer}
+}
+
+func (c *syslogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *syslogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	message := ansiEscape.ReplaceAllString(strings.TrimSuffix(buf.String(), "\n"), "")
+	buf.Free()
+	return c.writer.write(syslogSeverity(entry.Level), entry.Time, message)
+}
+
+func (c *syslogCore) Sync() error {
+	c.writer.flush()
+	return nil
+}
+
+// syslogSeverity maps a log level to a syslog severity
+func syslogSeverity(level zapcore.Level) int {
+	switch level {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.FatalLevel:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// syslogWriter sends RFC 5424 messages, with octet-counting framing over TCP and TLS (RFC 6587, RFC 5425)
+// Messages are queued and sent in the background, so an unreachable server never stalls logging.
+type syslogWriter struct {
+	network   string
+	addr      string
+	tlsConfig *tls.Config
+	hostname  string
+	appName   string
+	pid       int
+
+	queue   chan string
+	pending atomic.Int64 // Queued messages not sent yet
+	dropped atomic.Int64 // Messages dropped since the last report
+
+	conn net.Conn // Used by run only
+}
+
+// write queues a message, dropping it if the queue is full
+func (w *syslogWriter) write(severity int, t time.Time, message string) error {
+	msg := w.format(severity, t, message)
+	w.pending.Add(1)
+	select {
+	case w.queue <- msg:
+	default:
+		w.pending.Add(-1)
+		w.dropped.Add(1)
+	}
+	return nil
+}
+
+// format renders a message with its RFC 5424 header and, over TCP and TLS, its octet count
+func (w *syslogWriter) format(severity int, t time.Time, message string) string {
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		syslogFacility*8+severity, t.UTC().Format(time.RFC3339Nano), nilValue(w.hostname), nilValue(w.appName), w.pid, message)
+	if w.network != "udp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	return msg
+}
+
+// run sends the queued messages, reporting dropped ones once the server accepts messages again
+func (w *syslogWriter) run() {
+	for msg := range w.queue {
+		if dropped := w.dropped.Load(); dropped > 0 {
+			report := w.format(syslogSeverity(zapcore.WarnLevel), time.Now(), fmt.Sprintf("%d log messages dropped, syslog server unreachable or too slow", dropped))
+			if w.send(report) == nil {
+				w.dropped.Add(-dropped)
+			}
+		}
+		if err := w.send(msg); err != nil {
+			w.dropped.Add(1)
+		}
+		w.pending.Add(-1)
+	}
+}
+
+// flush waits until the queued messages are sent, at most syslogTimeout
+func (w *syslogWriter) flush() {
+	deadline := time.Now().Add(syslogTimeout)
+	for w.pending.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// send sends a message, reconnecting once if the connection was lost
+func (w *syslogWriter) send(msg string) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if w.conn, err = w.dial(); err != nil {
+				continue
+			}
+		}
+		_ = w.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err = w.conn.Write([]byte(msg)); err == nil {
+			return nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	return err
+}
+
+// dial connects to the syslog server
+func (w *syslogWriter) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	if w.tlsConfig != nil {
+		return tls.DialWithDialer(dialer, "tcp", w.addr, w.tlsConfig)
+	}
+	return dialer.Dial(w.network, w.addr)
+}
+
+// nilValue returns the RFC 5424 NILVALUE for empty header fields
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
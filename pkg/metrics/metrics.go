@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Update results recorded per namespace
+const (
+	ResultUpdated  = "updated"
+	ResultDetected = "detected"
+	ResultDeferred = "deferred"
+	ResultFailed   = "failed"
+)
+
+// Notification delivery results recorded per service
+const (
+	NotificationSucceeded = "succeeded"
+	NotificationFailed    = "failed"
+	NotificationRetried   = "retried"
+)
+
+// otherLabel replaces label values beyond the limit
+const otherLabel = "other"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	cycles = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kube_watchtower_cycles_total",
+		Help: "Check cycles run.",
+	})
+	cycleDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "kube_watchtower_cycle_duration_seconds",
+		Help:    "Duration of check cycles.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})
+	containers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kube_watchtower_containers",
+		Help: "Containers of the last check cycle, by outcome (scanned, updated, failed).",
+	}, []string{"outcome"})
+	updates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_watchtower_updates_total",
+		Help: "Container update results by namespace and result (updated, detected, deferred, failed).",
+	}, []string{"namespace", "result"})
+	registryChecks = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kube_watchtower_registry_check_duration_seconds",
+		Help:    "Duration of registry digest lookups by registry host.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"registry"})
+	registryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_watchtower_registry_errors_total",
+		Help: "Failed registry digest lookups by registry host.",
+	}, []string{"registry"})
+	registryCircuits = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kube_watchtower_registry_circuit_open",
+		Help: "Whether the checks against a registry host are skipped after consecutive failures (1) or not (0).",
+	}, []string{"registry"})
+	mixedDigestDeferrals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_watchtower_mixed_digest_deferrals_total",
+		Help: "Updates deferred because the replicas of a workload run different digests, by workload.",
+	}, []string{"namespace", "kind", "name"})
+	notifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_watchtower_notifications_total",
+		Help: "Notification deliveries by service and result (succeeded, failed, retried); attempts are succeeded + failed.",
+	}, []string{"service", "result"})
+
+	namespaces = newBoundedLabel(50)
+	registries = newBoundedLabel(50)
+	workloads  = newBoundedLabel(50)
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		cycles, cycleDuration, containers, updates, registryChecks, registryErrors, registryCircuits, mixedDigestDeferrals, notifications,
+	)
+}
+
+// Handler returns the Prometheus scrape handler
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// SetLabelLimit bounds the distinct namespace, registry and workload label values,
+// values beyond the limit are reported as "other"
+func SetLabelLimit(limit int) {
+	namespaces.setLimit(limit)
+	registries.setLimit(limit)
+	workloads.setLimit(limit)
+}
+
+// ObserveCycle records a completed check cycle
+func ObserveCycle(duration time.Duration, scanned, updated, failed int) {
+	cycles.Inc()
+	cycleDuration.Observe(duration.Seconds())
+	containers.WithLabelValues("scanned").Set(float64(scanned))
+	containers.WithLabelValues("updated").Set(float64(updated))
+	containers.WithLabelValues("failed").Set(float64(failed))
+}
+
+// ObserveUpdate records an update result of a container in a namespace
+func ObserveUpdate(namespace, result string) {
+	updates.WithLabelValues(namespaces.value(namespace), result).Inc()
+}
+
+// ObserveRegistryCheck records a registry digest lookup
+func ObserveRegistryCheck(host string, duration time.Duration, err error) {
+	host = registries.value(host)
+	registryChecks.WithLabelValues(host).Observe(duration.Seconds())
+	if err != nil {
+		registryErrors.WithLabelValues(host).Inc()
+	}
+}
+
+// ObserveMixedDigestDeferral records an update of a workload deferred because its replicas run different digests
+func ObserveMixedDigestDeferral(namespace, kind, name string) {
+	if workloads.value(namespace+"/"+kind+"/"+name) == otherLabel {
+		namespace, kind, name = otherLabel, otherLabel, otherLabel
+	}
+	mixedDigestDeferrals.WithLabelValues(namespace, kind, name).Inc()
+}
+
+// ObserveNotification records a notification delivery result of a service
+func ObserveNotification(service, result string) {
+	notifications.WithLabelValues(service, result).Inc()
+}
+
+// SetRegistryCircuit records whether the circuit of a registry host is open
+func SetRegistryCircuit(host string, open bool) {
+	value := 0.0
+	if open {
+		value = 1
+	}
+	registryCircuits.WithLabelValues(registries.value(host)).Set(value)
+}
+
+// boundedLabel keeps the first limit distinct values of a label
+type boundedLabel struct {
+	mu    sync.Mutex
+	limit int
+	seen  map[string]struct{}
+}
+
+func newBoundedLabel(limit int) *boundedLabel {
+	return &boundedLabel{limit: limit, seen: make(map[string]struct{})}
+}
+
+func (b *boundedLabel) setLimit(limit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = limit
+}
+
+// value returns v if it is known or the limit is not reached, "other" otherwise
+func (b *boundedLabel) value(v string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.seen[v]; ok {
+		return v
+	}
+	if len(b.seen) >= b.limit {
+		return otherLabel
+	}
+	b.seen[v] = struct{}{}
+	return v
+}
@@ -0,0 +1,133 @@
+package notifier
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/containrrr/shoutrrr"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// Backend delivers notifications to one destination
+type Backend interface {
+	// SendSummary delivers the summary of a check cycle
+	SendSummary(summary Summary) error
+	// Send delivers a message outside of the summary, e.g. on startup
+	Send(message string) error
+}
+
+// BackendFactory creates the backend of a notification URL
+type BackendFactory func(url string) (Backend, error)
+
+// KubernetesScheme is the scheme of Kubernetes Event notifications, provided by the watcher through Options.Backends
+const KubernetesScheme = "kubernetes"
+
+// Summary is the summary of a check cycle handed to a backend
+type Summary struct {
+	Cluster  string
+	DryRun   bool
+	Title    string
+	Sections []Section
+	Footer   string
+	Text     string // Plain text rendering of the title, sections and footer
+}
+
+// Section is a group of results shown under one heading
+type Section struct {
+	Name    string // success, detected, deferred, failed or advisory
+	Title   string
+	Color   string // Color of formatted messages
+	Results []UpdateResult
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendFactory{
+		"webhook+http":  newWebhookBackend,
+		"webhook+https": newWebhookBackend,
+		"file":          newFileBackend,
+	}
+)
+
+// RegisterBackend registers the backend of a URL scheme, replacing a previous one
+// URLs of other schemes are sent with shoutrrr.
+func RegisterBackend(scheme string, factory BackendFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[strings.ToLower(scheme)] = factory
+}
+
+// newBackend creates the backend of a notification URL, factories of the options take precedence over registered ones
+func newBackend(rawURL string, factories map[string]BackendFactory) (Backend, error) {
+	scheme := strings.ToLower(extractServiceType(rawURL))
+	if factory, ok := factories[scheme]; ok {
+		return factory(rawURL)
+	}
+
+	backendsMu.RLock()
+	factory, ok := backends[scheme]
+	backendsMu.RUnlock()
+	if ok {
+		return factory(rawURL)
+	}
+	return newShoutrrrBackend(rawURL), nil
+}
+
+// ValidateURL checks that the notification URLs (space separated) name known services with valid settings, without sending
+func ValidateURL(rawURLs string) error {
+	for _, rawURL := range strings.Fields(rawURLs) {
+		if err := validateURL(rawURL); err != nil {
+			return fmt.Errorf("%s://…: %w", extractServiceType(rawURL), err)
+		}
+	}
+	return nil
+}
+
+// validateURL checks one notification URL
+func validateURL(rawURL string) error {
+	scheme := strings.ToLower(extractServiceType(rawURL))
+	backendsMu.RLock()
+	_, ok := backends[scheme]
+	backendsMu.RUnlock()
+	if ok || scheme == KubernetesScheme {
+		if _, err := url.Parse(rawURL); err != nil {
+			return fmt.Errorf("invalid notification URL: %w", err)
+		}
+		return nil
+	}
+	if _, err := shoutrrr.CreateSender(rawURL); err != nil {
+		return err
+	}
+	return nil
+}
+
+// shoutrrrBackend sends notifications with shoutrrr, Slack and Discord summaries formatted
+type shoutrrrBackend struct {
+	url  string
+	rich richSender // Nil for services without formatted messages
+}
+
+func newShoutrrrBackend(rawURL string) *shoutrrrBackend {
+	b := &shoutrrrBackend{url: rawURL}
+	if rich, ok := newRichSender(rawURL); ok {
+		b.rich = rich
+	}
+	return b
+}
+
+func (b *shoutrrrBackend) SendSummary(summary Summary) error {
+	if b.rich != nil {
+		err := b.rich.send(summary.Title, summary.Sections, summary.Footer)
+		if err == nil {
+			return nil
+		}
+		logger.Warnf("Failed to send formatted notification, falling back to plain text: %v", err)
+	}
+	return shoutrrr.Send(b.url, summary.Text)
+}
+
+func (b *shoutrrrBackend) Send(message string) error {
+	return shoutrrr.Send(b.url, message)
+}
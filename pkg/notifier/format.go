@@ -0,0 +1,123 @@
+package notifier
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// DefaultTitle is the default summary title template
+const DefaultTitle = "kube-watchtower updates on {{.Cluster}}"
+
+// defaultEmoji are the markers of titles and sections, by name
+var defaultEmoji = map[string]string{
+	"title":    "☸️",
+	"test":     "🔔",
+	"success":  "✅",
+	"detected": "🔍",
+	"deferred": "⏸️",
+	"failed":   "❌",
+	"advisory": "💡",
+}
+
+// titleData is the data of the title template
+type titleData struct {
+	Cluster string
+	DryRun  bool
+}
+
+// FooterData is the data of the footer template
+type FooterData struct {
+	Cluster       string
+	Environment   string // Environment label, e.g. production
+	ServerVersion string // Kubernetes API server version, empty if unknown
+	Nodes         int    // Number of nodes, 0 if unknown
+	Version       string // kube-watchtower version
+	DryRun        bool
+}
+
+// newEmojiSet merges the overrides into the default markers, or returns an empty set without emoji
+func newEmojiSet(overrides map[string]string, disabled bool) map[string]string {
+	emoji := make(map[string]string, len(defaultEmoji))
+	if disabled {
+		return emoji
+	}
+	for name, marker := range defaultEmoji {
+		emoji[name] = marker
+	}
+	for name, marker := range overrides {
+		if _, ok := defaultEmoji[name]; !ok {
+			logger.Warnf("Unknown notification emoji %q, expected one of title, test, success, detected, deferred, failed, advisory", name)
+			continue
+		}
+		emoji[name] = marker
+	}
+	return emoji
+}
+
+// parseTitle parses the title template, falling back to the default title
+func parseTitle(text string) *template.Template {
+	if text == "" {
+		text = DefaultTitle
+	}
+	tmpl, err := template.New("title").Parse(text)
+	if err != nil {
+		logger.Warnf("Invalid notification title %q, using the default: %v", text, err)
+		tmpl = template.Must(template.New("title").Parse(DefaultTitle))
+	}
+	return tmpl
+}
+
+// parseFooter parses the footer template, nil if it is empty or invalid
+func parseFooter(text string) *template.Template {
+	if text == "" {
+		return nil
+	}
+	tmpl, err := template.New("footer").Parse(text)
+	if err != nil {
+		logger.Warnf("Invalid notification footer %q, leaving it out: %v", text, err)
+		return nil
+	}
+	return tmpl
+}
+
+// contextLine renders the footer template, "" without one
+func (n *Notifier) contextLine() string {
+	if n.footerTmpl == nil {
+		return ""
+	}
+	var data FooterData
+	if n.footerData != nil {
+		data = n.footerData()
+	}
+	data.Cluster, data.DryRun = n.clusterName, n.dryRun
+
+	var sb strings.Builder
+	if err := n.footerTmpl.Execute(&sb, data); err != nil {
+		logger.Warnf("Failed to render notification footer: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// Emoji returns the marker with a trailing space, or "" when it is empty or emoji are disabled
+func (n *Notifier) Emoji(name string) string {
+	if marker := n.emoji[name]; marker != "" {
+		return marker + " "
+	}
+	return ""
+}
+
+// title returns the title of the summary
+func (n *Notifier) title() string {
+	var sb strings.Builder
+	sb.WriteString(n.Emoji("title"))
+	if err := n.titleTemplate.Execute(&sb, titleData{Cluster: n.clusterName, DryRun: n.dryRun}); err != nil {
+		logger.Warnf("Failed to render notification title: %v", err)
+	}
+	if n.dryRun {
+		sb.WriteString(" [DRY-RUN]")
+	}
+	return sb.String()
+}
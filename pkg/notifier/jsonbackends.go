@@ -0,0 +1,126 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// summaryPayload is the JSON form of a summary or message, sent by the webhook and file backends
+type summaryPayload struct {
+	Time    time.Time       `json:"time"`
+	Cluster string          `json:"cluster,omitempty"`
+	DryRun  bool            `json:"dryRun,omitempty"`
+	Title   string          `json:"title,omitempty"`
+	Results []resultPayload `json:"results,omitempty"`
+	Footer  string          `json:"footer,omitempty"`
+	Message string          `json:"message"`
+}
+
+// resultPayload is the JSON form of a result
+type resultPayload struct {
+	Status    string `json:"status"` // Name of the section
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind,omitempty"`
+	Workload  string `json:"workload"`
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	Reason    string `json:"reason,omitempty"` // Deferral reason or error
+}
+
+// newSummaryPayload converts a summary, the plain text is kept as the message
+func newSummaryPayload(summary Summary) summaryPayload {
+	payload := summaryPayload{
+		Time:    time.Now().UTC(),
+		Cluster: summary.Cluster,
+		DryRun:  summary.DryRun,
+		Title:   summary.Title,
+		Results: []resultPayload{},
+		Footer:  summary.Footer,
+		Message: summary.Text,
+	}
+	for _, sec := range summary.Sections {
+		for _, result := range sec.Results {
+			r := resultPayload{
+				Status:    sec.Name,
+				Namespace: result.Source.Namespace,
+				Kind:      result.Source.Kind,
+				Workload:  result.Source.Workload,
+				Container: result.Source.Container,
+				Image:     result.Image,
+			}
+			if result.Error != nil {
+				r.Reason = result.Error.Error()
+			}
+			payload.Results = append(payload.Results, r)
+		}
+	}
+	return payload
+}
+
+// webhookBackend posts summaries as JSON, webhook+https://host/path posts to https://host/path
+type webhookBackend struct {
+	url string
+}
+
+func newWebhookBackend(rawURL string) (Backend, error) {
+	target, err := url.Parse(strings.TrimPrefix(rawURL, "webhook+"))
+	if err != nil || target.Host == "" {
+		return nil, fmt.Errorf("invalid webhook notification URL: expected webhook+https://host/path")
+	}
+	return &webhookBackend{url: target.String()}, nil
+}
+
+func (b *webhookBackend) SendSummary(summary Summary) error {
+	return postJSON(b.url, "", newSummaryPayload(summary))
+}
+
+func (b *webhookBackend) Send(message string) error {
+	return postJSON(b.url, "", summaryPayload{Time: time.Now().UTC(), Message: message})
+}
+
+// fileBackend appends summaries as JSON lines to a file, file:///path
+type fileBackend struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newFileBackend(rawURL string) (Backend, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Path == "" {
+		return nil, fmt.Errorf("invalid file notification URL: expected file:///path/to/file.jsonl")
+	}
+	return &fileBackend{path: target.Path}, nil
+}
+
+func (b *fileBackend) SendSummary(summary Summary) error {
+	return b.write(newSummaryPayload(summary))
+}
+
+func (b *fileBackend) Send(message string) error {
+	return b.write(summaryPayload{Time: time.Now().UTC(), Message: message})
+}
+
+func (b *fileBackend) write(payload summaryPayload) error {
+	line, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to serialize notification: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
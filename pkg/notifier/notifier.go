@@ -1,42 +1,138 @@
 package notifier
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
 
-	"github.com/containrrr/shoutrrr"
 	"github.com/qetesh/kube-watchtower/pkg/logger"
 )
 
 // UpdateResult stores the result of an update operation
 type UpdateResult struct {
-	Image   string
-	Success bool
-	Error   error
+	Source   Source
+	Image    string
+	Success  bool
+	Detected bool // Update detected but not applied (dry-run or monitor-only)
+	Deferred bool // Update held back by a policy or safety check, Error holds the reason
+	Advisory bool // Newer tags available for a pinned image, Image lists them
+	Error    error
+}
+
+// Options configures a notifier
+type Options struct {
+	ClusterName string
+	DryRun      bool
+	Retries     int                       // Delivery attempts per message, transient errors only
+	RetryDelay  time.Duration             // Delay before the first retry, doubled after each attempt
+	RateLimit   int                       // Summaries per hour, 0 for no limit
+	Title       string                    // Summary title template with .Cluster and .DryRun, DefaultTitle if empty
+	Footer      string                    // Context line template appended to the summary footer, see FooterData; none if empty
+	Context     func() FooterData         // Provides the context of the footer template, called once per summary
+	Emoji       map[string]string         // Markers replacing the defaults, by name (title, success, failed, ...)
+	NoEmoji     bool                      // Plain text titles and sections, e.g. for email
+	Backends    map[string]BackendFactory // Backends by URL scheme, taking precedence over registered ones
+	Failover    bool                      // With several URLs, try the next one only if the previous failed
 }
 
 // Notifier handles sending notifications
+// It is safe for concurrent use: results are added while workloads are checked concurrently.
 type Notifier struct {
-	url         string
-	clusterName string
-	enabled     bool
-	dryRun      bool
-	results     []UpdateResult
+	mu            sync.Mutex // Guards the URL, targets, results and suppressed count
+	url           string
+	targets       []target // Empty without a URL or when all URLs are invalid
+	failover      bool
+	factories     map[string]BackendFactory
+	clusterName   string
+	enabled       bool
+	dryRun        bool
+	retries       int
+	retryDelay    time.Duration
+	retryQueue    chan retry         // Messages waiting for a retry after a transient failure
+	retryOnce     sync.Once          // Starts retryLoop with the first retry
+	pending       atomic.Int64       // Queued retries not delivered or given up yet
+	ctx           context.Context    // Deliveries fail once it is done
+	cancel        context.CancelFunc // Stops retrying, called by Close
+	dropped       atomic.Int64       // Messages dropped after failed deliveries
+	limiter       *tokenBucket       // Nil without a rate limit
+	suppressed    int                // Updates held back by the rate limit since the last summary
+	titleTemplate *template.Template
+	footerTmpl    *template.Template // Nil without a footer template
+	footerData    func() FooterData
+	emoji         map[string]string
+	results       []UpdateResult
 }
 
 // NewNotifier creates a new notifier
-func NewNotifier(url, clusterName string, dryRun bool) *Notifier {
-	enabled := url != ""
-	if enabled {
-		logger.Infof("Using notifications: %s", extractServiceType(url))
+func NewNotifier(url string, opts Options) *Notifier {
+	n := &Notifier{
+		factories:     opts.Backends,
+		failover:      opts.Failover,
+		clusterName:   opts.ClusterName,
+		dryRun:        opts.DryRun,
+		retries:       opts.Retries,
+		retryDelay:    opts.RetryDelay,
+		retryQueue:    make(chan retry, retryQueueSize),
+		titleTemplate: parseTitle(opts.Title),
+		footerTmpl:    parseFooter(opts.Footer),
+		footerData:    opts.Context,
+		emoji:         newEmojiSet(opts.Emoji, opts.NoEmoji),
+		results:       make([]UpdateResult, 0),
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	if opts.RateLimit > 0 {
+		n.limiter = newTokenBucket(opts.RateLimit)
 	}
-	return &Notifier{
-		url:         url,
-		clusterName: clusterName,
-		enabled:     enabled,
-		dryRun:      dryRun,
-		results:     make([]UpdateResult, 0),
+	n.setURL(url)
+	return n
+}
+
+// SetURL replaces the notification URL, e.g. after a rotation of the Secret holding it
+func (n *Notifier) SetURL(url string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if url == n.url {
+		return
 	}
+	n.setURL(url)
+}
+
+// target is one destination of the notification URLs
+type target struct {
+	url     string
+	backend Backend
+}
+
+// setURL sets the notification URLs (space separated) and creates their backends
+func (n *Notifier) setURL(url string) {
+	n.url = url
+	n.targets = nil
+	n.enabled = false
+
+	var services []string
+	for _, u := range strings.Fields(url) {
+		backend, err := newBackend(u, n.factories)
+		if err != nil {
+			logger.Warnf("Notifications to %s disabled: %v", extractServiceType(u), err)
+			continue
+		}
+		n.targets = append(n.targets, target{url: u, backend: backend})
+		services = append(services, extractServiceType(u))
+	}
+	if len(n.targets) == 0 {
+		return
+	}
+
+	mode := ""
+	if n.failover && len(n.targets) > 1 {
+		mode = " (failover)"
+	}
+	logger.Infof("Using notifications: %s%s", strings.Join(services, ", "), mode)
+	n.enabled = true
 }
 
 // extractServiceType extracts service type from shoutrrr URL
@@ -53,94 +149,218 @@ func extractServiceType(url string) string {
 	return "unknown"
 }
 
-// AddResult adds an update result
-func (n *Notifier) AddResult(image string, success bool, err error) {
-	if !n.enabled {
-		return
+// Source identifies the container a result belongs to
+type Source struct {
+	Namespace string
+	Kind      string // Workload type, e.g. Deployment
+	Workload  string
+	Container string
+}
+
+// String formats the source as namespace/workload/container, prefixed with the kind if known
+func (s Source) String() string {
+	if s.Kind != "" {
+		return fmt.Sprintf("%s %s/%s/%s", s.Kind, s.Namespace, s.Workload, s.Container)
 	}
-	n.results = append(n.results, UpdateResult{
+	return fmt.Sprintf("%s/%s/%s", s.Namespace, s.Workload, s.Container)
+}
+
+// AddResult adds an update result
+func (n *Notifier) AddResult(source Source, image string, success bool, err error) {
+	n.add(UpdateResult{
+		Source:  source,
 		Image:   image,
 		Success: success,
 		Error:   err,
 	})
 }
 
-// SendSummary sends a summary notification of all updates
+// AddDetected adds a detected update that was not applied
+func (n *Notifier) AddDetected(source Source, image string) {
+	n.add(UpdateResult{
+		Source:   source,
+		Image:    image,
+		Success:  true,
+		Detected: true,
+	})
+}
+
+// AddDeferred adds an update that was held back, with the reason
+func (n *Notifier) AddDeferred(source Source, image string, reason string) {
+	n.add(UpdateResult{
+		Source:   source,
+		Image:    image,
+		Deferred: true,
+		Error:    fmt.Errorf("%s", reason),
+	})
+}
+
+// AddAdvisory adds the newer tags available for a pinned image
+func (n *Notifier) AddAdvisory(source Source, image string, tags []string) {
+	n.add(UpdateResult{
+		Source:   source,
+		Image:    fmt.Sprintf("%s (%s)", image, strings.Join(tags, ", ")),
+		Advisory: true,
+	})
+}
+
+// add stores a result while notifications are enabled
+func (n *Notifier) add(result UpdateResult) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.enabled {
+		return
+	}
+	n.results = append(n.results, result)
+}
+
+// SendSummary sends a summary notification of all updates to the backend of the URL
+// Slack and Discord webhooks get a formatted message, other shoutrrr services plain text.
+// Over the rate limit the summary is held back and counted in the next one.
 func (n *Notifier) SendSummary(totalCount int) {
+	n.mu.Lock()
 	if !n.enabled {
+		n.mu.Unlock()
 		return
 	}
 
 	// If no updates were attempted, don't send notification
 	if len(n.results) == 0 {
+		n.mu.Unlock()
+		return
+	}
+
+	if n.limiter != nil && !n.limiter.allow(time.Now()) {
+		n.suppressed += len(n.results)
+		logger.Infof("Notification rate limit reached, holding back %d update(s)", len(n.results))
+		n.mu.Unlock()
 		return
 	}
 
-	message := n.buildSummaryMessage(totalCount)
-	n.send(message)
+	summary := Summary{
+		Cluster:  n.clusterName,
+		DryRun:   n.dryRun,
+		Title:    n.title(),
+		Sections: n.sections(),
+		Footer:   n.footer(totalCount),
+		Text:     n.buildSummaryMessage(totalCount),
+	}
+	targets := n.targets
+	n.mu.Unlock()
+
+	// The context may query the cluster, so it is rendered without holding the lock
+	if line := n.contextLine(); line != "" {
+		summary.Footer += "\n" + line
+		summary.Text += "\n" + line
+	}
+
+	// Delivered without holding the lock, services may be slow to respond
+	if err := n.dispatch(targets, func(b Backend) error { return b.SendSummary(summary) }); err == nil {
+		n.mu.Lock()
+		n.suppressed = 0
+		n.mu.Unlock()
+	}
 }
 
-// buildSummaryMessage builds the summary notification message
-func (n *Notifier) buildSummaryMessage(totalCount int) string {
-	var sb strings.Builder
+// Send sends a message outside of the update summary, e.g. on startup
+func (n *Notifier) Send(message string) error {
+	n.mu.Lock()
+	enabled, url, targets := n.enabled, n.url, n.targets
+	n.mu.Unlock()
 
-	// Title
-	if n.dryRun {
-		sb.WriteString(fmt.Sprintf("☸️ kube-watchtower updates on %s [DRY-RUN]\n\n", n.clusterName))
-	} else {
-		sb.WriteString(fmt.Sprintf("☸️ kube-watchtower updates on %s\n\n", n.clusterName))
+	if !enabled {
+		if url != "" {
+			return fmt.Errorf("invalid notification URL %s://…", extractServiceType(strings.TrimSpace(url)))
+		}
+		return fmt.Errorf("no notification URL configured")
 	}
+	return n.dispatch(targets, func(b Backend) error { return b.Send(message) })
+}
 
-	// Separate successful and failed updates
-	var successList []string
-	var failList []string
+// sections groups the results in display order, empty sections are omitted
+func (n *Notifier) sections() []Section {
+	success := Section{Name: "success", Title: n.Emoji("success") + "Updated successfully", Color: colorSuccess}
+	detected := Section{Name: "detected", Title: n.Emoji("detected") + "Detected updates", Color: colorDetected}
+	deferred := Section{Name: "deferred", Title: n.Emoji("deferred") + "Deferred updates", Color: colorDeferred}
+	failed := Section{Name: "failed", Title: n.Emoji("failed") + "Failed to update", Color: colorFailed}
+	advisory := Section{Name: "advisory", Title: n.Emoji("advisory") + "Newer tags available", Color: colorAdvisory}
 
 	for _, result := range n.results {
-		if result.Success {
-			successList = append(successList, result.Image)
+		if result.Advisory {
+			advisory.Results = append(advisory.Results, result)
+		} else if result.Deferred {
+			deferred.Results = append(deferred.Results, result)
+		} else if result.Detected || (result.Success && n.dryRun) {
+			detected.Results = append(detected.Results, result)
+		} else if result.Success {
+			success.Results = append(success.Results, result)
 		} else {
-			failList = append(failList, result.Image)
+			failed.Results = append(failed.Results, result)
 		}
 	}
 
-	// Successful updates
-	if len(successList) > 0 {
-		if n.dryRun {
-			sb.WriteString("🔍 Detected updates:\n")
-		} else {
-			sb.WriteString("✅ Updated successfully:\n")
+	var sections []Section
+	for _, s := range []Section{success, detected, deferred, failed, advisory} {
+		if len(s.Results) > 0 {
+			sections = append(sections, s)
 		}
-		for _, image := range successList {
-			sb.WriteString(fmt.Sprintf("- %s\n", image))
+	}
+	return sections
+}
+
+// footer returns the update count line of the summary
+func (n *Notifier) footer(totalCount int) string {
+	successCount := 0
+	for _, result := range n.results {
+		if result.Advisory || result.Deferred {
+			continue
 		}
-		sb.WriteString("\n")
+		if n.dryRun && (result.Detected || result.Success) {
+			successCount++
+		} else if !n.dryRun && result.Success && !result.Detected {
+			successCount++
+		}
+	}
+	footer := fmt.Sprintf("Updated: %d/%d", successCount, totalCount)
+	if n.suppressed > 0 {
+		footer += fmt.Sprintf("\n…and %d more updates held back by the rate limit", n.suppressed)
 	}
+	return footer
+}
+
+// line formats a result as one summary line
+func (r UpdateResult) line() string {
+	if r.Deferred {
+		return fmt.Sprintf("%s (%v)", r.Image, r.Error)
+	}
+	return r.Image
+}
 
-	// Failed updates
-	if len(failList) > 0 {
-		sb.WriteString("❌ Failed to update:\n")
-		for _, image := range failList {
-			sb.WriteString(fmt.Sprintf("- %s\n", image))
+// buildSummaryMessage builds the summary notification message
+func (n *Notifier) buildSummaryMessage(totalCount int) string {
+	var sb strings.Builder
+
+	// Title
+	sb.WriteString(n.title() + "\n\n")
+
+	// Successful, detected, deferred and failed updates, newer tags of pinned images
+	for _, s := range n.sections() {
+		sb.WriteString(s.Title + ":\n")
+		for _, result := range s.Results {
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", result.Source, result.line()))
 		}
 		sb.WriteString("\n")
 	}
 
 	// Summary
-	successCount := len(successList)
-	sb.WriteString(fmt.Sprintf("Updated: %d/%d", successCount, totalCount))
+	sb.WriteString(n.footer(totalCount))
 
 	return sb.String()
 }
 
-// send sends notification
-func (n *Notifier) send(message string) {
-	err := shoutrrr.Send(n.url, message)
-	if err != nil {
-		logger.Warnf("Failed to send notification: %v", err)
-	}
-}
-
 // Reset clears all stored results
 func (n *Notifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	n.results = make([]UpdateResult, 0)
 }
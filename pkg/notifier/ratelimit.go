@@ -0,0 +1,38 @@
+package notifier
+
+import (
+	"time"
+)
+
+// tokenBucket limits messages to a number per hour, refilled continuously
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	refill   float64 // Tokens per second
+	last     time.Time
+}
+
+// newTokenBucket creates a full bucket for perHour messages per hour
+func newTokenBucket(perHour int) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(perHour),
+		tokens:   float64(perHour),
+		refill:   float64(perHour) / time.Hour.Seconds(),
+		last:     time.Now(),
+	}
+}
+
+// allow takes a token if one is available
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.refill
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,208 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/metrics"
+)
+
+// transientStatus matches HTTP 429 and 5xx status codes in service error messages
+var transientStatus = regexp.MustCompile(`\b(429|5\d\d)\b`)
+
+// retryQueueSize bounds the messages waiting for a retry, further ones are dropped
+const retryQueueSize = 100
+
+// retry is a message waiting to be delivered again after a transient failure
+type retry struct {
+	targets []target // Targets still missing the message, in failover mode all of them
+	send    func(Backend) error
+	attempt int           // Attempts made so far
+	delay   time.Duration // Wait before the next attempt
+}
+
+// dispatch delivers a message to the targets: to all of them, or in failover mode in order until one succeeds
+// Transient failures are retried in the background with exponential backoff, so a slow or rate-limited service
+// never holds up the check cycle.
+// Returns the errors of the first attempt, nil if the message reached all targets (in failover mode: one target).
+func (n *Notifier) dispatch(targets []target, send func(Backend) error) error {
+	failed, err := n.attempt(targets, send, 1)
+	if len(failed) > 0 {
+		n.enqueue(retry{targets: failed, send: send, attempt: 1, delay: n.retryDelay})
+	}
+	return err
+}
+
+// attempt makes one delivery attempt to the targets
+// Returns the targets to retry and the delivery errors; messages that are not retried are counted as dropped.
+func (n *Notifier) attempt(targets []target, send func(Backend) error, attempt int) ([]target, error) {
+	var errs []error
+	var failed []target
+	transient := false
+	for i, t := range targets {
+		service := extractServiceType(t.url)
+		err := n.deliver(n.ctx, service, func() error { return send(t.backend) })
+		if err == nil {
+			metrics.ObserveNotification(service, metrics.NotificationSucceeded)
+			if n.failover {
+				return nil, nil
+			}
+			continue
+		}
+		errs = append(errs, err)
+
+		retried := attempt < n.retries && isTransient(err)
+		if retried {
+			metrics.ObserveNotification(service, metrics.NotificationRetried)
+			logger.Debugf("Failed to send notification to %s (attempt %d/%d), retrying in the background: %v", service, attempt, n.retries, err)
+			failed = append(failed, t)
+			transient = true
+		} else {
+			metrics.ObserveNotification(service, metrics.NotificationFailed)
+			logger.Warnf("Failed to send notification to %s after %d attempt(s): %v", service, attempt, err)
+			if !n.failover {
+				n.dropped.Add(1)
+			}
+		}
+		if n.failover && i < len(targets)-1 {
+			logger.Warnf("Notification to %s failed, failing over to %s", service, extractServiceType(targets[i+1].url))
+		}
+	}
+	if len(errs) == 0 {
+		return nil, nil
+	}
+
+	if n.failover {
+		// The whole chain is tried again while one target may still recover
+		if !transient {
+			n.dropped.Add(1)
+			return nil, errors.Join(errs...)
+		}
+		return targets, errors.Join(errs...)
+	}
+	return failed, errors.Join(errs...)
+}
+
+// deliver runs one delivery to a service, failing without it once ctx is done
+func (n *Notifier) deliver(ctx context.Context, service string, send func() error) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("notification to %s not sent: %w", service, err)
+	}
+	return send()
+}
+
+// enqueue queues a message for a retry, dropping it if the queue is full or the notifier is closed
+func (n *Notifier) enqueue(r retry) {
+	if n.ctx.Err() != nil {
+		n.dropQueued(r, "notifier is closed")
+		return
+	}
+	n.retryOnce.Do(func() { go n.retryLoop() })
+
+	n.pending.Add(1)
+	select {
+	case n.retryQueue <- r:
+	default:
+		n.pending.Add(-1)
+		n.dropQueued(r, "retry queue is full")
+	}
+}
+
+// retryLoop delivers the queued messages once their delay has passed, until the notifier is closed
+func (n *Notifier) retryLoop() {
+	for {
+		var r retry
+		select {
+		case <-n.ctx.Done():
+			n.dropRetries()
+			return
+		case r = <-n.retryQueue:
+		}
+
+		timer := time.NewTimer(r.delay)
+		select {
+		case <-n.ctx.Done():
+			timer.Stop()
+			n.dropQueued(r, "notifier is closed")
+			n.pending.Add(-1)
+			continue
+		case <-timer.C:
+		}
+
+		attempt := r.attempt + 1
+		if failed, _ := n.attempt(r.targets, r.send, attempt); len(failed) > 0 {
+			r.targets, r.attempt, r.delay = failed, attempt, r.delay*2
+			select {
+			case n.retryQueue <- r:
+				continue // Still pending
+			default:
+				n.dropQueued(r, "retry queue is full")
+			}
+		}
+		n.pending.Add(-1)
+	}
+}
+
+// dropRetries drops the messages still queued once the notifier is closed
+func (n *Notifier) dropRetries() {
+	for {
+		select {
+		case r := <-n.retryQueue:
+			n.dropQueued(r, "notifier is closed")
+			n.pending.Add(-1)
+		default:
+			return
+		}
+	}
+}
+
+// dropQueued counts a message given up on without another attempt
+func (n *Notifier) dropQueued(r retry, reason string) {
+	logger.Warnf("Dropping notification after %d attempt(s): %s", r.attempt, reason)
+	if n.failover {
+		n.dropped.Add(1)
+	} else {
+		n.dropped.Add(int64(len(r.targets)))
+	}
+}
+
+// Close waits until the queued retries are delivered or given up, at most until ctx is done,
+// then stops retrying and ends the retry loop; messages still queued are dropped
+func (n *Notifier) Close(ctx context.Context) {
+	for n.pending.Load() > 0 && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	n.cancel()
+}
+
+// Dropped returns the number of messages dropped after failed deliveries
+// In fan-out mode every target missing a message counts, in failover mode a message no target accepted.
+func (n *Notifier) Dropped() int64 {
+	return n.dropped.Load()
+}
+
+// isTransient checks if a delivery error is worth retrying: network errors, timeouts, 429 and 5xx responses
+// shoutrrr wraps most service errors as plain text, so the message is inspected as well
+func isTransient(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, marker := range []string{"timeout", "deadline exceeded", "connection reset", "connection refused", "eof"} {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return transientStatus.MatchString(message)
+}
@@ -0,0 +1,266 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containrrr/shoutrrr/pkg/services/discord"
+	"github.com/containrrr/shoutrrr/pkg/services/slack"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// Section colors of formatted messages
+const (
+	colorSuccess  = "#2eb67d"
+	colorDetected = "#1d9bd1"
+	colorDeferred = "#ecb22e"
+	colorFailed   = "#e01e5a"
+	colorAdvisory = "#868686"
+)
+
+// Message size limits of the services
+const (
+	maxSlackBlocks     = 45 // 50 per message, some kept for headings
+	maxDiscordEmbeds   = 10
+	maxDiscordFields   = 25
+	maxDiscordFieldLen = 1024
+)
+
+// richClient posts formatted notifications
+var richClient = &http.Client{Timeout: 30 * time.Second}
+
+// richSender sends a formatted summary to a service
+type richSender interface {
+	send(title string, sections []Section, footer string) error
+}
+
+// newRichSender returns the formatted sender of Slack and Discord URLs
+func newRichSender(rawURL string) (richSender, bool) {
+	serviceURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false
+	}
+
+	switch serviceURL.Scheme {
+	case slack.Scheme:
+		config, err := slack.CreateConfigFromURL(serviceURL)
+		if err != nil {
+			return nil, false
+		}
+		return &slackSender{config: config}, true
+	case "discord":
+		config := &discord.Config{}
+		if err := config.SetURL(serviceURL); err != nil {
+			return nil, false
+		}
+		return &discordSender{url: discord.CreateAPIURLFromConfig(config)}, true
+	default:
+		return nil, false
+	}
+}
+
+// slackSender sends Slack messages with one colored attachment of blocks per section
+type slackSender struct {
+	config *slack.Config
+}
+
+// slackPostMessageURL is the Slack API method posting messages with a bot token
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+func (s *slackSender) send(title string, sections []Section, footer string) error {
+	if s.config.Token.IsAPIToken() {
+		return s.sendThread(title, sections, footer)
+	}
+
+	var attachments []slackAttachment
+	blocks := 0
+	for _, sec := range sections {
+		att := slackSection(sec, maxSlackBlocks-blocks)
+		blocks += len(att.Blocks) - 1
+		attachments = append(attachments, att)
+	}
+
+	payload := s.payload(title + "\n" + footer)
+	payload["attachments"] = attachments
+	return postJSON(s.config.Token.WebhookURL(), "", payload)
+}
+
+// sendThread posts the title, section counts and footer as the parent message of a thread
+// and each section with its entries as a reply, which needs a bot token.
+// Replies that fail are only logged, retrying would post the parent message again.
+func (s *slackSender) sendThread(title string, sections []Section, footer string) error {
+	lines := []string{title}
+	for _, sec := range sections {
+		lines = append(lines, fmt.Sprintf("%s: %d", sec.Title, len(sec.Results)))
+	}
+	lines = append(lines, footer)
+
+	var parent struct {
+		Ts string `json:"ts"`
+	}
+	if err := postJSONResult(slackPostMessageURL, s.config.Token.Authorization(), s.payload(strings.Join(lines, "\n")), &parent); err != nil {
+		return err
+	}
+	if parent.Ts == "" {
+		return nil
+	}
+
+	for _, sec := range sections {
+		payload := s.payload(sec.Title)
+		payload["thread_ts"] = parent.Ts
+		payload["attachments"] = []slackAttachment{slackSection(sec, maxSlackBlocks)}
+		if err := postJSON(slackPostMessageURL, s.config.Token.Authorization(), payload); err != nil {
+			logger.Warnf("Failed to post %q to the notification thread: %v", sec.Title, err)
+		}
+	}
+	return nil
+}
+
+// payload returns a Slack message with the text, bot name and, for the API, channel
+func (s *slackSender) payload(text string) map[string]interface{} {
+	payload := map[string]interface{}{"text": text}
+	if s.config.BotName != "" {
+		payload["username"] = s.config.BotName
+	}
+	if s.config.Token.IsAPIToken() {
+		payload["channel"] = s.config.Channel
+	}
+	return payload
+}
+
+// slackSection renders a section as a colored attachment with a heading and at most limit entries
+func slackSection(sec Section, limit int) slackAttachment {
+	att := slackAttachment{
+		Color:  sec.Color,
+		Blocks: []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: "*" + sec.Title + "*"}}},
+	}
+	for i, result := range sec.Results {
+		if i >= limit {
+			att.Blocks = append(att.Blocks, slackBlock{Type: "context", Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("…and %d more", len(sec.Results)-i)}})
+			break
+		}
+		att.Blocks = append(att.Blocks, slackBlock{Type: "section", Fields: []slackText{
+			{Type: "mrkdwn", Text: "*Workload*\n" + result.Source.String()},
+			{Type: "mrkdwn", Text: "*Image*\n" + result.line()},
+		}})
+	}
+	return att
+}
+
+// discordSender sends Discord messages with one colored embed of fields per section
+type discordSender struct {
+	url string
+}
+
+func (d *discordSender) send(title string, sections []Section, footer string) error {
+	type field struct {
+		Name   string `json:"name"`
+		Value  string `json:"value"`
+		Inline bool   `json:"inline,omitempty"`
+	}
+	type embed struct {
+		Title  string  `json:"title"`
+		Color  int     `json:"color"`
+		Fields []field `json:"fields"`
+	}
+
+	var embeds []embed
+	for _, sec := range sections {
+		if len(embeds) == maxDiscordEmbeds {
+			break
+		}
+		color, _ := strconv.ParseInt(strings.TrimPrefix(sec.Color, "#"), 16, 32)
+		e := embed{Title: sec.Title, Color: int(color)}
+		for i, result := range sec.Results {
+			if i == maxDiscordFields-1 && len(sec.Results) > maxDiscordFields {
+				e.Fields = append(e.Fields, field{Name: "…", Value: fmt.Sprintf("and %d more", len(sec.Results)-i)})
+				break
+			}
+			e.Fields = append(e.Fields, field{Name: result.Source.String(), Value: truncate(result.line(), maxDiscordFieldLen)})
+		}
+		embeds = append(embeds, e)
+	}
+
+	return postJSON(d.url, "", map[string]interface{}{
+		"content": fmt.Sprintf("**%s**\n%s", title, footer),
+		"embeds":  embeds,
+	})
+}
+
+// postJSON posts a JSON payload, checking the status and the ok field of Slack API responses
+func postJSON(url, authorization string, payload interface{}) error {
+	return postJSONResult(url, authorization, payload, nil)
+}
+
+// postJSONResult posts a JSON payload like postJSON and decodes the response into result, unless it is nil
+func postJSONResult(url, authorization string, payload, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to serialize notification: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := richClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification service returned status %d: %s", resp.StatusCode, bytes.TrimSpace(response))
+	}
+
+	var apiResponse struct {
+		Ok    *bool  `json:"ok"`
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(response, &apiResponse) == nil && apiResponse.Ok != nil && !*apiResponse.Ok {
+		return fmt.Errorf("notification service rejected the message: %s", apiResponse.Error)
+	}
+	if result != nil {
+		if err := json.Unmarshal(response, result); err != nil {
+			return fmt.Errorf("failed to read notification service response: %w", err)
+		}
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Decision is the outcome of a policy evaluation
+type Decision string
+
+const (
+	Allow Decision = "allow" // Apply the update
+	Deny  Decision = "deny"  // Do not apply the update
+	Defer Decision = "defer" // Retry in a later cycle
+)
+
+// Input is the proposed update passed to the policy as `input`
+type Input struct {
+	Time        time.Time         `json:"time"`
+	Weekday     string            `json:"weekday"`
+	Cluster     string            `json:"cluster"`
+	Namespace   string            `json:"namespace"`
+	Kind        string            `json:"kind"`
+	Name        string            `json:"name"`
+	Container   string            `json:"container"`
+	OldImage    string            `json:"oldImage"`
+	NewImage    string            `json:"newImage"`
+	OldDigest   string            `json:"oldDigest,omitempty"`
+	NewDigest   string            `json:"newDigest"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Image       map[string]string `json:"image,omitempty"` // Image metadata (OCI annotations)
+}
+
+// Result is a policy decision with an optional reason
+type Result struct {
+	Decision Decision
+	Reason   string
+}
+
+// Client evaluates a Rego policy through the OPA REST data API
+type Client struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewClient creates a policy client, returns nil if url is empty (disabled)
+// url points at a rule, e.g. http://localhost:8181/v1/data/kubewatchtower/decision
+func NewClient(url string, timeout time.Duration) *Client {
+	if url == "" {
+		return nil
+	}
+	return &Client{
+		url:        url,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Evaluate evaluates the policy for a proposed update
+// The rule may return a decision string or an object {"decision": ..., "reason": ...};
+// an undefined result allows the update
+func (c *Client) Evaluate(ctx context.Context, input Input) (Result, error) {
+	if c == nil {
+		return Result{Decision: Allow}, nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to serialize policy input: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid policy url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to evaluate policy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read policy response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("policy server returned status %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+
+	return parseResponse(data)
+}
+
+// parseResponse parses an OPA data API response
+func parseResponse(data []byte) (Result, error) {
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return Result{}, fmt.Errorf("invalid policy response: %w", err)
+	}
+	if len(response.Result) == 0 || string(response.Result) == "null" {
+		return Result{Decision: Allow, Reason: "policy undefined"}, nil
+	}
+
+	var result struct {
+		Decision Decision `json:"decision"`
+		Reason   string   `json:"reason"`
+	}
+	if err := json.Unmarshal(response.Result, &result.Decision); err != nil {
+		if err := json.Unmarshal(response.Result, &result); err != nil {
+			return Result{}, fmt.Errorf("invalid policy result: %s", response.Result)
+		}
+	}
+
+	switch result.Decision {
+	case Allow, Deny, Defer:
+		return Result{Decision: result.Decision, Reason: result.Reason}, nil
+	default:
+		return Result{}, fmt.Errorf("unknown policy decision %q", result.Decision)
+	}
+}
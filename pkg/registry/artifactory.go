@@ -0,0 +1,85 @@
+package registry
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// artifactoryKeyPrefix starts JFrog Artifactory API keys, other tokens are access tokens
+const artifactoryKeyPrefix = "AKC"
+
+// artifactoryReload is the time after which the token files are re-read, so rotated tokens are picked up
+const artifactoryReload = time.Minute
+
+// artifactoryTransport authenticates the requests to Artifactory registries with the access token or API key
+// read from the file of their host. Access tokens are sent as bearer tokens, skipping the token exchange,
+// API keys as the X-JFrog-Art-Api header.
+type artifactoryTransport struct {
+	next  http.RoundTripper
+	files map[string]string // Registry host -> token file
+
+	mu     sync.Mutex
+	tokens map[string]string
+	read   time.Time
+}
+
+// newArtifactoryTransport wraps next with the tokens of files, next itself if there are none
+func newArtifactoryTransport(next http.RoundTripper, files map[string]string) http.RoundTripper {
+	if len(files) == 0 {
+		return next
+	}
+	normalized := make(map[string]string, len(files))
+	for host, file := range files {
+		normalized[strings.ToLower(strings.TrimSpace(host))] = file
+	}
+	return &artifactoryTransport{next: next, files: normalized}
+}
+
+func (t *artifactoryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := t.token(strings.ToLower(req.URL.Host))
+	if token == "" {
+		token = t.token(strings.ToLower(req.URL.Hostname()))
+	}
+	if token == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	if strings.HasPrefix(token, artifactoryKeyPrefix) {
+		req.Header.Set("X-JFrog-Art-Api", token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// token returns the token of a host, re-reading the files every artifactoryReload
+func (t *artifactoryTransport) token(host string) string {
+	if _, ok := t.files[host]; !ok {
+		return ""
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tokens == nil || time.Since(t.read) > artifactoryReload {
+		tokens := make(map[string]string, len(t.files))
+		for h, file := range t.files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				logger.Warnf("Failed to read Artifactory token of %s: %v", h, err)
+				if previous, ok := t.tokens[h]; ok {
+					tokens[h] = previous
+				}
+				continue
+			}
+			tokens[h] = strings.TrimSpace(string(data))
+		}
+		t.tokens, t.read = tokens, time.Now()
+	}
+	return t.tokens[host]
+}
@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/metrics"
+)
+
+// ErrCircuitOpen is returned for requests to a registry skipped after consecutive failures
+var ErrCircuitOpen = errors.New("registry circuit open")
+
+// circuitBreaker skips the requests to registry hosts that failed consecutively for a cooldown,
+// instead of waiting for a timeout on every image of a down registry
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*circuit
+}
+
+// circuit is the state of one registry host
+type circuit struct {
+	failures  int
+	openUntil time.Time
+}
+
+// newCircuitBreaker creates a breaker opening after threshold consecutive failures, nil (disabled) if threshold is 0
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, hosts: make(map[string]*circuit)}
+}
+
+// allow returns ErrCircuitOpen while the circuit of host is open
+// After the cooldown, requests are let through again, the next failure reopens the circuit.
+func (b *circuitBreaker) allow(host string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.hosts[host]
+	if !ok || c.openUntil.IsZero() {
+		return nil
+	}
+	if remaining := time.Until(c.openUntil); remaining > 0 {
+		return fmt.Errorf("%w: %s failed %d times, retrying in %s", ErrCircuitOpen, host, c.failures, remaining.Round(time.Second))
+	}
+	return nil
+}
+
+// record records the result of a request to host
+func (b *circuitBreaker) record(ctx context.Context, host string, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+
+	if !isRegistryOutage(ctx, err) {
+		if !c.openUntil.IsZero() {
+			logger.Infof("Registry %s is reachable again, closing its circuit", host)
+			metrics.SetRegistryCircuit(host, false)
+		}
+		c.failures, c.openUntil = 0, time.Time{}
+		return
+	}
+
+	c.failures++
+	if c.failures < b.threshold {
+		return
+	}
+	if c.openUntil.IsZero() {
+		logger.Warnf("Registry %s failed %d times in a row, skipping its images for %s: %v", host, c.failures, b.cooldown, err)
+		metrics.SetRegistryCircuit(host, true)
+	}
+	c.openUntil = time.Now().Add(b.cooldown)
+}
+
+// isRegistryOutage reports whether an error means the registry is unavailable: a connection error,
+// a timeout or a server error. Client errors such as a missing tag or denied access do not count.
+func isRegistryOutage(ctx context.Context, err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || ctx.Err() == context.Canceled {
+		return false
+	}
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	const host = "registry.example.com"
+	ctx := context.Background()
+	outage := &transport.Error{StatusCode: http.StatusServiceUnavailable}
+	b := newCircuitBreaker(2, 50*time.Millisecond)
+
+	// Closed: failures below the threshold let requests through
+	b.record(ctx, host, outage)
+	if err := b.allow(host); err != nil {
+		t.Fatalf("allow after 1/2 failures = %v, want nil", err)
+	}
+
+	// Open: the threshold skips the host for the cooldown, other hosts are unaffected
+	b.record(ctx, host, outage)
+	if err := b.allow(host); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow after 2/2 failures = %v, want ErrCircuitOpen", err)
+	}
+	if err := b.allow("other.example.com"); err != nil {
+		t.Errorf("allow for another host = %v, want nil", err)
+	}
+
+	// Half-open: after the cooldown a request is let through, its failure reopens the circuit right away
+	time.Sleep(60 * time.Millisecond)
+	if err := b.allow(host); err != nil {
+		t.Fatalf("allow after the cooldown = %v, want nil", err)
+	}
+	b.record(ctx, host, outage)
+	if err := b.allow(host); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow after a failure in half-open state = %v, want ErrCircuitOpen", err)
+	}
+
+	// Closed: a success after the cooldown resets the failures
+	time.Sleep(60 * time.Millisecond)
+	b.record(ctx, host, nil)
+	b.record(ctx, host, outage)
+	if err := b.allow(host); err != nil {
+		t.Errorf("allow after a success and 1/2 failures = %v, want nil", err)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := newCircuitBreaker(0, time.Minute)
+	if b != nil {
+		t.Fatalf("newCircuitBreaker(0) = %v, want nil", b)
+	}
+	b.record(context.Background(), "registry.example.com", errors.New("connection refused"))
+	if err := b.allow("registry.example.com"); err != nil {
+		t.Errorf("allow on a disabled breaker = %v, want nil", err)
+	}
+}
+
+func TestIsRegistryOutage(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"success", context.Background(), nil, false},
+		{"connection error", context.Background(), errors.New("dial tcp: connection refused"), true},
+		{"timeout", context.Background(), context.DeadlineExceeded, true},
+		{"server error", context.Background(), &transport.Error{StatusCode: http.StatusBadGateway}, true},
+		{"missing tag", context.Background(), &transport.Error{StatusCode: http.StatusNotFound}, false},
+		{"denied", context.Background(), &transport.Error{StatusCode: http.StatusUnauthorized}, false},
+		{"rate limited", context.Background(), &transport.Error{StatusCode: http.StatusTooManyRequests}, false},
+		{"cancelled error", context.Background(), context.Canceled, false},
+		{"cancelled context", cancelled, errors.New("request aborted"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRegistryOutage(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("isRegistryOutage(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
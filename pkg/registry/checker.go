@@ -3,29 +3,58 @@ package registry
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
-	"github.com/docker/docker/client"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/metrics"
 )
 
-// ImageChecker checks container image updates
+// ImageChecker checks container image updates against the registries
 type ImageChecker struct {
-	client *client.Client
+	transport http.RoundTripper
+	tokens    *tokenCache // nil if token caching is disabled
+	breaker   *circuitBreaker
+	limiter   *rateLimiter
+	timeout   time.Duration
+}
+
+// CheckerOptions configures the registry access of an ImageChecker
+type CheckerOptions struct {
+	// Registry hosts -> proxy URL or "direct", other registries use the proxy environment
+	Proxies map[string]string
+
+	// Registry hosts -> file holding a JFrog Artifactory access token or API key
+	Artifactory map[string]string
+
+	// Time registry tokens are reused, 0 authenticates every request anew
+	TokenTTL time.Duration
+
+	// Consecutive failures after which a registry is skipped for CircuitCooldown, 0 never skips
+	CircuitThreshold int
+	CircuitCooldown  time.Duration
+
+	// Time a registry lookup may take, 0 relies on the transport timeouts
+	Timeout time.Duration
 }
 
 // NewImageChecker creates a new image checker
-func NewImageChecker() (*ImageChecker, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+func NewImageChecker(opts CheckerOptions) (*ImageChecker, error) {
+	transport, err := newTransport(opts.Proxies)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create docker client: %w", err)
+		return nil, err
 	}
-
+	transport = &rateLimitTransport{next: newArtifactoryTransport(transport, opts.Artifactory)}
 	return &ImageChecker{
-		client: cli,
+		transport: transport,
+		tokens:    newTokenCache(opts.TokenTTL),
+		breaker:   newCircuitBreaker(opts.CircuitThreshold, opts.CircuitCooldown),
+		limiter:   newRateLimiter(),
+		timeout:   opts.Timeout,
 	}, nil
 }
 
@@ -37,23 +66,20 @@ type ImageInfo struct {
 }
 
 // ParseImage parses image string into ImageInfo
+// Supports registry ports (registry:5000/app:tag) and pinned images (app:tag@sha256:...)
 func ParseImage(image string) *ImageInfo {
 	info := &ImageInfo{
 		Tag: "latest",
 	}
 
 	// Separate digest
-	parts := strings.Split(image, "@")
-	if len(parts) > 1 {
-		info.Digest = parts[1]
-		image = parts[0]
-	}
+	image, info.Digest, _ = strings.Cut(image, "@")
 
-	// Separate tag
-	parts = strings.Split(image, ":")
-	info.Repository = parts[0]
-	if len(parts) > 1 {
-		info.Tag = parts[1]
+	// Separate tag, the last ":" after the last "/" (a ":" before it is a registry port)
+	info.Repository = image
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		info.Repository = image[:i]
+		info.Tag = image[i+1:]
 	}
 
 	return info
@@ -64,11 +90,17 @@ type RegistryCredentials struct {
 	Registry string
 	Username string
 	Password string
+	Token    string // Bearer token, used instead of username/password
+
+	Authenticator authn.Authenticator // Resolved from a keychain, used instead of the fields above
 }
 
 // CheckForUpdate checks if image has an update
 // Returns: hasUpdate (whether there is an update), remoteDigest (remote image digest), error
 func (ic *ImageChecker) CheckForUpdate(ctx context.Context, currentImage string, credentials *RegistryCredentials) (bool, string, error) {
+	ctx, cancel := ic.withTimeout(ctx)
+	defer cancel()
+
 	imageInfo := ParseImage(currentImage)
 
 	// Get remote image digest
@@ -88,16 +120,102 @@ func (ic *ImageChecker) getRemoteDigest(ctx context.Context, imageInfo *ImageInf
 
 	ref, err := name.ParseReference(imageName)
 	if err != nil {
-		logger.Fatalf("Failed to parse image name: %v", err)
+		return "", fmt.Errorf("failed to parse image name: %w", err)
+	}
+
+	// Check distribution
+	start := time.Now()
+	desc, err := ic.get(ctx, ref, credentials)
+	metrics.ObserveRegistryCheck(ref.Context().RegistryStr(), time.Since(start), err)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect distribution: %w", describeAuthError(err, credentials))
+	}
+
+	return desc.Digest.String(), nil
+}
+
+// get fetches the descriptor of ref through the circuit breaker of its registry
+func (ic *ImageChecker) get(ctx context.Context, ref name.Reference, credentials *RegistryCredentials) (*remote.Descriptor, error) {
+	host := ref.Context().RegistryStr()
+	if err := ic.allow(host); err != nil {
+		return nil, err
+	}
+	trackedCtx, rateLimited := ic.limiter.track(ctx, host)
+	desc, err := remote.Get(ref, ic.remoteOptions(trackedCtx, credentials)...)
+	ic.breaker.record(ctx, host, err)
+	if err != nil {
+		ic.forgetToken(credentials)
+	}
+	return desc, ic.describeTimeout(ctx, host, rateLimited(err))
+}
+
+// withTimeout bounds a registry lookup, including the requests of the descriptors it returns, by REGISTRY_TIMEOUT
+func (ic *ImageChecker) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ic.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, ic.timeout)
+}
+
+// describeTimeout names the registry and the timeout of a request that ran out of time
+func (ic *ImageChecker) describeTimeout(ctx context.Context, host string, err error) error {
+	if err == nil || ic.timeout <= 0 || ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	return fmt.Errorf("registry %s did not answer within %s: %w", host, ic.timeout, err)
+}
+
+// allow returns an error while the requests to host are held back by its circuit or rate limit
+func (ic *ImageChecker) allow(host string) error {
+	if err := ic.limiter.allow(host); err != nil {
+		return err
+	}
+	return ic.breaker.allow(host)
+}
+
+// list lists the tags of repo through the circuit breaker of its registry
+func (ic *ImageChecker) list(ctx context.Context, repo name.Repository, credentials *RegistryCredentials) ([]string, error) {
+	host := repo.RegistryStr()
+	if err := ic.allow(host); err != nil {
+		return nil, err
+	}
+	trackedCtx, rateLimited := ic.limiter.track(ctx, host)
+	tags, err := remote.List(repo, ic.remoteOptions(trackedCtx, credentials)...)
+	ic.breaker.record(ctx, host, err)
+	if err != nil {
+		ic.forgetToken(credentials)
 	}
+	return tags, ic.describeTimeout(ctx, host, rateLimited(err))
+}
+
+// remoteOptions returns the registry request options for credentials
+// With token caching, the requests reuse the cached puller of the credentials.
+func (ic *ImageChecker) remoteOptions(ctx context.Context, credentials *RegistryCredentials) []remote.Option {
+	options := ic.authOptions(credentials)
+	if ic.tokens != nil {
+		if key, ok := credentialsKey(credentials); ok {
+			if puller, err := ic.tokens.puller(key, options); err == nil {
+				return []remote.Option{remote.WithContext(ctx), remote.Reuse(puller)}
+			}
+		}
+	}
+	return append(options, remote.WithContext(ctx))
+}
 
-	// Prepare authentication options
+// authOptions returns the transport and authentication options for credentials
+func (ic *ImageChecker) authOptions(credentials *RegistryCredentials) []remote.Option {
 	options := []remote.Option{
-		remote.WithContext(ctx),
+		remote.WithTransport(ic.transport),
 	}
 
 	// Add authentication if credentials are provided
-	if credentials != nil && credentials.Username != "" {
+	if credentials != nil && credentials.Authenticator != nil {
+		options = append(options, remote.WithAuth(credentials.Authenticator))
+		logger.Debugf("Using keychain credentials for registry: %s", credentials.Registry)
+	} else if credentials != nil && credentials.Token != "" {
+		options = append(options, remote.WithAuth(&authn.Bearer{Token: credentials.Token}))
+		logger.Debugf("Using token for registry: %s", credentials.Registry)
+	} else if credentials != nil && credentials.Username != "" {
 		auth := &authn.Basic{
 			Username: credentials.Username,
 			Password: credentials.Password,
@@ -105,23 +223,19 @@ func (ic *ImageChecker) getRemoteDigest(ctx context.Context, imageInfo *ImageInf
 		options = append(options, remote.WithAuth(auth))
 		logger.Debugf("Using credentials for registry: %s", credentials.Registry)
 	} else {
-		// Use default keychain (can read from ~/.docker/config.json)
+		// Use default keychain (can read from ~/.docker/config.json, credsStore and credHelpers included)
 		options = append(options, remote.WithAuthFromKeychain(authn.DefaultKeychain))
 	}
 
-	// Check distribution
-	desc, err := remote.Get(ref, options...)
-	if err != nil {
-		return "", fmt.Errorf("failed to inspect distribution: %w", err)
-	}
-
-	return desc.Digest.String(), nil
+	return options
 }
 
-// Close closes the client
-func (ic *ImageChecker) Close() error {
-	if ic.client != nil {
-		return ic.client.Close()
+// forgetToken drops the cached token of credentials after a failed request, so the next request authenticates anew
+func (ic *ImageChecker) forgetToken(credentials *RegistryCredentials) {
+	if ic.tokens == nil {
+		return
+	}
+	if key, ok := credentialsKey(credentials); ok {
+		ic.tokens.forget(key)
 	}
-	return nil
 }
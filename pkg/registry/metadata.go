@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// OCI image annotation keys (also used as config labels)
+const (
+	annotationVersion  = "org.opencontainers.image.version"
+	annotationRevision = "org.opencontainers.image.revision"
+	annotationSource   = "org.opencontainers.image.source"
+	annotationCreated  = "org.opencontainers.image.created"
+)
+
+// ImageMetadata describes an image from its OCI annotations and labels
+type ImageMetadata struct {
+	Version  string
+	Revision string
+	Source   string
+	Created  time.Time
+}
+
+// String formats the metadata, e.g. "1.27.1 (built 2024-06-02, github.com/org/app)"
+func (m *ImageMetadata) String() string {
+	if m == nil {
+		return ""
+	}
+
+	var details []string
+	if !m.Created.IsZero() {
+		details = append(details, "built "+m.Created.Format("2006-01-02"))
+	}
+	if m.Revision != "" {
+		revision := m.Revision
+		if len(revision) > 12 {
+			revision = revision[:12]
+		}
+		details = append(details, revision)
+	}
+	if m.Source != "" {
+		details = append(details, strings.TrimPrefix(strings.TrimPrefix(m.Source, "https://"), "http://"))
+	}
+
+	result := m.Version
+	if len(details) > 0 {
+		result = strings.TrimSpace(fmt.Sprintf("%s (%s)", result, strings.Join(details, ", ")))
+	}
+	return result
+}
+
+// Annotations returns the metadata as OCI image annotations, nil if nothing is known
+func (m *ImageMetadata) Annotations() map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	annotations := make(map[string]string)
+	for key, value := range map[string]string{
+		annotationVersion:  m.Version,
+		annotationRevision: m.Revision,
+		annotationSource:   m.Source,
+	} {
+		if value != "" {
+			annotations[key] = value
+		}
+	}
+	if !m.Created.IsZero() {
+		annotations[annotationCreated] = m.Created.Format(time.RFC3339)
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// GetMetadata fetches the OCI metadata of repository@digest.
+// Index and manifest annotations take precedence over image config labels.
+func (ic *ImageChecker) GetMetadata(ctx context.Context, repository, digest string, credentials *RegistryCredentials) (*ImageMetadata, error) {
+	ctx, cancel := ic.withTimeout(ctx)
+	defer cancel()
+
+	ref, err := name.ParseReference(fmt.Sprintf("%s@%s", repository, digest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image name: %w", err)
+	}
+
+	desc, err := ic.get(ctx, ref, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect distribution: %w", err)
+	}
+
+	values := make(map[string]string)
+	merge := func(source map[string]string) {
+		for _, key := range []string{annotationVersion, annotationRevision, annotationSource, annotationCreated} {
+			if values[key] == "" && source[key] != "" {
+				values[key] = source[key]
+			}
+		}
+	}
+
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image index: %w", err)
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get index manifest: %w", err)
+		}
+		merge(manifest.Annotations)
+	}
+
+	// Resolves an index to the default platform image
+	image, err := desc.Image()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	manifest, err := image.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest: %w", err)
+	}
+	merge(manifest.Annotations)
+
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image config: %w", err)
+	}
+	merge(configFile.Config.Labels)
+
+	metadata := &ImageMetadata{
+		Version:  values[annotationVersion],
+		Revision: values[annotationRevision],
+		Source:   values[annotationSource],
+		Created:  configFile.Created.Time,
+	}
+	if value := values[annotationCreated]; value != "" {
+		if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+			metadata.Created = parsed
+		}
+	}
+	return metadata, nil
+}
@@ -0,0 +1,76 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// PlatformResolver is implemented by resolvers that can list the platforms of an image
+type PlatformResolver interface {
+	// Platforms lists the platforms ("os/arch") of repository@digest, nil if unknown
+	Platforms(ctx context.Context, repository, digest string, credentials *RegistryCredentials) ([]string, error)
+}
+
+var (
+	_ PlatformResolver = (*ImageChecker)(nil)
+	_ PlatformResolver = (*ResolverRouter)(nil)
+)
+
+// Platforms lists the platforms of an index, or the platform of a single image from its config
+// Attestation manifests (unknown/unknown) are left out.
+func (ic *ImageChecker) Platforms(ctx context.Context, repository, digest string, credentials *RegistryCredentials) ([]string, error) {
+	ctx, cancel := ic.withTimeout(ctx)
+	defer cancel()
+
+	ref, err := name.ParseReference(fmt.Sprintf("%s@%s", repository, digest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image name: %w", err)
+	}
+
+	desc, err := ic.get(ctx, ref, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect distribution: %w", err)
+	}
+
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image index: %w", err)
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get index manifest: %w", err)
+		}
+		var platforms []string
+		for _, entry := range manifest.Manifests {
+			if entry.Platform == nil || entry.Platform.OS == "unknown" || entry.Platform.OS == "" {
+				continue
+			}
+			platforms = append(platforms, entry.Platform.OS+"/"+entry.Platform.Architecture)
+		}
+		return platforms, nil
+	}
+
+	image, err := desc.Image()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image config: %w", err)
+	}
+	if configFile.OS == "" || configFile.Architecture == "" {
+		return nil, nil
+	}
+	return []string{configFile.OS + "/" + configFile.Architecture}, nil
+}
+
+// Platforms lists the platforms with the resolver of the repository, nil if it cannot list them
+func (r *ResolverRouter) Platforms(ctx context.Context, repository, digest string, credentials *RegistryCredentials) ([]string, error) {
+	if resolver, ok := r.resolverFor(repository).(PlatformResolver); ok {
+		return resolver.Platforms(ctx, repository, digest, credentials)
+	}
+	return nil, nil
+}
@@ -0,0 +1,60 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// proxyDirect disables the proxy for a registry
+const proxyDirect = "direct"
+
+// newTransport returns the registry transport. Registries listed in proxies (host -> proxy URL or "direct")
+// use their own proxy, all others the HTTP(S)_PROXY / NO_PROXY environment.
+func newTransport(proxies map[string]string) (http.RoundTripper, error) {
+	if len(proxies) == 0 {
+		return remote.DefaultTransport, nil
+	}
+
+	overrides := make(map[string]*url.URL, len(proxies))
+	for host, value := range proxies {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if value == proxyDirect {
+			overrides[host] = nil
+			continue
+		}
+		proxyURL, err := url.Parse(value)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q for registry %s", value, host)
+		}
+		overrides[host] = proxyURL
+	}
+
+	transport := remote.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxyURL, ok := matchProxy(overrides, req.URL.Hostname()); ok {
+			return proxyURL, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+	return transport, nil
+}
+
+// matchProxy finds the override for host or its closest parent domain
+// (e.g. "docker.io" also covers registry-1.docker.io and auth.docker.io)
+func matchProxy(overrides map[string]*url.URL, host string) (*url.URL, bool) {
+	host = strings.ToLower(host)
+	for {
+		if proxyURL, ok := overrides[host]; ok {
+			return proxyURL, true
+		}
+		idx := strings.Index(host, ".")
+		if idx == -1 {
+			return nil, false
+		}
+		host = host[idx+1:]
+	}
+}
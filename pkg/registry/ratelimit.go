@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// ErrRateLimited is returned for requests to a registry backing off after a 429 response
+var ErrRateLimited = errors.New("rate limited by registry")
+
+// defaultRetryAfter is the backoff of a 429 response without a valid Retry-After header
+const defaultRetryAfter = time.Minute
+
+// maxRetryAfter bounds the backoff requested by a registry
+const maxRetryAfter = 6 * time.Hour
+
+// rateLimitKey is the context key of the rateLimitResponse of a request
+type rateLimitKey struct{}
+
+// rateLimitResponse receives the Retry-After of a 429 response to the requests of one call
+type rateLimitResponse struct {
+	mu         sync.Mutex
+	retryAfter time.Duration
+}
+
+// rateLimiter holds back the requests to registries that answered 429 until their Retry-After passed
+type rateLimiter struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{until: make(map[string]time.Time)}
+}
+
+// allow returns ErrRateLimited while host is backing off
+func (l *rateLimiter) allow(host string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if remaining := time.Until(l.until[host]); remaining > 0 {
+		return fmt.Errorf("%w: %s, retrying after %s", ErrRateLimited, host, remaining.Round(time.Second))
+	}
+	return nil
+}
+
+// track returns a context recording the 429 responses of a call and a function applying them to host
+// The function wraps err with ErrRateLimited if the call was rate limited.
+func (l *rateLimiter) track(ctx context.Context, host string) (context.Context, func(err error) error) {
+	response := &rateLimitResponse{}
+	return context.WithValue(ctx, rateLimitKey{}, response), func(err error) error {
+		response.mu.Lock()
+		retryAfter := response.retryAfter
+		response.mu.Unlock()
+		if retryAfter == 0 || err == nil {
+			return err
+		}
+
+		l.mu.Lock()
+		until := time.Now().Add(retryAfter)
+		if until.After(l.until[host]) {
+			l.until[host] = until
+		}
+		l.mu.Unlock()
+		logger.Warnf("Registry %s is rate limiting, backing off for %s", host, retryAfter)
+		return fmt.Errorf("%w: %s, retrying after %s: %w", ErrRateLimited, host, retryAfter, err)
+	}
+}
+
+// rateLimitTransport reports the Retry-After of 429 responses to the rateLimitResponse of the request
+type rateLimitTransport struct {
+	next http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		return resp, err
+	}
+	if response, ok := req.Context().Value(rateLimitKey{}).(*rateLimitResponse); ok {
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		response.mu.Lock()
+		if retryAfter > response.retryAfter {
+			response.retryAfter = retryAfter
+		}
+		response.mu.Unlock()
+	}
+	return resp, err
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or as HTTP date, defaultRetryAfter if it is missing or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		retryAfter = date.Sub(now)
+	}
+	switch {
+	case retryAfter <= 0:
+		return defaultRetryAfter
+	case retryAfter > maxRetryAfter:
+		return maxRetryAfter
+	}
+	return retryAfter
+}
@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"delta seconds", "120", 2 * time.Minute},
+		{"one second", "1", time.Second},
+		{"HTTP date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"RFC 850 date", now.Add(time.Hour).Format(time.RFC850), time.Hour},
+		{"missing", "", defaultRetryAfter},
+		{"zero", "0", defaultRetryAfter},
+		{"negative", "-30", defaultRetryAfter},
+		{"date in the past", now.Add(-time.Minute).Format(http.TimeFormat), defaultRetryAfter},
+		{"fractional seconds", "1.5", defaultRetryAfter},
+		{"garbage", "soon", defaultRetryAfter},
+		{"capped seconds", "86400", maxRetryAfter},
+		{"capped date", now.Add(24 * time.Hour).Format(http.TimeFormat), maxRetryAfter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/limited" {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &rateLimitTransport{next: http.DefaultTransport}}
+	failed := errors.New("request failed")
+
+	limiter := newRateLimiter()
+	get := func(path string) (int, error) {
+		ctx, apply := limiter.track(context.Background(), "registry.example.com")
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, apply(failed)
+		}
+		return resp.StatusCode, apply(nil)
+	}
+
+	if _, err := get("/ok"); err != nil {
+		t.Fatalf("successful call = %v, want nil", err)
+	}
+	if err := limiter.allow("registry.example.com"); err != nil {
+		t.Fatalf("allow after a successful call = %v, want nil", err)
+	}
+
+	if _, err := get("/limited"); !errors.Is(err, ErrRateLimited) || !errors.Is(err, failed) {
+		t.Fatalf("rate limited call = %v, want ErrRateLimited wrapping the call error", err)
+	}
+	if err := limiter.allow("registry.example.com"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("allow while backing off = %v, want ErrRateLimited", err)
+	}
+	if err := limiter.allow("other.example.com"); err != nil {
+		t.Errorf("allow for another host = %v, want nil", err)
+	}
+	if remaining := time.Until(limiter.until["registry.example.com"]); remaining <= 25*time.Second || remaining > 30*time.Second {
+		t.Errorf("backoff = %s, want the Retry-After of 30s", remaining)
+	}
+}
@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// ImageResolver resolves remote digests, newer tags and metadata of images
+type ImageResolver interface {
+	// CheckForUpdate resolves the remote digest of an image's tag, the caller compares it with the running digest
+	CheckForUpdate(ctx context.Context, currentImage string, credentials *RegistryCredentials) (bool, string, error)
+
+	// NewerTags lists the version tags of a repository newer than currentTag, newest first
+	NewerTags(ctx context.Context, repository, currentTag string, credentials *RegistryCredentials) ([]string, error)
+
+	// GetMetadata reads the OCI metadata of an image, nil if it has none
+	GetMetadata(ctx context.Context, repository, digest string, credentials *RegistryCredentials) (*ImageMetadata, error)
+}
+
+var (
+	_ ImageResolver = (*ImageChecker)(nil)
+	_ ImageResolver = (*ResolverRouter)(nil)
+)
+
+// ResolverRouter selects the ImageResolver of an image by repository prefix,
+// falling back to a default resolver for all other images
+type ResolverRouter struct {
+	fallback ImageResolver
+	routes   []resolverRoute // Longest prefix first
+}
+
+// resolverRoute is a custom resolver for a repository prefix
+type resolverRoute struct {
+	prefix   string
+	resolver ImageResolver
+}
+
+// NewResolverRouter creates a router resolving unmatched images with fallback
+func NewResolverRouter(fallback ImageResolver) *ResolverRouter {
+	return &ResolverRouter{fallback: fallback}
+}
+
+// Register resolves the images whose repository (as written in the pod spec) starts with prefix with resolver
+// A prefix is a registry host ("artifacts.internal") or a host and path ("ghcr.io/org"), the longest match wins.
+func (r *ResolverRouter) Register(prefix string, resolver ImageResolver) {
+	prefix = strings.TrimSuffix(strings.ToLower(prefix), "/")
+	r.routes = append(r.routes, resolverRoute{prefix: prefix, resolver: resolver})
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return len(r.routes[i].prefix) > len(r.routes[j].prefix)
+	})
+}
+
+// resolverFor selects the resolver of a repository
+func (r *ResolverRouter) resolverFor(repository string) ImageResolver {
+	repository = strings.ToLower(repository)
+	for _, route := range r.routes {
+		if repository == route.prefix || strings.HasPrefix(repository, route.prefix+"/") {
+			return route.resolver
+		}
+	}
+	return r.fallback
+}
+
+// CheckForUpdate resolves the remote digest with the resolver of the image
+func (r *ResolverRouter) CheckForUpdate(ctx context.Context, currentImage string, credentials *RegistryCredentials) (bool, string, error) {
+	return r.resolverFor(ParseImage(currentImage).Repository).CheckForUpdate(ctx, currentImage, credentials)
+}
+
+// NewerTags lists the newer tags with the resolver of the repository
+func (r *ResolverRouter) NewerTags(ctx context.Context, repository, currentTag string, credentials *RegistryCredentials) ([]string, error) {
+	return r.resolverFor(repository).NewerTags(ctx, repository, currentTag, credentials)
+}
+
+// GetMetadata reads the metadata with the resolver of the repository
+func (r *ResolverRouter) GetMetadata(ctx context.Context, repository, digest string, credentials *RegistryCredentials) (*ImageMetadata, error) {
+	return r.resolverFor(repository).GetMetadata(ctx, repository, digest, credentials)
+}
@@ -0,0 +1,49 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Robot account kinds
+const (
+	RobotQuay   = "quay"
+	RobotHarbor = "harbor"
+)
+
+// quayRobotPattern matches Quay robot account names, <organization or user>+<robot>
+var quayRobotPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*\+[a-z0-9_]+$`)
+
+// RobotKind returns the robot account kind of a registry username, empty for other users
+// Harbor robot accounts are named robot$<name> or robot$<project>+<name>.
+func RobotKind(username string) string {
+	switch {
+	case strings.HasPrefix(username, "robot$"):
+		return RobotHarbor
+	case quayRobotPattern.MatchString(username):
+		return RobotQuay
+	}
+	return ""
+}
+
+// describeAuthError explains a rejected robot account, whose token may have expired or lack the repository permission
+func describeAuthError(err error, credentials *RegistryCredentials) error {
+	if credentials == nil || credentials.Username == "" {
+		return err
+	}
+	kind := RobotKind(credentials.Username)
+	if kind == "" {
+		return err
+	}
+
+	var terr *transport.Error
+	if !errors.As(err, &terr) || (terr.StatusCode != http.StatusUnauthorized && terr.StatusCode != http.StatusForbidden) {
+		return err
+	}
+	return fmt.Errorf("%w (%s robot account %s was rejected: expired, disabled or without pull permission on the repository)", err, kind, credentials.Username)
+}
@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// version is a parsed semantic version tag ("1.2", "v1.2.3"); pre-release tags are not versions
+type version struct {
+	parts []int // major, minor[, patch]
+}
+
+// parseVersion parses a tag as a semantic version
+func parseVersion(tag string) (version, bool) {
+	fields := strings.Split(strings.TrimPrefix(tag, "v"), ".")
+	if len(fields) < 2 || len(fields) > 3 {
+		return version{}, false
+	}
+
+	v := version{parts: make([]int, len(fields))}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return version{}, false
+		}
+		v.parts[i] = n
+	}
+	return v, true
+}
+
+// less compares two versions, missing parts count as 0
+func (v version) less(other version) bool {
+	for i := 0; i < 3; i++ {
+		a, b := 0, 0
+		if i < len(v.parts) {
+			a = v.parts[i]
+		}
+		if i < len(other.parts) {
+			b = other.parts[i]
+		}
+		if a != b {
+			return a < b
+		}
+	}
+	return false
+}
+
+// IsVersionTag checks if a tag is a semantic version (e.g. "1.27" or "v1.27.1")
+func IsVersionTag(tag string) bool {
+	_, ok := parseVersion(tag)
+	return ok
+}
+
+// NewerTags lists the version tags of a repository newer than currentTag, newest first.
+// With a non-version currentTag (e.g. an image pinned by digest) all version tags are returned.
+func (ic *ImageChecker) NewerTags(ctx context.Context, repository, currentTag string, credentials *RegistryCredentials) ([]string, error) {
+	ctx, cancel := ic.withTimeout(ctx)
+	defer cancel()
+
+	repo, err := name.NewRepository(repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository: %w", err)
+	}
+
+	tags, err := ic.list(ctx, repo, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", describeAuthError(err, credentials))
+	}
+
+	current, hasCurrent := parseVersion(currentTag)
+
+	type candidate struct {
+		tag     string
+		version version
+	}
+	var newer []candidate
+	for _, tag := range tags {
+		v, ok := parseVersion(tag)
+		if !ok || (hasCurrent && !current.less(v)) {
+			continue
+		}
+		newer = append(newer, candidate{tag: tag, version: v})
+	}
+
+	sort.Slice(newer, func(i, j int) bool {
+		return newer[j].version.less(newer[i].version)
+	})
+
+	result := make([]string, len(newer))
+	for i, c := range newer {
+		result[i] = c.tag
+	}
+	return result, nil
+}
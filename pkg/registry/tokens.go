@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// keychainKey is the token cache key of requests authenticated by the default keychain
+const keychainKey = "keychain"
+
+// tokenCache reuses registry token exchanges across checks. Each set of credentials has a puller,
+// which keeps an authenticated transport per repository scope and refreshes its token when the registry rejects it.
+// Pullers are replaced after the TTL and after a failed request, so a failed token exchange is retried.
+type tokenCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	pullers map[string]*cachedPuller
+}
+
+// cachedPuller is the puller of one set of credentials
+type cachedPuller struct {
+	puller  *remote.Puller
+	created time.Time
+}
+
+// newTokenCache creates a token cache keeping pullers for ttl, nil (no caching) if ttl is 0
+func newTokenCache(ttl time.Duration) *tokenCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &tokenCache{ttl: ttl, pullers: make(map[string]*cachedPuller)}
+}
+
+// puller returns the cached puller of key, creating one from options if there is none or it expired
+func (c *tokenCache) puller(key string, options []remote.Option) (*remote.Puller, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.pullers[key]; ok && time.Since(cached.created) < c.ttl {
+		return cached.puller, nil
+	}
+	puller, err := remote.NewPuller(options...)
+	if err != nil {
+		return nil, err
+	}
+	c.pullers[key] = &cachedPuller{puller: puller, created: time.Now()}
+	return puller, nil
+}
+
+// forget drops the puller of key
+func (c *tokenCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pullers, key)
+}
+
+// credentialsKey identifies credentials in the token cache without keeping secrets in memory as keys
+// Returns false for credentials that cannot be identified.
+func credentialsKey(credentials *RegistryCredentials) (string, bool) {
+	if credentials == nil {
+		return keychainKey, true
+	}
+
+	var fields []string
+	switch {
+	case credentials.Authenticator != nil:
+		auth, err := credentials.Authenticator.Authorization()
+		if err != nil {
+			return "", false
+		}
+		fields = []string{"auth", auth.Username, auth.Password, auth.Auth, auth.IdentityToken, auth.RegistryToken}
+	case credentials.Token != "":
+		fields = []string{"token", credentials.Token}
+	case credentials.Username != "":
+		fields = []string{"basic", credentials.Username, credentials.Password}
+	default:
+		return keychainKey, true
+	}
+
+	hash := sha256.New()
+	for _, field := range fields {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
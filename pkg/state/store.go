@@ -0,0 +1,474 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// dataKey is the ConfigMap key holding the serialized state
+const dataKey = "state.json"
+
+// promotionLimit is the number of digests per image whose promotion is remembered
+const promotionLimit = 10
+
+// ContainerState stores the persisted state of a single container
+type ContainerState struct {
+	RemoteDigest   string    `json:"remoteDigest,omitempty"`   // Last seen remote digest
+	NotifiedDigest string    `json:"notifiedDigest,omitempty"` // Last digest reported as detected
+	LastChecked    time.Time `json:"lastChecked,omitempty"`
+	LastUpdated    time.Time `json:"lastUpdated,omitempty"`
+	Failures       int       `json:"failures,omitempty"` // Consecutive failures
+	LastFailed     time.Time `json:"lastFailed,omitempty"`
+	LastError      string    `json:"lastError,omitempty"`
+	SkippedDigest  string    `json:"skippedDigest,omitempty"` // Digest rolled back from, not re-applied
+	AdvisedTag     string    `json:"advisedTag,omitempty"`    // Newest tag reported by the newer-tag advisory
+}
+
+// UpdateRecord stores one update attempt in the history
+type UpdateRecord struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Container string    `json:"container"`
+	OldImage  string    `json:"oldImage"`
+	NewImage  string    `json:"newImage"`
+	OldDigest string    `json:"oldDigest,omitempty"`
+	NewDigest string    `json:"newDigest,omitempty"`
+	Success   bool      `json:"success"`
+	Rollback  bool      `json:"rollback,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Snapshots []string  `json:"snapshots,omitempty"` // VolumeSnapshots taken before the update
+	Cycle     string    `json:"cycle,omitempty"`     // Check cycle or manual update that applied it
+
+	// Helm release that installed the workload, its next upgrade reverts the update
+	HelmRelease string `json:"helmRelease,omitempty"`
+
+	// OCI metadata of the new image
+	Version  string `json:"version,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Created  string `json:"created,omitempty"` // RFC 3339
+}
+
+// ID identifies a record in the history, derived from its time and container
+func (r UpdateRecord) ID() string {
+	sum := sha256.Sum256([]byte(r.Time.UTC().Format(time.RFC3339Nano) + " " + Key(r.Namespace, r.Kind, r.Name, r.Container)))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Promotion stores the progress of a digest through the promotion stages
+type Promotion struct {
+	Image  string               `json:"image"` // repository:tag
+	Digest string               `json:"digest"`
+	Stages map[string]time.Time `json:"stages,omitempty"` // First rollout or sighting per stage, the start of its soak
+	Failed string               `json:"failed,omitempty"` // Why the promotion was halted
+}
+
+// State is the persisted watcher state
+type State struct {
+	Containers map[string]*ContainerState `json:"containers"`
+	History    []UpdateRecord             `json:"history,omitempty"`
+	Promotions []Promotion                `json:"promotions,omitempty"`
+}
+
+// Store persists watcher state in a ConfigMap
+type Store struct {
+	client       *k8s.Client
+	namespace    string
+	name         string
+	historyLimit int
+
+	mu    sync.Mutex
+	state State
+}
+
+// NewStore creates a new state store, returns nil if name is empty (disabled)
+// All methods are safe to call on a nil Store
+func NewStore(client *k8s.Client, namespace, name string, historyLimit int) *Store {
+	if name == "" {
+		return nil
+	}
+	logger.Infof("Using state store: %s/%s", namespace, name)
+	return &Store{
+		client:       client,
+		namespace:    namespace,
+		name:         name,
+		historyLimit: historyLimit,
+		state:        State{Containers: make(map[string]*ContainerState)},
+	}
+}
+
+// Key builds the state key of a container
+func Key(namespace, kind, name, container string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", namespace, kind, name, container)
+}
+
+// Load reads the state from the ConfigMap
+func (s *Store) Load(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	data, err := s.client.GetConfigMapData(ctx, s.namespace, s.name)
+	if err != nil {
+		return err
+	}
+
+	state := State{Containers: make(map[string]*ContainerState)}
+	if raw, ok := data[dataKey]; ok && raw != "" {
+		if err := json.Unmarshal([]byte(raw), &state); err != nil {
+			return fmt.Errorf("failed to parse state: %w", err)
+		}
+		if state.Containers == nil {
+			state.Containers = make(map[string]*ContainerState)
+		}
+	}
+
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+	return nil
+}
+
+// Save writes the state to the ConfigMap
+func (s *Store) Save(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	raw, err := json.Marshal(s.state)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to serialize state: %w", err)
+	}
+
+	return s.client.SaveConfigMapData(ctx, s.namespace, s.name, map[string]string{
+		dataKey: string(raw),
+	})
+}
+
+// Container returns a copy of the state of a container
+func (s *Store) Container(key string) ContainerState {
+	if s == nil {
+		return ContainerState{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cs, ok := s.state.Containers[key]; ok {
+		return *cs
+	}
+	return ContainerState{}
+}
+
+// RecordCheck records a successful registry check
+func (s *Store) RecordCheck(key, remoteDigest string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cs := s.container(key)
+	cs.RemoteDigest = remoteDigest
+	cs.LastChecked = time.Now()
+}
+
+// RecordNotified records that an update to digest has been reported
+func (s *Store) RecordNotified(key, digest string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.container(key).NotifiedDigest = digest
+}
+
+// RecordAdvised records the newest tag reported by the newer-tag advisory
+func (s *Store) RecordAdvised(key, tag string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.container(key).AdvisedTag = tag
+}
+
+// Prune forgets the state of the containers whose key is not in keys, e.g. of deleted workloads
+// Called after a full check cycle with the keys of every listed container.
+func (s *Store) Prune(keys map[string]bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.state.Containers {
+		if !keys[key] {
+			delete(s.state.Containers, key)
+		}
+	}
+}
+
+// ResetFailures clears the failure counter of a container
+func (s *Store) ResetFailures(key string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cs, ok := s.state.Containers[key]; ok {
+		cs.Failures = 0
+		cs.LastError = ""
+	}
+}
+
+// RecordFailure increments the failure counter of a container
+func (s *Store) RecordFailure(key string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cs := s.container(key)
+	cs.Failures++
+	cs.LastFailed = time.Now()
+	if err != nil {
+		cs.LastError = err.Error()
+	}
+}
+
+// RecordUpdate records an update attempt and appends it to the history
+func (s *Store) RecordUpdate(key string, record UpdateRecord) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs := s.container(key)
+	if record.Success {
+		cs.LastUpdated = record.Time
+		cs.NotifiedDigest = record.NewDigest
+		cs.Failures = 0
+		cs.LastError = ""
+	} else {
+		cs.Failures++
+		cs.LastFailed = record.Time
+		cs.LastError = record.Error
+	}
+
+	s.appendHistory(record)
+}
+
+// RecordRollback records a rollback and stops the rolled back digest from being re-applied
+func (s *Store) RecordRollback(key string, record UpdateRecord) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.Rollback = true
+	if record.Success {
+		cs := s.container(key)
+		cs.SkippedDigest = record.OldDigest
+		cs.NotifiedDigest = record.OldDigest
+		cs.LastUpdated = record.Time
+		s.failPromotion(record.OldDigest, fmt.Sprintf("rolled back in %s/%s", record.Namespace, record.Name))
+	}
+
+	s.appendHistory(record)
+}
+
+// LastUpdate returns the successful updates of the containers changed by the most recent update of a workload,
+// optionally restricted to one container
+// Containers updated in the same check cycle belong to one update
+func (s *Store) LastUpdate(namespace, kind, name, container string) []UpdateRecord {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []UpdateRecord
+	seen := make(map[string]bool)
+	for i := len(s.state.History) - 1; i >= 0; i-- {
+		record := s.state.History[i]
+		if record.Namespace != namespace || record.Kind != kind || record.Name != name {
+			continue
+		}
+		if container != "" && record.Container != container {
+			continue
+		}
+		if !record.Success || record.Rollback || seen[record.Container] {
+			continue
+		}
+		if len(records) > 0 && (record.Cycle == "" || record.Cycle != records[0].Cycle) {
+			break
+		}
+		records = append(records, record)
+		seen[record.Container] = true
+		if container != "" {
+			break
+		}
+	}
+	return records
+}
+
+// SkipDigest stops a digest from being re-applied to a container, set when a rollback starts
+func (s *Store) SkipDigest(key, digest string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.container(key).SkippedDigest = digest
+}
+
+// LastAttempt returns the most recent update or rollback of a container, successful or not
+func (s *Store) LastAttempt(namespace, kind, name, container string) (UpdateRecord, bool) {
+	if s == nil {
+		return UpdateRecord{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.state.History) - 1; i >= 0; i-- {
+		record := s.state.History[i]
+		if record.Namespace == namespace && record.Kind == kind && record.Name == name && record.Container == container {
+			return record, true
+		}
+	}
+	return UpdateRecord{}, false
+}
+
+// RecordPromotion records that a digest of image runs in a stage, keeping the time it was first seen there
+func (s *Store) RecordPromotion(image, digest, stage string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.state.Promotions {
+		p := &s.state.Promotions[i]
+		if p.Image != image || p.Digest != digest {
+			continue
+		}
+		if p.Stages == nil {
+			p.Stages = make(map[string]time.Time)
+		}
+		if _, ok := p.Stages[stage]; !ok {
+			p.Stages[stage] = time.Now()
+		}
+		return
+	}
+
+	s.state.Promotions = append(s.state.Promotions, Promotion{
+		Image:  image,
+		Digest: digest,
+		Stages: map[string]time.Time{stage: time.Now()},
+	})
+	s.trimPromotions(image)
+}
+
+// FailPromotion halts the promotion of a digest
+func (s *Store) FailPromotion(digest, reason string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failPromotion(digest, reason)
+}
+
+// Promotions returns a copy of the promotions of image, oldest first
+func (s *Store) Promotions(image string) []Promotion {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var promotions []Promotion
+	for _, p := range s.state.Promotions {
+		if p.Image != image {
+			continue
+		}
+		stages := make(map[string]time.Time, len(p.Stages))
+		for stage, t := range p.Stages {
+			stages[stage] = t
+		}
+		p.Stages = stages
+		promotions = append(promotions, p)
+	}
+	return promotions
+}
+
+// failPromotion halts the promotion of a digest, keeping the first reason (caller holds mu)
+func (s *Store) failPromotion(digest, reason string) {
+	for i := range s.state.Promotions {
+		p := &s.state.Promotions[i]
+		if p.Digest == digest && p.Failed == "" {
+			p.Failed = reason
+		}
+	}
+}
+
+// trimPromotions drops the oldest promotions of image beyond promotionLimit (caller holds mu)
+func (s *Store) trimPromotions(image string) {
+	count := 0
+	for _, p := range s.state.Promotions {
+		if p.Image == image {
+			count++
+		}
+	}
+	if count <= promotionLimit {
+		return
+	}
+
+	promotions := s.state.Promotions[:0]
+	for _, p := range s.state.Promotions {
+		if p.Image == image && count > promotionLimit {
+			count--
+			continue
+		}
+		promotions = append(promotions, p)
+	}
+	s.state.Promotions = promotions
+}
+
+// History returns a copy of the update history, oldest first
+func (s *Store) History() []UpdateRecord {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]UpdateRecord(nil), s.state.History...)
+}
+
+// appendHistory appends a record, trimming the history to its limit (caller holds mu)
+func (s *Store) appendHistory(record UpdateRecord) {
+	s.state.History = append(s.state.History, record)
+	if s.historyLimit > 0 && len(s.state.History) > s.historyLimit {
+		s.state.History = s.state.History[len(s.state.History)-s.historyLimit:]
+	}
+}
+
+// container returns the state of a container, creating it if needed (caller holds mu)
+func (s *Store) container(key string) *ContainerState {
+	cs, ok := s.state.Containers[key]
+	if !ok {
+		cs = &ContainerState{}
+		s.state.Containers[key] = cs
+	}
+	return cs
+}
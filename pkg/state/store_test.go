@@ -0,0 +1,97 @@
+package state
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSkippedDigestPersistence(t *testing.T) {
+	const (
+		rolledBack = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		previous   = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+		newer      = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+	)
+	key := Key("team-a", "Deployment", "web", "app")
+	record := UpdateRecord{
+		Time:      time.Now(),
+		Namespace: "team-a",
+		Kind:      "Deployment",
+		Name:      "web",
+		Container: "app",
+		OldImage:  "registry.example.com/web:1.0@" + rolledBack,
+		NewImage:  "registry.example.com/web:1.0@" + previous,
+		OldDigest: rolledBack,
+		NewDigest: previous,
+	}
+
+	tests := []struct {
+		name  string
+		apply func(s *Store)
+		want  string
+	}{
+		{
+			name:  "skipped when the rollback starts",
+			apply: func(s *Store) { s.SkipDigest(key, rolledBack) },
+			want:  rolledBack,
+		},
+		{
+			name: "recorded by a successful rollback",
+			apply: func(s *Store) {
+				r := record
+				r.Success = true
+				s.RecordRollback(key, r)
+			},
+			want: rolledBack,
+		},
+		{
+			name: "kept by a failed rollback",
+			apply: func(s *Store) {
+				s.SkipDigest(key, rolledBack)
+				r := record
+				r.Error = "rollout failed"
+				s.RecordRollback(key, r)
+			},
+			want: rolledBack,
+		},
+		{
+			name: "kept by later checks and updates",
+			apply: func(s *Store) {
+				s.SkipDigest(key, rolledBack)
+				s.RecordCheck(key, rolledBack)
+				s.RecordUpdate(key, UpdateRecord{Time: time.Now(), Success: true, NewDigest: newer})
+			},
+			want: rolledBack,
+		},
+		{
+			name:  "replaced by the next rollback",
+			apply: func(s *Store) { s.SkipDigest(key, rolledBack); s.SkipDigest(key, newer) },
+			want:  newer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := k8s.NewClientFromClientset(fake.NewSimpleClientset(), nil, k8s.ClientOptions{})
+
+			store := NewStore(client, "kube-watchtower", "kube-watchtower-state", 10)
+			tt.apply(store)
+			if err := store.Save(ctx); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			// A restarted watcher reads the skipped digest back
+			restarted := NewStore(client, "kube-watchtower", "kube-watchtower-state", 10)
+			if err := restarted.Load(ctx); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if got := restarted.Container(key).SkippedDigest; got != tt.want {
+				t.Errorf("SkippedDigest after reload = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package watcher
+
+import (
+	"context"
+	"strings"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/notifier"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+)
+
+// maxAdvisedTags bounds the number of newer tags listed per image
+const maxAdvisedTags = 5
+
+// isDigestPinned checks if an image is pinned by digest without a tag (e.g. nginx@sha256:...),
+// such images are never updated automatically
+func isDigestPinned(image string) bool {
+	name, _, found := strings.Cut(image, "@")
+	if !found {
+		return false
+	}
+	// A tag follows the last ":" after the last "/" (a ":" before it is a registry port)
+	return !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":")
+}
+
+// adviseNewerTags reports the newer version tags of an image pinned by digest or version tag,
+// once per newest tag
+func (w *Watcher) adviseNewerTags(ctx context.Context, nsConfig *config.NamespaceConfig, source notifier.Source, stateKey string, container k8s.ContainerInfo, credentials *registry.RegistryCredentials) {
+	pinned := isDigestPinned(container.Image)
+	if !pinned && !registry.IsVersionTag(container.Tag) {
+		return
+	}
+
+	currentTag := container.Tag
+	if pinned {
+		currentTag = ""
+	}
+
+	repository := registry.ParseImage(container.Image).Repository
+	tags, err := w.imageChecker.NewerTags(ctx, repository, currentTag, credentials)
+	if err != nil {
+		logger.Debugf("  Failed to list newer tags of %s: %v", repository, err)
+		return
+	}
+	if len(tags) == 0 {
+		return
+	}
+
+	logger.Infof("Newer tags available for %s: %s", container.Image, strings.Join(tags, ", "))
+
+	if w.store.Container(stateKey).AdvisedTag == tags[0] {
+		logger.Debugf("  Newer tag %s already reported, not notifying again", tags[0])
+		return
+	}
+	if len(tags) > maxAdvisedTags {
+		tags = append(tags[:maxAdvisedTags], "...")
+	}
+	w.addAdvisory(nsConfig, source, container.Image, tags)
+	w.store.RecordAdvised(stateKey, tags[0])
+}
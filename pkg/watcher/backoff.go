@@ -0,0 +1,30 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/state"
+)
+
+// maxFailureBackoff caps the wait before a failing container is checked again
+const maxFailureBackoff = 24 * time.Hour
+
+// failureBackoff returns until when a container with consecutive failures is not checked again,
+// FAILURE_BACKOFF after the first failure and doubled with every further one
+// The zero time means the container is checked now.
+func (w *Watcher) failureBackoff(cs state.ContainerState) time.Time {
+	if w.config.FailureBackoff <= 0 || cs.Failures == 0 || cs.LastFailed.IsZero() {
+		return time.Time{}
+	}
+
+	backoff := w.config.FailureBackoff
+	for i := 1; i < cs.Failures && backoff < maxFailureBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxFailureBackoff)
+
+	if until := cs.LastFailed.Add(backoff); time.Now().Before(until) {
+		return until
+	}
+	return time.Time{}
+}
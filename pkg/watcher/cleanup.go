@@ -0,0 +1,176 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cleanupJobTimeout bounds a node cleanup Job
+const cleanupJobTimeout = 2 * time.Minute
+
+// workloadNodes returns the nodes running the workload,
+// nil when neither cleanup nor node image discovery is enabled
+func (w *Watcher) workloadNodes(ctx context.Context, workload k8s.WorkloadInfo) []string {
+	if (!w.config.Cleanup && !w.config.NodeImageDiscovery) || workload.Selector == nil {
+		return nil
+	}
+
+	nodes, err := w.k8sClient.GetPodNodes(ctx, workload.Namespace, workload.Selector)
+	if err != nil {
+		logger.Warnf("Failed to get nodes of %s/%s: %v", workload.Namespace, workload.Name, err)
+		return nil
+	}
+	return nodes
+}
+
+// cleanupImage removes a superseded image from nodes, one Job per node.
+// Failures are logged only, the update itself already succeeded.
+func (w *Watcher) cleanupImage(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, nodes []string) {
+	if len(nodes) == 0 || container.CurrentDigest == "" {
+		return
+	}
+
+	// Only target nodes that may still cache the old digest
+	repository := registry.ParseImage(container.Image).Repository
+	if cached := w.nodesWithImage(ctx, repository, container.CurrentDigest); cached != nil {
+		var targets []string
+		for _, node := range nodes {
+			if _, ok := cached[node]; ok {
+				targets = append(targets, node)
+			}
+		}
+		nodes = targets
+		if len(nodes) == 0 {
+			logger.Debugf("Image %s@%s is no longer listed in the node status of any node", repository, container.CurrentDigest)
+			return
+		}
+	}
+
+	image := fmt.Sprintf("%s@%s", repository, container.CurrentDigest)
+	logger.Infof("Removing image %s from %d node(s)", image, len(nodes))
+
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+			job := w.buildCleanupJob(workload, image, node)
+			if err := w.k8sClient.RunJob(ctx, job, cleanupJobTimeout); err != nil {
+				logger.Warnf("Failed to remove image %s from node %s: %v", image, node, err)
+			}
+		}(node)
+	}
+	wg.Wait()
+}
+
+// buildCleanupJob builds a privileged Job removing image from node via the host's crictl
+func (w *Watcher) buildCleanupJob(workload k8s.WorkloadInfo, image, node string) *batchv1.Job {
+	privileged := true
+	backoffLimit := int32(0)
+	ttl := jobTTLSeconds
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-cleanup-", workload.Name),
+			Namespace:    w.config.PodNamespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "kube-watchtower",
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					NodeName:      node,
+					HostPID:       true,
+					RestartPolicy: corev1.RestartPolicyNever,
+					Tolerations:   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					Containers: []corev1.Container{{
+						Name:  "cleanup",
+						Image: w.config.CleanupImage,
+						Command: []string{
+							"nsenter", "-t", "1", "-m", "--",
+							"crictl", "rmi", image,
+						},
+						SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// nodesWithImage returns the nodes that may cache repository@digest: true if their node status lists it,
+// false if their list is truncated without it. Nil when node image discovery is disabled or failed.
+// Best effort: the kubelet reports at most --node-status-max-images images per node, see k8s.NodeImages.
+func (w *Watcher) nodesWithImage(ctx context.Context, repository, digest string) map[string]bool {
+	if !w.config.NodeImageDiscovery {
+		return nil
+	}
+
+	nodeImages, err := w.k8sClient.GetNodeImages(ctx)
+	if err != nil {
+		logger.Warnf("Failed to get node images: %v", err)
+		return nil
+	}
+
+	nodes := make(map[string]bool)
+	for name, images := range nodeImages {
+		if images.Has(repository, digest) {
+			nodes[name] = true
+		} else if images.Truncated {
+			nodes[name] = false
+		}
+	}
+	return nodes
+}
+
+// logCachedNodes logs on how many of the workload's nodes the new image is already cached
+func (w *Watcher) logCachedNodes(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newDigest string, nodes []string) {
+	cached := w.nodesWithImage(ctx, registry.ParseImage(container.Image).Repository, newDigest)
+	if cached == nil || len(nodes) == 0 {
+		return
+	}
+
+	count, unknown := 0, 0
+	for _, node := range nodes {
+		listed, ok := cached[node]
+		switch {
+		case listed:
+			count++
+		case ok:
+			unknown++
+		}
+	}
+	if unknown > 0 {
+		logger.Infof("New image %s is cached on at least %d/%d node(s) of %s/%s (best effort from the node status, %d report a truncated image list)", shortDigest(newDigest), count, len(nodes), workload.Namespace, workload.Name, unknown)
+		return
+	}
+	logger.Infof("New image %s is cached on %d/%d node(s) of %s/%s (best effort from the node status)", shortDigest(newDigest), count, len(nodes), workload.Namespace, workload.Name)
+}
+
+// pruneRevisions deletes old ReplicaSets / ControllerRevisions of the workload beyond CleanupRevisions.
+// Failures are logged only, the update itself already succeeded.
+func (w *Watcher) pruneRevisions(ctx context.Context, workload k8s.WorkloadInfo) {
+	if !w.config.Cleanup || w.config.CleanupRevisions < 0 {
+		return
+	}
+
+	deleted, err := w.k8sClient.PruneRevisions(ctx, workload.Type, workload.Namespace, workload.Name, w.config.CleanupRevisions)
+	if err != nil {
+		logger.Warnf("Failed to prune old revisions of %s/%s: %v", workload.Namespace, workload.Name, err)
+	}
+	if deleted > 0 {
+		logger.Infof("Pruned %d old revision(s) of %s/%s (%s)", deleted, workload.Namespace, workload.Name, workload.Type)
+	}
+}
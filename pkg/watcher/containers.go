@@ -0,0 +1,26 @@
+package watcher
+
+import (
+	"strings"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+)
+
+// annotationIgnoreContainers lists container name patterns of a workload (comma separated) that are not checked,
+// e.g. sidecars injected into the pods and managed by their own control plane
+const annotationIgnoreContainers = "kube-watchtower.io/ignore-containers"
+
+// isContainerIgnored checks if the workload annotations exclude a container
+func isContainerIgnored(annotations map[string]string, container string) bool {
+	value := annotations[annotationIgnoreContainers]
+	if value == "" {
+		return false
+	}
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return config.MatchAnyPattern(patterns, container)
+}
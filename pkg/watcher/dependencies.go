@@ -0,0 +1,256 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// annotationDependsOn lists the workloads ("namespace/name" or "name" in the same namespace)
+// that must be updated before the annotated workload
+const annotationDependsOn = "kube-watchtower.io/depends-on"
+
+// workloadKey identifies a workload by namespace and name
+func workloadKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// parseDependencies returns the dependency keys of a workload
+func parseDependencies(workload k8s.WorkloadInfo) []string {
+	var deps []string
+	for _, dep := range strings.Split(workload.Annotations[annotationDependsOn], ",") {
+		dep = strings.TrimSpace(dep)
+		if dep == "" {
+			continue
+		}
+		if !strings.Contains(dep, "/") {
+			dep = workloadKey(workload.Namespace, dep)
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+// dependencyGraph orders the workloads of a check cycle so that dependencies and earlier
+// update groups are checked before their dependents, and lets dependents wait for them
+type dependencyGraph struct {
+	deps    map[string][]string // Dependencies of each workload key
+	cycles  map[string]string   // Workload key -> cycle description
+	pending map[string]int      // Workloads per key not yet done
+	ok      map[string]bool     // Whether all workloads of a key updated successfully
+
+	groups       []string       // Update groups in order
+	group        map[string]int // Workload key -> update group position
+	groupPending []int          // Workloads per update group not yet done
+	groupOK      []bool         // Whether all workloads of an update group were checked without a failed update
+
+	mu   sync.Mutex
+	cond *sync.Cond
+}
+
+// newDependencyGraph builds the graph and returns the workloads in dependency and update group order.
+// Workloads in a dependency cycle are moved to the end and never updated.
+func newDependencyGraph(workloads []k8s.WorkloadInfo, groups []string, defaultGroup string) (*dependencyGraph, []k8s.WorkloadInfo) {
+	g := &dependencyGraph{
+		deps:         make(map[string][]string),
+		cycles:       make(map[string]string),
+		pending:      make(map[string]int),
+		ok:           make(map[string]bool),
+		groups:       groups,
+		group:        make(map[string]int),
+		groupPending: make([]int, len(groups)),
+		groupOK:      make([]bool, len(groups)),
+	}
+	g.cond = sync.NewCond(&g.mu)
+	for i := range g.groupOK {
+		g.groupOK[i] = true
+	}
+
+	byKey := make(map[string][]k8s.WorkloadInfo)
+	var keys []string
+	for _, workload := range workloads {
+		key := workloadKey(workload.Namespace, workload.Name)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+			g.ok[key] = true
+			g.group[key] = updateGroup(workload, groups, defaultGroup)
+		}
+		byKey[key] = append(byKey[key], workload)
+		g.pending[key]++
+		if group := g.group[key]; group >= 0 {
+			g.groupPending[group]++
+		}
+	}
+
+	// Workloads of an update group are sorted after those of the previous group
+	groupKeys := make([][]string, len(groups))
+	for _, key := range keys {
+		if group := g.group[key]; group >= 0 {
+			groupKeys[group] = append(groupKeys[group], key)
+		}
+	}
+	previousGroup := func(key string) []string {
+		group := g.group[key]
+		for group--; group >= 0; group-- {
+			if len(groupKeys[group]) > 0 {
+				return groupKeys[group]
+			}
+		}
+		return nil
+	}
+
+	for _, key := range keys {
+		for _, workload := range byKey[key] {
+			for _, dep := range parseDependencies(workload) {
+				if _, ok := byKey[dep]; !ok {
+					logger.Warnf("Ignoring dependency %s of %s (workload not monitored)", dep, key)
+					continue
+				}
+				g.deps[key] = append(g.deps[key], dep)
+			}
+		}
+	}
+
+	// Depth-first topological sort with cycle detection
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int)
+	var ordered, cyclic []string
+	var path []string
+
+	var visit func(key string)
+	visit = func(key string) {
+		switch marks[key] {
+		case visited:
+			return
+		case visiting:
+			// Report the cycle from its first occurrence on the path
+			start := 0
+			for i, k := range path {
+				if k == key {
+					start = i
+				}
+			}
+			cycle := strings.Join(append(append([]string{}, path[start:]...), key), " -> ")
+			for _, k := range path[start:] {
+				if _, ok := g.cycles[k]; !ok {
+					g.cycles[k] = cycle
+				}
+			}
+			return
+		}
+
+		marks[key] = visiting
+		path = append(path, key)
+		for _, dep := range g.deps[key] {
+			visit(dep)
+		}
+		for _, dep := range previousGroup(key) {
+			visit(dep)
+		}
+		path = path[:len(path)-1]
+		marks[key] = visited
+
+		if _, inCycle := g.cycles[key]; inCycle {
+			cyclic = append(cyclic, key)
+		} else {
+			ordered = append(ordered, key)
+		}
+	}
+	for _, key := range keys {
+		visit(key)
+	}
+
+	// Workloads depending on a cycle can never be updated either
+	for _, key := range ordered {
+		for _, dep := range g.deps[key] {
+			if cycle, ok := g.cycles[dep]; ok {
+				g.cycles[key] = cycle
+				break
+			}
+		}
+	}
+
+	// Workloads in a cycle are checked last, their update group cannot wait for them and fails
+	for _, key := range cyclic {
+		if group := g.group[key]; group >= 0 {
+			g.groupPending[group] -= g.pending[key]
+			g.groupOK[group] = false
+			g.group[key] = -1
+		}
+	}
+
+	result := make([]k8s.WorkloadInfo, 0, len(workloads))
+	for _, key := range append(ordered, cyclic...) {
+		if cycle, ok := g.cycles[key]; ok {
+			logger.Errorf("Dependency cycle detected for %s: %s", key, cycle)
+		}
+		result = append(result, byKey[key]...)
+	}
+	return g, result
+}
+
+// wait blocks until all dependencies of the workload are done and returns
+// why the workload must not be updated, or "" when it may be updated
+func (g *dependencyGraph) wait(ctx context.Context, workload k8s.WorkloadInfo) string {
+	key := workloadKey(workload.Namespace, workload.Name)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if cycle, ok := g.cycles[key]; ok {
+		return fmt.Sprintf("dependency cycle %s", cycle)
+	}
+
+	for _, dep := range g.deps[key] {
+		for g.pending[dep] > 0 && ctx.Err() == nil {
+			logger.Debugf("Waiting for dependency %s of %s", dep, key)
+			g.cond.Wait()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err().Error()
+		}
+		if !g.ok[dep] {
+			return fmt.Sprintf("dependency %s was not updated successfully", dep)
+		}
+	}
+
+	for group := 0; group < g.group[key]; group++ {
+		for g.groupPending[group] > 0 && ctx.Err() == nil {
+			logger.Debugf("Waiting for update group %s before %s", g.groups[group], key)
+			g.cond.Wait()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err().Error()
+		}
+		if !g.groupOK[group] {
+			return fmt.Sprintf("update group %s had failed updates or unchecked workloads", g.groups[group])
+		}
+	}
+	return ""
+}
+
+// done marks the workload as done, ok reports whether it was updated successfully
+// completed reports whether it was checked completely without a failed update, its update group only halts
+// the later ones otherwise: a deferred update is applied in a later check and does not hold back the rollout.
+func (g *dependencyGraph) done(workload k8s.WorkloadInfo, ok, completed bool) {
+	key := workloadKey(workload.Namespace, workload.Name)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pending[key]--
+	g.ok[key] = g.ok[key] && ok
+	if group := g.group[key]; group >= 0 {
+		g.groupPending[group]--
+		g.groupOK[group] = g.groupOK[group] && completed
+	}
+	g.cond.Broadcast()
+}
@@ -0,0 +1,208 @@
+package watcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+)
+
+// testWorkload builds a Deployment with annotations as key=value pairs
+func testWorkload(namespace, name string, annotations ...string) k8s.WorkloadInfo {
+	workload := k8s.WorkloadInfo{
+		Type:        k8s.WorkloadTypeDeployment,
+		Namespace:   namespace,
+		Name:        name,
+		Annotations: make(map[string]string),
+	}
+	for _, annotation := range annotations {
+		key, value, _ := strings.Cut(annotation, "=")
+		workload.Annotations[key] = value
+	}
+	return workload
+}
+
+func TestNewDependencyGraph(t *testing.T) {
+	dependsOn := annotationDependsOn + "="
+
+	tests := []struct {
+		name       string
+		workloads  []k8s.WorkloadInfo
+		want       []string        // Workload keys in check order
+		wantCycles map[string]bool // Workload keys never updated because of a cycle
+	}{
+		{
+			name:      "no dependencies keep their order",
+			workloads: []k8s.WorkloadInfo{testWorkload("ns", "a"), testWorkload("ns", "b")},
+			want:      []string{"ns/a", "ns/b"},
+		},
+		{
+			name: "dependencies first",
+			workloads: []k8s.WorkloadInfo{
+				testWorkload("ns", "web", dependsOn+"api"),
+				testWorkload("ns", "api", dependsOn+"db"),
+				testWorkload("ns", "db"),
+			},
+			want: []string{"ns/db", "ns/api", "ns/web"},
+		},
+		{
+			name: "dependency in another namespace",
+			workloads: []k8s.WorkloadInfo{
+				testWorkload("app", "web", dependsOn+"data/db"),
+				testWorkload("data", "db"),
+			},
+			want: []string{"data/db", "app/web"},
+		},
+		{
+			name: "several dependencies",
+			workloads: []k8s.WorkloadInfo{
+				testWorkload("ns", "web", dependsOn+"api, cache"),
+				testWorkload("ns", "api"),
+				testWorkload("ns", "cache"),
+			},
+			want: []string{"ns/api", "ns/cache", "ns/web"},
+		},
+		{
+			name:      "unmonitored dependency is ignored",
+			workloads: []k8s.WorkloadInfo{testWorkload("ns", "web", dependsOn+"missing")},
+			want:      []string{"ns/web"},
+		},
+		{
+			name: "cycle is checked last",
+			workloads: []k8s.WorkloadInfo{
+				testWorkload("ns", "a", dependsOn+"b"),
+				testWorkload("ns", "b", dependsOn+"a"),
+				testWorkload("ns", "c"),
+			},
+			want:       []string{"ns/c", "ns/b", "ns/a"},
+			wantCycles: map[string]bool{"ns/a": true, "ns/b": true},
+		},
+		{
+			name:       "self dependency is a cycle",
+			workloads:  []k8s.WorkloadInfo{testWorkload("ns", "a", dependsOn+"a")},
+			want:       []string{"ns/a"},
+			wantCycles: map[string]bool{"ns/a": true},
+		},
+		{
+			name: "dependent of a cycle is never updated",
+			workloads: []k8s.WorkloadInfo{
+				testWorkload("ns", "a", dependsOn+"b"),
+				testWorkload("ns", "b", dependsOn+"a"),
+				testWorkload("ns", "web", dependsOn+"a"),
+			},
+			want:       []string{"ns/web", "ns/b", "ns/a"},
+			wantCycles: map[string]bool{"ns/a": true, "ns/b": true, "ns/web": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, ordered := newDependencyGraph(tt.workloads, nil, "")
+
+			got := make([]string, len(ordered))
+			for i, workload := range ordered {
+				got[i] = workloadKey(workload.Namespace, workload.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+			for _, workload := range ordered {
+				key := workloadKey(workload.Namespace, workload.Name)
+				_, inCycle := g.cycles[key]
+				if inCycle != tt.wantCycles[key] {
+					t.Errorf("%s in cycle = %v, want %v", key, inCycle, tt.wantCycles[key])
+				}
+				if inCycle {
+					if reason := g.wait(context.Background(), workload); !strings.HasPrefix(reason, "dependency cycle ") {
+						t.Errorf("wait(%s) = %q, want a dependency cycle", key, reason)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDependencyGraphWait(t *testing.T) {
+	tests := []struct {
+		name      string
+		workloads []k8s.WorkloadInfo
+		ok        bool // Outcome of the first workload
+		completed bool
+		want      string // Why the second workload must not be updated
+	}{
+		{
+			name:      "dependency updated",
+			workloads: []k8s.WorkloadInfo{testWorkload("ns", "db"), testWorkload("ns", "api", annotationDependsOn+"=db")},
+			ok:        true,
+			completed: true,
+			want:      "",
+		},
+		{
+			name:      "failed dependency releases its dependent",
+			workloads: []k8s.WorkloadInfo{testWorkload("ns", "db"), testWorkload("ns", "api", annotationDependsOn+"=db")},
+			ok:        false,
+			completed: false,
+			want:      "dependency ns/db was not updated successfully",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, ordered := newDependencyGraph(tt.workloads, nil, "")
+			first, second := ordered[0], ordered[1]
+			if reason := g.wait(context.Background(), first); reason != "" {
+				t.Fatalf("wait(%s) = %q, want no wait", first.Name, reason)
+			}
+
+			// The dependent waits until the first workload is done
+			result := make(chan string, 1)
+			go func() { result <- g.wait(context.Background(), second) }()
+			select {
+			case reason := <-result:
+				t.Fatalf("wait(%s) returned %q before %s was done", second.Name, reason, first.Name)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			g.done(first, tt.ok, tt.completed)
+			select {
+			case reason := <-result:
+				if reason != tt.want {
+					t.Errorf("wait(%s) = %q, want %q", second.Name, reason, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("wait(%s) still blocked after %s was done", second.Name, first.Name)
+			}
+		})
+	}
+}
+
+func TestDependencyGraphWaitForSharedName(t *testing.T) {
+	// A dependency name shared by a Deployment and a StatefulSet is done once both are
+	db := testWorkload("ns", "db")
+	dbSet := testWorkload("ns", "db")
+	dbSet.Type = k8s.WorkloadTypeStatefulSet
+	api := testWorkload("ns", "api", annotationDependsOn+"=db")
+	g, _ := newDependencyGraph([]k8s.WorkloadInfo{db, dbSet, api}, nil, "")
+
+	result := make(chan string, 1)
+	go func() { result <- g.wait(context.Background(), api) }()
+
+	g.done(db, true, true)
+	select {
+	case reason := <-result:
+		t.Fatalf("wait(api) returned %q with one db still pending", reason)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	g.done(dbSet, true, true)
+	select {
+	case reason := <-result:
+		if reason != "" {
+			t.Errorf("wait(api) = %q, want no reason", reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wait(api) still blocked after both db workloads were done")
+	}
+}
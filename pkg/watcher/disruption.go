@@ -0,0 +1,36 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// blockingPDBs describes the PodDisruptionBudgets covering a workload that allow no disruptions, "" if there are none
+// Updates are deferred while a budget is exhausted, e.g. by a node drain or another rollout, rather than starting
+// a rollout that stalls.
+func (w *Watcher) blockingPDBs(ctx context.Context, workload k8s.WorkloadInfo) string {
+	if workload.Selector == nil {
+		// CronJobs have no running pods to disrupt
+		return ""
+	}
+	statuses, err := w.k8sClient.GetPDBStatuses(ctx, workload.Namespace, workload.Selector)
+	if err != nil {
+		logger.Debugf("Unable to check PodDisruptionBudgets for %s/%s: %v", workload.Namespace, workload.Name, err)
+		return ""
+	}
+
+	var blocking []string
+	for _, status := range statuses {
+		if status.DisruptionsAllowed <= 0 {
+			blocking = append(blocking, fmt.Sprintf("%s (%d/%d healthy)", status.Name, status.CurrentHealthy, status.DesiredHealthy))
+		}
+	}
+	if len(blocking) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("PodDisruptionBudget %s allows no disruptions", strings.Join(blocking, ", "))
+}
@@ -0,0 +1,22 @@
+// Package watcher is the update engine of kube-watchtower: it lists workloads, resolves the remote digests
+// of their images and rolls out the updates, notifying about the results.
+//
+// Programs embedding the engine build a config.Config (config.LoadConfig reads the environment) and replace
+// any of the components through Options:
+//
+//	client := k8s.NewClientFromClientset(clientset, restConfig, k8s.ClientOptions{})
+//	w, err := watcher.New(cfg, watcher.Options{
+//		Client:   client,
+//		Resolver: myResolver, // registry.ImageResolver
+//		Notifier: myNotifier, // watcher.Notifier
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//	err = w.CheckOnce(ctx)
+//
+// Nil components default to the Kubernetes client (k8s.WorkloadLister, k8s.Updater), the
+// go-containerregistry based registry.ImageChecker and a notifier for cfg.NotificationURL. Delivery to other
+// destinations is added with notifier.RegisterBackend, keeping the summaries, retries and rate limit.
+package watcher
@@ -0,0 +1,28 @@
+package watcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// drainContext returns the context of in-flight operations of a check cycle.
+// When ctx is cancelled (e.g. on SIGTERM) no new updates are started, but running ones
+// may finish until DrainTimeout expires.
+func (w *Watcher) drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	w.draining.Store(ctx.Err() != nil)
+
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stopDrain := context.AfterFunc(ctx, func() {
+		w.draining.Store(true)
+		logger.Infof("Shutting down, waiting up to %s for in-flight updates", w.config.DrainTimeout)
+		timer := time.AfterFunc(w.config.DrainTimeout, cancel)
+		context.AfterFunc(drainCtx, func() { timer.Stop() })
+	})
+
+	return drainCtx, func() {
+		stopDrain()
+		cancel()
+	}
+}
@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// Event types
+const (
+	EventCheckStarted     = "check-started"
+	EventCheckCompleted   = "check-completed"
+	EventUpdateAvailable  = "update-available"
+	EventUpdateDeferred   = "update-deferred"
+	EventRolloutStarted   = "rollout-started"
+	EventRolloutCompleted = "rollout-completed"
+	EventUpdateApplied    = "update-applied"
+	EventUpdateFailed     = "update-failed"
+	EventRollback         = "rollback"
+	EventDigestSkew       = "digest-skew"
+)
+
+// eventBuffer is the number of events buffered per subscriber, further events are dropped
+const eventBuffer = 64
+
+// Event is a real-time event of the watcher
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
+	Workload  string    `json:"workload,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Image     string    `json:"image,omitempty"`
+	Digest    string    `json:"digest,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// eventBroker fans events out to subscribers
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// Subscribe returns a channel receiving the events of the watcher and a function ending the subscription
+// Events are dropped while the subscriber's buffer is full.
+func (w *Watcher) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBuffer)
+
+	w.events.mu.Lock()
+	if w.events.subscribers == nil {
+		w.events.subscribers = make(map[chan Event]struct{})
+	}
+	w.events.subscribers[ch] = struct{}{}
+	w.events.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			w.events.mu.Lock()
+			delete(w.events.subscribers, ch)
+			w.events.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish sends an event to all subscribers without blocking
+func (w *Watcher) publish(event Event) {
+	event.Time = time.Now()
+	event.Cluster = w.config.KubeContext
+
+	w.events.mu.Lock()
+	defer w.events.mu.Unlock()
+	for ch := range w.events.subscribers {
+		select {
+		case ch <- event:
+		default:
+			logger.Debugf("Event subscriber is too slow, dropped %s event", event.Type)
+		}
+	}
+}
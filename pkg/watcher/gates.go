@@ -0,0 +1,190 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/metrics"
+	"github.com/qetesh/kube-watchtower/pkg/policy"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+)
+
+// shouldDefer runs the checks that may hold back an available update
+// metadata describes the new image, nil if unknown
+// Returns the reason and true if the update must not be applied in this cycle
+func (w *Watcher) shouldDefer(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage, newDigest string, metadata *registry.ImageMetadata, credentials *registry.RegistryCredentials) (string, bool) {
+	if reason, deferred := w.checkPause(workload); deferred {
+		return reason, true
+	}
+	if reason, deferred := checkMixedDigests(workload, container); deferred {
+		return reason, true
+	}
+	if reason, deferred := w.checkPolicy(ctx, workload, container, newImage, newDigest, metadata); deferred {
+		return reason, true
+	}
+	if reason, deferred := w.checkHPA(ctx, workload); deferred {
+		return reason, true
+	}
+	if reason, deferred := w.checkKEDA(ctx, workload); deferred {
+		return reason, true
+	}
+	if reason, deferred := w.checkPlatforms(ctx, workload, newImage, newDigest, credentials); deferred {
+		return reason, true
+	}
+	return "", false
+}
+
+// checkMixedDigests defers updates while replicas run different digests,
+// the workload is mid-rollout or suffers node cache skew that an update would only confuse
+func checkMixedDigests(workload k8s.WorkloadInfo, container k8s.ContainerInfo) (string, bool) {
+	if len(container.RunningDigests) <= 1 {
+		return "", false
+	}
+	metrics.ObserveMixedDigestDeferral(workload.Namespace, string(workload.Type), workload.Name)
+	return fmt.Sprintf("replicas run different digests: %s", formatDigestCounts(container.RunningDigests)), true
+}
+
+// checkHPA defers updates while an HPA attached to the workload is scaling,
+// so rollouts and scale events don't fight over ReplicaSets
+func (w *Watcher) checkHPA(ctx context.Context, workload k8s.WorkloadInfo) (string, bool) {
+	if w.config.HPAStabilizationWindow <= 0 || workload.Type == k8s.WorkloadTypeDaemonSet {
+		return "", false
+	}
+
+	hpa, err := w.k8sClient.GetHPAStatus(ctx, workload.Type, workload.Namespace, workload.Name)
+	if err != nil {
+		logger.Debugf("Unable to check HPA for %s/%s: %v", workload.Namespace, workload.Name, err)
+		return "", false
+	}
+	if hpa == nil {
+		return "", false
+	}
+
+	if hpa.DesiredReplicas != hpa.CurrentReplicas {
+		return fmt.Sprintf("HPA %s is scaling (%d -> %d replicas)", hpa.Name, hpa.CurrentReplicas, hpa.DesiredReplicas), true
+	}
+	if !hpa.LastScaleTime.IsZero() && time.Since(hpa.LastScaleTime) < w.config.HPAStabilizationWindow {
+		return fmt.Sprintf("HPA %s scaled %s ago", hpa.Name, time.Since(hpa.LastScaleTime).Round(time.Second)), true
+	}
+	return "", false
+}
+
+// checkKEDA defers updates while a KEDA ScaledObject moves the workload from or to zero replicas,
+// which its HPA (checked by checkHPA) does not report
+// An idle workload about to be scaled to zero only waits with SCALED_TO_ZERO, which applies the update to the
+// template once it is at zero; otherwise it would never be updated.
+func (w *Watcher) checkKEDA(ctx context.Context, workload k8s.WorkloadInfo) (string, bool) {
+	if w.config.HPAStabilizationWindow <= 0 || workload.Type == k8s.WorkloadTypeDaemonSet {
+		return "", false
+	}
+
+	scaledObject, err := w.k8sClient.GetScaledObjectStatus(ctx, workload.Type, workload.Namespace, workload.Name)
+	if err != nil {
+		logger.Debugf("Unable to check KEDA ScaledObject for %s/%s: %v", workload.Namespace, workload.Name, err)
+		return "", false
+	}
+	if scaledObject == nil || scaledObject.Paused {
+		return "", false
+	}
+
+	switch {
+	case scaledObject.Active && workload.Replicas == 0:
+		return fmt.Sprintf("KEDA ScaledObject %s is scaling up from zero", scaledObject.Name), true
+	case w.config.ScaledToZero && !scaledObject.Active && scaledObject.MinReplicas == 0 && workload.Replicas > 0:
+		return fmt.Sprintf("KEDA ScaledObject %s is idle and scales to zero", scaledObject.Name), true
+	}
+	return "", false
+}
+
+// checkPlatforms defers updates whose new image lacks a platform of the nodes running the workload,
+// e.g. an amd64-only push that would break the arm64 half of a mixed node pool
+// Platforms that cannot be determined don't hold back the update.
+func (w *Watcher) checkPlatforms(ctx context.Context, workload k8s.WorkloadInfo, newImage, newDigest string, credentials *registry.RegistryCredentials) (string, bool) {
+	resolver, ok := w.imageChecker.(registry.PlatformResolver)
+	if !w.config.PlatformCheck || !ok || workload.Selector == nil {
+		return "", false
+	}
+
+	used, err := w.k8sClient.GetPodPlatforms(ctx, workload.Namespace, workload.Selector)
+	if err != nil {
+		logger.Debugf("Unable to get the node platforms of %s/%s: %v", workload.Namespace, workload.Name, err)
+		return "", false
+	}
+	if len(used) == 0 {
+		return "", false
+	}
+	available, err := resolver.Platforms(ctx, registry.ParseImage(newImage).Repository, newDigest, credentials)
+	if err != nil {
+		logger.Warnf("Unable to get the platforms of %s: %v", newImage, err)
+		return "", false
+	}
+	if len(available) == 0 {
+		return "", false
+	}
+
+	provided := make(map[string]bool, len(available))
+	for _, platform := range available {
+		provided[platform] = true
+	}
+	var missing []string
+	for _, platform := range used {
+		if !provided[platform] {
+			missing = append(missing, platform)
+		}
+	}
+	if len(missing) == 0 {
+		return "", false
+	}
+	logger.Warnf("New image of %s/%s lacks platforms %s used by its nodes (has %s)", workload.Namespace, workload.Name, strings.Join(missing, ", "), strings.Join(available, ", "))
+	return fmt.Sprintf("new image lacks platforms %s used by the nodes", strings.Join(missing, ", ")), true
+}
+
+// checkPolicy evaluates the update policy, failing closed when it cannot be evaluated
+func (w *Watcher) checkPolicy(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage, newDigest string, metadata *registry.ImageMetadata) (string, bool) {
+	if w.policy == nil {
+		return "", false
+	}
+
+	now := time.Now()
+	result, err := w.policy.Evaluate(ctx, policy.Input{
+		Time:        now,
+		Weekday:     now.Weekday().String(),
+		Cluster:     w.config.NotificationCluster,
+		Namespace:   workload.Namespace,
+		Kind:        string(workload.Type),
+		Name:        workload.Name,
+		Container:   container.Name,
+		OldImage:    container.Image,
+		NewImage:    newImage,
+		OldDigest:   container.CurrentDigest,
+		NewDigest:   newDigest,
+		Annotations: workload.Annotations,
+		Image:       metadata.Annotations(),
+	})
+	if err != nil {
+		logger.Warnf("Policy evaluation failed for %s/%s: %v", workload.Namespace, workload.Name, err)
+		return fmt.Sprintf("policy evaluation failed: %v", err), true
+	}
+
+	logger.Debugf("  Policy decision: %s %s", result.Decision, result.Reason)
+	switch result.Decision {
+	case policy.Deny:
+		return policyReason("denied by policy", result.Reason), true
+	case policy.Defer:
+		return policyReason("deferred by policy", result.Reason), true
+	default:
+		return "", false
+	}
+}
+
+// policyReason formats a policy decision with its optional reason
+func policyReason(decision, reason string) string {
+	if reason == "" {
+		return decision
+	}
+	return fmt.Sprintf("%s: %s", decision, reason)
+}
@@ -0,0 +1,31 @@
+package watcher
+
+import (
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// annotationUpdateGroup assigns a workload to one of the UpdateGroups,
+// whose updates run in order within a check cycle
+const annotationUpdateGroup = "kube-watchtower.io/update-group"
+
+// updateGroup returns the position of the workload's update group in groups,
+// -1 if the workload belongs to no configured group
+func updateGroup(workload k8s.WorkloadInfo, groups []string, defaultGroup string) int {
+	group, ok := workload.Annotations[annotationUpdateGroup]
+	if !ok {
+		group = defaultGroup
+	}
+	if group == "" {
+		return -1
+	}
+	for i, g := range groups {
+		if g == group {
+			return i
+		}
+	}
+	if ok {
+		logger.Warnf("Ignoring %s %q of %s/%s (not in UPDATE_GROUPS)", annotationUpdateGroup, group, workload.Namespace, workload.Name)
+	}
+	return -1
+}
@@ -0,0 +1,154 @@
+package watcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+)
+
+func TestUpdateGroup(t *testing.T) {
+	groups := []string{"canary", "prod"}
+	tests := []struct {
+		name         string
+		annotation   string // Empty for no annotation
+		defaultGroup string
+		want         int
+	}{
+		{"annotated group", "prod", "", 1},
+		{"annotation wins over default", "canary", "prod", 0},
+		{"default group", "", "prod", 1},
+		{"no group", "", "", -1},
+		{"unknown group", "staging", "prod", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := testWorkload("ns", "app")
+			if tt.annotation != "" {
+				workload.Annotations[annotationUpdateGroup] = tt.annotation
+			}
+			if got := updateGroup(workload, groups, tt.defaultGroup); got != tt.want {
+				t.Errorf("updateGroup(%q, default %q) = %d, want %d", tt.annotation, tt.defaultGroup, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateGroupOrder(t *testing.T) {
+	inGroup := annotationUpdateGroup + "="
+	groups := []string{"canary", "general", "critical"}
+
+	tests := []struct {
+		name         string
+		workloads    []k8s.WorkloadInfo
+		defaultGroup string
+		want         []string // Workload keys in check order
+	}{
+		{
+			name: "groups in configured order",
+			workloads: []k8s.WorkloadInfo{
+				testWorkload("ns", "db", inGroup+"critical"),
+				testWorkload("ns", "web", inGroup+"general"),
+				testWorkload("ns", "preview", inGroup+"canary"),
+			},
+			want: []string{"ns/preview", "ns/web", "ns/db"},
+		},
+		{
+			name: "workloads without a group keep their place",
+			workloads: []k8s.WorkloadInfo{
+				testWorkload("ns", "db", inGroup+"critical"),
+				testWorkload("ns", "other"),
+				testWorkload("ns", "preview", inGroup+"canary"),
+			},
+			want: []string{"ns/preview", "ns/db", "ns/other"},
+		},
+		{
+			name: "default group",
+			workloads: []k8s.WorkloadInfo{
+				testWorkload("ns", "web"),
+				testWorkload("ns", "preview", inGroup+"canary"),
+			},
+			defaultGroup: "general",
+			want:         []string{"ns/preview", "ns/web"},
+		},
+		{
+			name: "empty group is skipped",
+			workloads: []k8s.WorkloadInfo{
+				testWorkload("ns", "db", inGroup+"critical"),
+				testWorkload("ns", "preview", inGroup+"canary"),
+			},
+			want: []string{"ns/preview", "ns/db"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ordered := newDependencyGraph(tt.workloads, groups, tt.defaultGroup)
+
+			got := make([]string, len(ordered))
+			for i, workload := range ordered {
+				got[i] = workloadKey(workload.Namespace, workload.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateGroupWait(t *testing.T) {
+	canary := testWorkload("ns", "preview", annotationUpdateGroup+"=canary")
+	prod := testWorkload("ns", "web", annotationUpdateGroup+"=prod")
+
+	tests := []struct {
+		name      string
+		ok        bool // Outcome of the canary workload
+		completed bool
+		want      string // Why the prod workload must not be updated
+	}{
+		{"earlier group updated", true, true, ""},
+		{"deferred update does not halt the later groups", false, true, ""},
+		{"failed update halts the later groups", false, false, "update group canary had failed updates or unchecked workloads"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, _ := newDependencyGraph([]k8s.WorkloadInfo{prod, canary}, []string{"canary", "prod"}, "")
+
+			// The later group waits until the earlier one is done
+			result := make(chan string, 1)
+			go func() { result <- g.wait(context.Background(), prod) }()
+			select {
+			case reason := <-result:
+				t.Fatalf("wait(prod) returned %q before the canary group was done", reason)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			g.done(canary, tt.ok, tt.completed)
+			select {
+			case reason := <-result:
+				if reason != tt.want {
+					t.Errorf("wait(prod) = %q, want %q", reason, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("wait(prod) still blocked after the canary group was done")
+			}
+		})
+	}
+}
+
+func TestUpdateGroupCycle(t *testing.T) {
+	// A workload of the canary group in a dependency cycle is never checked, the later groups are halted
+	a := testWorkload("ns", "a", annotationUpdateGroup+"=canary", annotationDependsOn+"=b")
+	b := testWorkload("ns", "b", annotationDependsOn+"=a")
+	prod := testWorkload("ns", "web", annotationUpdateGroup+"=prod")
+	g, _ := newDependencyGraph([]k8s.WorkloadInfo{a, b, prod}, []string{"canary", "prod"}, "")
+
+	want := "update group canary had failed updates or unchecked workloads"
+	if reason := g.wait(context.Background(), prod); reason != want {
+		t.Errorf("wait(prod) = %q, want %q", reason, want)
+	}
+}
@@ -0,0 +1,99 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+	"github.com/qetesh/kube-watchtower/pkg/state"
+)
+
+// History returns the recorded updates and rollbacks, oldest first, optionally of one namespace and workload
+func (w *Watcher) History(ctx context.Context, namespace, name string) ([]state.UpdateRecord, error) {
+	if w.store == nil {
+		return nil, fmt.Errorf("no update history: STATE_CONFIGMAP is not set")
+	}
+	if err := w.store.Load(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	records := make([]state.UpdateRecord, 0)
+	for _, record := range w.store.History() {
+		if (namespace == "" || record.Namespace == namespace) && (name == "" || record.Name == name) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// RollbackRecord reverts the recorded update with the given ID to the image it replaced
+// and waits for the rollout to complete
+func (w *Watcher) RollbackRecord(ctx context.Context, id string) ([]RollbackResult, error) {
+	if w.store == nil {
+		return nil, fmt.Errorf("no update history: STATE_CONFIGMAP is not set")
+	}
+
+	w.mu.Lock()
+	target, kind, record, err := w.findRecordTarget(ctx, id)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	return w.rollback(ctx, kind, record.Namespace, record.Name, []*rollbackTarget{target})
+}
+
+// findRecordTarget looks up the recorded update with the given ID and determines what reverting it changes,
+// resolving the workload by the kind in the record (caller holds mu)
+func (w *Watcher) findRecordTarget(ctx context.Context, id string) (*rollbackTarget, k8s.WorkloadType, *state.UpdateRecord, error) {
+	if err := w.store.Load(ctx); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	var record *state.UpdateRecord
+	for _, r := range w.store.History() {
+		if r.ID() == id {
+			record = &r
+			break
+		}
+	}
+	switch {
+	case record == nil:
+		return nil, "", nil, fmt.Errorf("no recorded update with ID %s", id)
+	case record.Rollback:
+		return nil, "", nil, fmt.Errorf("record %s is a rollback, not an update", id)
+	case !record.Success:
+		return nil, "", nil, fmt.Errorf("record %s is a failed update, nothing to revert", id)
+	}
+	kind, ok := k8s.ParseWorkloadType(record.Kind)
+	if !ok {
+		return nil, "", nil, fmt.Errorf("record %s has unknown workload kind %q", id, record.Kind)
+	}
+
+	// Revert from whatever the container runs now, which may be a later update
+	template, err := w.k8sClient.GetWorkloadTemplate(ctx, kind, record.Namespace, record.Name)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	currentImage := ""
+	for _, c := range template.Spec.Containers {
+		if c.Name == record.Container {
+			currentImage = c.Image
+		}
+	}
+	if currentImage == "" {
+		return nil, "", nil, fmt.Errorf("container %s not found in %s %s/%s", record.Container, kind, record.Namespace, record.Name)
+	}
+	if currentImage != record.NewImage {
+		logger.Warnf("%s/%s/%s changed since update %s (now %s), reverting anyway", record.Namespace, record.Name, record.Container, id, currentImage)
+	}
+
+	return &rollbackTarget{
+		container:  record.Container,
+		fromImage:  currentImage,
+		fromDigest: registry.ParseImage(currentImage).Digest,
+		toImage:    record.OldImage,
+		toDigest:   record.OldDigest,
+	}, kind, record, nil
+}
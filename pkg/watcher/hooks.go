@@ -0,0 +1,77 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// Lifecycle hook annotations (set on the workload)
+const (
+	annotationPreUpdateCommand  = "kube-watchtower.io/pre-update-command"
+	annotationPostUpdateCommand = "kube-watchtower.io/post-update-command"
+	annotationHookContainer     = "kube-watchtower.io/hook-container"
+	annotationHookTimeout       = "kube-watchtower.io/hook-timeout"
+	annotationHookFailurePolicy = "kube-watchtower.io/hook-failure-policy"
+)
+
+// Hook failure policies
+const (
+	hookFailureAbort = "abort" // Abort the update (pre) or mark it failed (post)
+	hookFailureWarn  = "warn"  // Log a warning and continue
+)
+
+// defaultHookTimeout bounds a hook when no timeout annotation is set
+const defaultHookTimeout = time.Minute
+
+// runHook runs the lifecycle hook command from annotation in all running pods of the workload
+// Returns an error only when the hook fails and the failure policy is abort
+func (w *Watcher) runHook(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, annotation string) error {
+	command := workload.Annotations[annotation]
+	if command == "" || workload.Selector == nil {
+		// CronJobs have no running pods to run the hook in
+		return nil
+	}
+
+	hookContainer := container.Name
+	if name := workload.Annotations[annotationHookContainer]; name != "" {
+		hookContainer = name
+	}
+
+	timeout := defaultHookTimeout
+	if value := workload.Annotations[annotationHookTimeout]; value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			logger.Warnf("Invalid %s annotation on %s/%s: %v", annotationHookTimeout, workload.Namespace, workload.Name, err)
+		} else {
+			timeout = parsed
+		}
+	}
+
+	policy := workload.Annotations[annotationHookFailurePolicy]
+	if policy == "" {
+		policy = hookFailureAbort
+	}
+
+	logger.Infof("Running hook %s in %s/%s (container %s)", annotation, workload.Namespace, workload.Name, hookContainer)
+
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := w.k8sClient.ExecInPods(hookCtx, workload.Namespace, workload.Selector, hookContainer, []string{"sh", "-c", command})
+	if err == nil {
+		return nil
+	}
+	if hookCtx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+
+	if policy == hookFailureWarn {
+		logger.Warnf("Hook %s failed for %s/%s, continuing: %v", annotation, workload.Namespace, workload.Name, err)
+		return nil
+	}
+	return err
+}
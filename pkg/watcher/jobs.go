@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Pre-update Job annotations (set on the workload)
+const (
+	annotationPreUpdateJob          = "kube-watchtower.io/pre-update-job"           // Inline Job manifest (YAML or JSON)
+	annotationPreUpdateJobConfigMap = "kube-watchtower.io/pre-update-job-configmap" // "name[/key]" of a ConfigMap holding the manifest
+	annotationPreUpdateJobTimeout   = "kube-watchtower.io/pre-update-job-timeout"
+)
+
+// Pre-update Job defaults
+const (
+	defaultJobConfigMapKey = "job.yaml"
+	defaultJobTimeout      = 10 * time.Minute
+	jobTTLSeconds          = int32(3600)
+)
+
+// runPreUpdateJob runs the workload's pre-update Job (e.g. database migrations) with the new image
+// and waits for it to succeed
+func (w *Watcher) runPreUpdateJob(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage string) error {
+	manifest, err := w.preUpdateJobManifest(ctx, workload)
+	if err != nil || manifest == "" {
+		return err
+	}
+
+	job, err := buildPreUpdateJob(manifest, workload, container, newImage)
+	if err != nil {
+		return err
+	}
+
+	timeout := defaultJobTimeout
+	if value := workload.Annotations[annotationPreUpdateJobTimeout]; value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			logger.Warnf("Invalid %s annotation on %s/%s: %v", annotationPreUpdateJobTimeout, workload.Namespace, workload.Name, err)
+		} else {
+			timeout = parsed
+		}
+	}
+
+	logger.Infof("Running pre-update job for %s/%s with image %s", workload.Namespace, workload.Name, newImage)
+	return w.k8sClient.RunJob(ctx, job, timeout)
+}
+
+// preUpdateJobManifest returns the Job manifest from the inline or ConfigMap annotation
+func (w *Watcher) preUpdateJobManifest(ctx context.Context, workload k8s.WorkloadInfo) (string, error) {
+	if manifest := workload.Annotations[annotationPreUpdateJob]; manifest != "" {
+		return manifest, nil
+	}
+
+	ref := workload.Annotations[annotationPreUpdateJobConfigMap]
+	if ref == "" {
+		return "", nil
+	}
+
+	name, key, found := strings.Cut(ref, "/")
+	if !found {
+		key = defaultJobConfigMapKey
+	}
+
+	data, err := w.k8sClient.GetConfigMapData(ctx, workload.Namespace, name)
+	if err != nil {
+		return "", err
+	}
+	manifest, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("configmap %s/%s has no key %s", workload.Namespace, name, key)
+	}
+	return manifest, nil
+}
+
+// buildPreUpdateJob parses a Job manifest and points it at the new image
+// Containers without an image or running the updated repository get the new image
+func buildPreUpdateJob(manifest string, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage string) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+	if err := yaml.Unmarshal([]byte(manifest), job); err != nil {
+		return nil, fmt.Errorf("invalid job manifest: %w", err)
+	}
+	if len(job.Spec.Template.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("invalid job manifest: no containers")
+	}
+
+	repository := registry.ParseImage(container.Image).Repository
+	replaced := 0
+	for i := range job.Spec.Template.Spec.Containers {
+		c := &job.Spec.Template.Spec.Containers[i]
+		if c.Image == "" || registry.ParseImage(c.Image).Repository == repository {
+			c.Image = newImage
+			replaced++
+		}
+	}
+	if replaced == 0 {
+		return nil, fmt.Errorf("invalid job manifest: no container uses image %s", repository)
+	}
+
+	job.Namespace = workload.Namespace
+	job.Name = ""
+	job.GenerateName = fmt.Sprintf("%s-pre-update-", workload.Name)
+	job.ResourceVersion = ""
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels["app.kubernetes.io/managed-by"] = "kube-watchtower"
+	if job.Spec.TTLSecondsAfterFinished == nil {
+		ttl := jobTTLSeconds
+		job.Spec.TTLSecondsAfterFinished = &ttl
+	}
+	if job.Spec.Template.Spec.RestartPolicy == "" {
+		job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	}
+	if len(job.Spec.Template.Spec.ImagePullSecrets) == 0 {
+		for _, secret := range workload.ImagePullSecrets {
+			job.Spec.Template.Spec.ImagePullSecrets = append(job.Spec.Template.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: secret})
+		}
+	}
+
+	return job, nil
+}
@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// eventTimeout bounds the Event creation of one summary
+const eventTimeout = 30 * time.Second
+
+// eventReasons are the Event reasons of the summary sections
+var eventReasons = map[string]string{
+	"success":  "ImageUpdated",
+	"detected": "UpdateDetected",
+	"deferred": "UpdateDeferred",
+	"failed":   "UpdateFailed",
+	"advisory": "NewerTagsAvailable",
+}
+
+// kubeEventBackend reports summaries as Events on the workloads (kubernetes://)
+// Messages outside of the summary, e.g. on startup, have no workload and are only logged.
+type kubeEventBackend struct {
+	client *k8s.Client
+}
+
+// kubeEventBackendFactory returns the factory of the kubernetes:// backend of a client
+func kubeEventBackendFactory(client *k8s.Client) notifier.BackendFactory {
+	return func(string) (notifier.Backend, error) {
+		return &kubeEventBackend{client: client}, nil
+	}
+}
+
+func (b *kubeEventBackend) SendSummary(summary notifier.Summary) error {
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+
+	var lastErr error
+	for _, sec := range summary.Sections {
+		eventType := corev1.EventTypeNormal
+		if sec.Name == "failed" || sec.Name == "deferred" {
+			eventType = corev1.EventTypeWarning
+		}
+		for _, result := range sec.Results {
+			if result.Source.Kind == "" {
+				continue
+			}
+			message := fmt.Sprintf("Container %s: %s", result.Source.Container, result.Image)
+			if result.Error != nil {
+				message += fmt.Sprintf(" (%v)", result.Error)
+			}
+			if summary.DryRun {
+				message += " [dry-run]"
+			}
+			if err := b.client.RecordWorkloadEvent(ctx, k8s.WorkloadType(result.Source.Kind), result.Source.Namespace, result.Source.Workload, eventType, eventReasons[sec.Name], message); err != nil {
+				logger.Debugf("Failed to record event for %s: %v", result.Source, err)
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+func (b *kubeEventBackend) Send(message string) error {
+	logger.Debugf("Not sending message as Kubernetes Event, no workload: %s", message)
+	return nil
+}
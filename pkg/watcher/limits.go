@@ -0,0 +1,172 @@
+package watcher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/state"
+)
+
+// cycleStats aggregates the counters of one check cycle
+type cycleStats struct {
+	mu           sync.Mutex
+	scannedCount int
+	updatedCount int
+	failedCount  int
+	nsScanned    map[string]int // Keyed by namespace or workload notification URL
+	pending      []PendingUpdate
+	containers   map[string]*checkedContainer // Keyed by state key
+	incomplete   map[string]bool              // Workload keys whose check did not complete
+	snapshots    map[string]*volumeSnapshots  // Keyed by workload key
+}
+
+// newCycleStats creates the counters of a check cycle
+func newCycleStats() *cycleStats {
+	return &cycleStats{
+		nsScanned:  make(map[string]int),
+		containers: make(map[string]*checkedContainer),
+		incomplete: make(map[string]bool),
+		snapshots:  make(map[string]*volumeSnapshots),
+	}
+}
+
+// incompleteCheck records a workload whose check did not complete, e.g. a container skipped
+// while its registry is unavailable
+func (s *cycleStats) incompleteCheck(workload k8s.WorkloadInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.incomplete[workloadKey(workload.Namespace, workload.Name)] = true
+}
+
+// completed reports whether a workload was checked completely without a failed update
+// Deferred updates count as completed, they are applied in a later check.
+func (s *cycleStats) completed(workload k8s.WorkloadInfo) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.incomplete[workloadKey(workload.Namespace, workload.Name)] {
+		return false
+	}
+	for _, checked := range s.containers {
+		if checked.namespace == workload.Namespace && checked.kind == string(workload.Type) && checked.name == workload.Name &&
+			checked.status.Status == ContainerFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// scanned counts a scanned container, also for the namespace and workload notification URLs it is routed to
+func (s *cycleStats) scanned(urls []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scannedCount++
+	for _, url := range urls {
+		s.nsScanned[url]++
+	}
+}
+
+// updated counts an updated (or, in dry-run, detected) container
+func (s *cycleStats) updated() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updatedCount++
+}
+
+// failed counts a failed container
+func (s *cycleStats) failed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failedCount++
+}
+
+// addPending records an available update that was not applied
+func (s *cycleStats) addPending(workload k8s.WorkloadInfo, container k8s.ContainerInfo, newDigest, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if checked, ok := s.containers[state.Key(workload.Namespace, string(workload.Type), workload.Name, container.Name)]; ok {
+		checked.status.Status = ContainerPending
+		checked.status.Reason = reason
+	}
+	s.pending = append(s.pending, PendingUpdate{
+		Namespace: workload.Namespace,
+		Kind:      string(workload.Type),
+		Name:      workload.Name,
+		Container: container.Name,
+		Image:     container.Image,
+		NewDigest: newDigest,
+		Reason:    reason,
+	})
+}
+
+// rolloutLimiter bounds the number of workloads being updated at once,
+// cluster-wide and per namespace
+type rolloutLimiter struct {
+	global       chan struct{}
+	perNamespace int
+
+	mu         sync.Mutex
+	namespaces map[string]chan struct{}
+}
+
+// newRolloutLimiter creates a limiter, a limit <= 0 means unlimited
+func newRolloutLimiter(global, perNamespace int) *rolloutLimiter {
+	l := &rolloutLimiter{
+		perNamespace: perNamespace,
+		namespaces:   make(map[string]chan struct{}),
+	}
+	if global > 0 {
+		l.global = make(chan struct{}, global)
+	}
+	return l
+}
+
+// acquire blocks until a rollout slot in namespace is free or ctx is done
+func (l *rolloutLimiter) acquire(ctx context.Context, namespace string) error {
+	if ns := l.namespace(namespace); ns != nil {
+		select {
+		case ns <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if l.global != nil {
+		select {
+		case l.global <- struct{}{}:
+		case <-ctx.Done():
+			l.releaseNamespace(namespace)
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// release frees a rollout slot acquired for namespace
+func (l *rolloutLimiter) release(namespace string) {
+	if l.global != nil {
+		<-l.global
+	}
+	l.releaseNamespace(namespace)
+}
+
+// releaseNamespace frees the namespace slot
+func (l *rolloutLimiter) releaseNamespace(namespace string) {
+	if ns := l.namespace(namespace); ns != nil {
+		<-ns
+	}
+}
+
+// namespace returns the semaphore of a namespace, nil if unlimited
+func (l *rolloutLimiter) namespace(namespace string) chan struct{} {
+	if l.perNamespace <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ns, ok := l.namespaces[namespace]
+	if !ok {
+		ns = make(chan struct{}, l.perNamespace)
+		l.namespaces[namespace] = ns
+	}
+	return ns
+}
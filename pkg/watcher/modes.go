@@ -0,0 +1,41 @@
+package watcher
+
+import (
+	"context"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+)
+
+// annotationUpdateMode overrides UpdateMode for a workload
+const annotationUpdateMode = "kube-watchtower.io/update-mode"
+
+// updateMode returns the update mode of a workload, invalid annotations fall back to UpdateMode
+func (w *Watcher) updateMode(workload k8s.WorkloadInfo) string {
+	mode, ok := workload.Annotations[annotationUpdateMode]
+	if !ok {
+		return w.config.UpdateMode
+	}
+	if !config.IsUpdateMode(mode) {
+		logger.Warnf("Invalid %s %q on %s/%s, using %s", annotationUpdateMode, mode, workload.Namespace, workload.Name, w.config.UpdateMode)
+		return w.config.UpdateMode
+	}
+	return mode
+}
+
+// newestTag returns the image with the newest version tag newer than the current one,
+// empty if the tag is not a version or no newer tag exists
+func (w *Watcher) newestTag(ctx context.Context, container k8s.ContainerInfo, credentials *registry.RegistryCredentials) (string, error) {
+	if !registry.IsVersionTag(container.Tag) {
+		return "", nil
+	}
+
+	repository := registry.ParseImage(container.Image).Repository
+	tags, err := w.imageChecker.NewerTags(ctx, repository, container.Tag, credentials)
+	if err != nil || len(tags) == 0 {
+		return "", err
+	}
+	return repository + ":" + tags[0], nil
+}
@@ -0,0 +1,69 @@
+package watcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/notifier"
+)
+
+// loadNotificationURL reads the notification URL from NotificationURLSecret, so rotated tokens are picked up
+// The previous URL is kept when the Secret cannot be read
+func (w *Watcher) loadNotificationURL(ctx context.Context) {
+	if w.config.NotificationURLSecret == "" {
+		return
+	}
+
+	namespace, name, key, err := w.config.NotificationSecretRef()
+	if err != nil {
+		logger.Warnf("Failed to read notification URL: %v", err)
+		return
+	}
+	url, err := w.k8sClient.GetSecretValue(ctx, namespace, name, key)
+	if err != nil {
+		logger.Warnf("Failed to read notification URL: %v", err)
+		return
+	}
+	if n, ok := w.notifier.(*notifier.Notifier); ok {
+		n.SetURL(url)
+	}
+}
+
+// emoji returns the marker of a message type followed by a space, "" for custom notifiers or without emoji
+func (w *Watcher) emoji(name string) string {
+	if n, ok := w.notifier.(*notifier.Notifier); ok {
+		return n.Emoji(name)
+	}
+	return ""
+}
+
+// footerTimeout bounds the cluster queries of the notification footer
+const footerTimeout = 10 * time.Second
+
+// footerContext returns the provider of the footer template data, querying the cluster once per summary
+// Values that cannot be read are left empty.
+func footerContext(cfg *config.Config, client *k8s.Client) func() notifier.FooterData {
+	return func() notifier.FooterData {
+		data := notifier.FooterData{Environment: cfg.NotificationEnvironment, Version: cfg.Version}
+		if client == nil {
+			return data
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), footerTimeout)
+		defer cancel()
+
+		if version, err := client.ServerVersion(); err == nil {
+			data.ServerVersion = version
+		} else {
+			logger.Debugf("Failed to read server version for the notification footer: %v", err)
+		}
+		if nodes, err := client.CountNodes(ctx); err == nil {
+			data.Nodes = nodes
+		} else {
+			logger.Debugf("Failed to count nodes for the notification footer: %v", err)
+		}
+		return data
+	}
+}
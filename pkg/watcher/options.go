@@ -0,0 +1,147 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/audit"
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/notifier"
+	"github.com/qetesh/kube-watchtower/pkg/policy"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+	"github.com/qetesh/kube-watchtower/pkg/state"
+)
+
+// Notifier receives the results of check cycles and sends their summaries
+type Notifier interface {
+	AddResult(source notifier.Source, image string, success bool, err error)
+	AddDetected(source notifier.Source, image string)
+	AddDeferred(source notifier.Source, image string, reason string)
+	AddAdvisory(source notifier.Source, image string, tags []string)
+	SendSummary(totalCount int)
+	Reset()
+
+	// Send sends a message outside of the summary, e.g. on startup
+	Send(message string) error
+}
+
+// deliveryCounter is implemented by notifiers counting the messages they failed to deliver
+type deliveryCounter interface {
+	Dropped() int64
+}
+
+// notifierCloser is implemented by notifiers delivering in the background, Close waits for them
+type notifierCloser interface {
+	Close(ctx context.Context)
+}
+
+var (
+	_ Notifier        = (*notifier.Notifier)(nil)
+	_ deliveryCounter = (*notifier.Notifier)(nil)
+	_ notifierCloser  = (*notifier.Notifier)(nil)
+)
+
+// clusterNameTimeout bounds the detection of the cluster name when NOTIFICATION_CLUSTER is unset
+const clusterNameTimeout = 10 * time.Second
+
+// Options replaces the default components of a watcher, e.g. in programs embedding the update engine
+// Nil fields use the defaults built from the configuration.
+type Options struct {
+	Client   *k8s.Client            // Kubernetes client, see k8s.NewClientFromClientset; from the kubeconfig if nil
+	Lister   k8s.WorkloadLister     // Lists the workloads of a check cycle, Client if nil
+	Resolver registry.ImageResolver // Resolves remote digests, a registry.ImageChecker if nil
+	Updater  k8s.Updater            // Writes new images and awaits rollouts, Client if nil
+	Notifier Notifier               // Receives the results, a shoutrrr notifier for NotificationURL if nil
+
+	// Custom resolvers by repository prefix (registry host or host/path), Resolver resolves all other images
+	Resolvers map[string]registry.ImageResolver
+}
+
+// New creates a watcher for the configuration with the given components
+// cfg is used as is: build it with config.LoadConfig or by hand, and check it with Validate.
+func New(cfg *config.Config, opts Options) (*Watcher, error) {
+	k8sClient := opts.Client
+	if k8sClient == nil {
+		var err error
+		k8sClient, err = k8s.NewClientWithOptions(cfg.KubeContext, k8s.ClientOptions{
+			QPS:       cfg.K8sQPS,
+			Burst:     cfg.K8sBurst,
+			UserAgent: cfg.UserAgent,
+			PageSize:  int64(cfg.K8sPageSize),
+
+			ScaledToZero: cfg.ScaledToZero,
+			CronJobs:     cfg.CronJobs,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create k8s client: %w", err)
+		}
+	}
+	if cfg.NotificationCluster == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterNameTimeout)
+		detected := *cfg
+		detected.NotificationCluster = k8sClient.DetectClusterName(ctx)
+		cancel()
+		logger.Infof("Detected cluster name: %s (set NOTIFICATION_CLUSTER to override)", detected.NotificationCluster)
+		cfg = &detected
+	}
+
+	resolver := opts.Resolver
+	if resolver == nil {
+		imageChecker, err := registry.NewImageChecker(registry.CheckerOptions{
+			Proxies:     cfg.RegistryProxies,
+			Artifactory: cfg.RegistryArtifactory,
+			TokenTTL:    cfg.RegistryTokenTTL,
+
+			CircuitThreshold: cfg.RegistryCircuitThreshold,
+			CircuitCooldown:  cfg.RegistryCircuitCooldown,
+			Timeout:          cfg.RegistryTimeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create image checker: %w", err)
+		}
+		resolver = imageChecker
+	}
+	if len(opts.Resolvers) > 0 {
+		router := registry.NewResolverRouter(resolver)
+		for prefix, custom := range opts.Resolvers {
+			router.Register(prefix, custom)
+		}
+		resolver = router
+	}
+
+	var lister k8s.WorkloadLister = k8sClient
+	if opts.Lister != nil {
+		lister = opts.Lister
+	}
+	var updater k8s.Updater = k8sClient
+	if opts.Updater != nil {
+		updater = opts.Updater
+	}
+	var notif Notifier = notifier.NewNotifier(cfg.NotificationURL, notifierOptions(cfg, k8sClient))
+	if opts.Notifier != nil {
+		notif = opts.Notifier
+	}
+
+	auditLog, err := audit.NewExporter(cfg.AuditLog, cfg.AuditLogS3Endpoint, cfg.NotificationCluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit log exporter: %w", err)
+	}
+
+	return &Watcher{
+		config:       cfg,
+		k8sClient:    k8sClient,
+		lister:       lister,
+		updater:      updater,
+		imageChecker: resolver,
+		notifier:     notif,
+		nsNotifiers:  make(map[string]*notifier.Notifier),
+		workloadURLs: make(map[string]string),
+		store:        state.NewStore(k8sClient, cfg.PodNamespace, cfg.StateConfigMap, cfg.StateHistoryLimit),
+		policy:       policy.NewClient(cfg.PolicyURL, cfg.WebhookTimeout),
+		rollouts:     newRolloutLimiter(cfg.MaxConcurrentRollouts, cfg.MaxConcurrentRolloutsPerNamespace),
+		audit:        auditLog,
+	}, nil
+}
@@ -0,0 +1,15 @@
+package watcher
+
+import (
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+)
+
+// ownedWorkloadHandling returns how a workload with the owner is handled (OWNED_WORKLOADS),
+// OwnedWorkloadsUpdate for workloads without an owner or with an allowed one
+func (w *Watcher) ownedWorkloadHandling(owner *k8s.Owner) string {
+	if owner == nil || w.config.IsOwnerAllowed(owner.Kind, owner.Name) {
+		return config.OwnedWorkloadsUpdate
+	}
+	return w.config.OwnedWorkloads
+}
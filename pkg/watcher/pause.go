@@ -0,0 +1,135 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// Pause annotations
+const (
+	annotationPaused     = "kube-watchtower.io/paused"      // On the kube-watchtower namespace: "true" or an RFC 3339 time
+	annotationPauseUntil = "kube-watchtower.io/pause-until" // On a workload: RFC 3339 time
+)
+
+// PauseStatus describes whether updates are paused
+type PauseStatus struct {
+	Paused bool       `json:"paused"`
+	Until  *time.Time `json:"until,omitempty"` // Auto-resume time, nil pauses until resumed
+	Source string     `json:"source,omitempty"`
+}
+
+// pauseState is a global pause, a zero until pauses until resumed
+type pauseState struct {
+	paused bool
+	until  time.Time
+}
+
+// active checks if the pause is in effect at t
+func (p pauseState) active(t time.Time) bool {
+	return p.paused && (p.until.IsZero() || t.Before(p.until))
+}
+
+// status describes the pause
+func (p pauseState) status(source string) PauseStatus {
+	status := PauseStatus{Paused: true, Source: source}
+	if !p.until.IsZero() {
+		until := p.until
+		status.Until = &until
+	}
+	return status
+}
+
+// Pause suspends all updates of the watcher until the given time, a zero time pauses until resumed
+func (w *Watcher) Pause(until time.Time) {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+
+	w.apiPause = pauseState{paused: true, until: until}
+	if until.IsZero() {
+		logger.Infof("Updates paused")
+	} else {
+		logger.Infof("Updates paused until %s", until.Format(time.RFC3339))
+	}
+}
+
+// Resume lifts a pause set with Pause
+func (w *Watcher) Resume() {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+
+	w.apiPause = pauseState{}
+	logger.Infof("Updates resumed")
+}
+
+// PauseStatus returns whether updates are currently paused globally
+func (w *Watcher) PauseStatus() PauseStatus {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+
+	now := time.Now()
+	if w.apiPause.active(now) {
+		return w.apiPause.status("api")
+	}
+	if w.nsPause.active(now) {
+		return w.nsPause.status(fmt.Sprintf("namespace %s", w.config.PodNamespace))
+	}
+	return PauseStatus{}
+}
+
+// loadNamespacePause reads the pause annotation of the kube-watchtower namespace
+func (w *Watcher) loadNamespacePause(ctx context.Context) {
+	annotations, err := w.k8sClient.GetNamespaceAnnotations(ctx, w.config.PodNamespace)
+	if err != nil {
+		logger.Debugf("Failed to read pause annotation of namespace %s: %v", w.config.PodNamespace, err)
+		return
+	}
+
+	state := pauseState{}
+	if value := strings.TrimSpace(annotations[annotationPaused]); value != "" {
+		switch strings.ToLower(value) {
+		case "true", "yes", "1":
+			state.paused = true
+		case "false", "no", "0":
+		default:
+			until, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				logger.Warnf("Invalid %s annotation on namespace %s: %v", annotationPaused, w.config.PodNamespace, err)
+				break
+			}
+			state = pauseState{paused: true, until: until}
+		}
+	}
+
+	w.pauseMu.Lock()
+	w.nsPause = state
+	w.pauseMu.Unlock()
+}
+
+// checkPause defers updates while updates are paused globally or for the workload
+func (w *Watcher) checkPause(workload k8s.WorkloadInfo) (string, bool) {
+	if status := w.PauseStatus(); status.Paused {
+		if status.Until != nil {
+			return fmt.Sprintf("paused by %s until %s", status.Source, status.Until.Format(time.RFC3339)), true
+		}
+		return fmt.Sprintf("paused by %s", status.Source), true
+	}
+
+	value := workload.Annotations[annotationPauseUntil]
+	if value == "" {
+		return "", false
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logger.Warnf("Invalid %s annotation on %s/%s: %v", annotationPauseUntil, workload.Namespace, workload.Name, err)
+		return "", false
+	}
+	if time.Now().Before(until) {
+		return fmt.Sprintf("paused until %s", until.Format(time.RFC3339)), true
+	}
+	return "", false
+}
@@ -0,0 +1,120 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// Plugin stages, in addition to EventPreUpdate and EventPostUpdate
+const (
+	EventPreCheck  = "pre-check"
+	EventOnFailure = "on-failure"
+)
+
+// maxPluginReason bounds the plugin output reported as the reason of a veto
+const maxPluginReason = 200
+
+// runPlugins runs the PLUGINS executables for an event, in order
+// The first plugin exiting non-zero (or failing to run) vetoes the action and stops the others.
+func (w *Watcher) runPlugins(ctx context.Context, event UpdateEvent) error {
+	if len(w.config.Plugins) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event: %w", err)
+	}
+	for _, plugin := range w.config.Plugins {
+		if err := w.runPlugin(ctx, plugin, event.Event, payload); err != nil {
+			return fmt.Errorf("plugin %s: %w", plugin, err)
+		}
+	}
+	return nil
+}
+
+// runPlugin runs a plugin with the stage as its argument and the event on stdin
+func (w *Watcher) runPlugin(ctx context.Context, plugin, stage string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, w.config.PluginTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, plugin, stage)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(cmd.Environ(), "KUBE_WATCHTOWER_EVENT="+stage)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", w.config.PluginTimeout)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if reason := lastLine(output); reason != "" {
+			return fmt.Errorf("exit code %d: %s", exitErr.ExitCode(), truncateReason(reason))
+		}
+		return fmt.Errorf("exit code %d", exitErr.ExitCode())
+	}
+	if err != nil {
+		return err
+	}
+	if len(output) > 0 {
+		logger.Debugf("Plugin %s (%s): %s", plugin, stage, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// checkPlugins runs the pre-check plugins of a container
+// Returns the reason and true if a plugin vetoed the check
+func (w *Watcher) checkPlugins(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo) (string, bool) {
+	if err := w.runPlugins(ctx, w.newUpdateEvent(EventPreCheck, workload, container, "", "")); err != nil {
+		return fmt.Sprintf("vetoed by %v", err), true
+	}
+	return "", false
+}
+
+// callPostUpdatePlugins runs the post-update plugins and, if the update failed, the on-failure plugins
+// Their exit codes are only logged, the update has already happened.
+func (w *Watcher) callPostUpdatePlugins(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage, newDigest string, updateErr error) {
+	if len(w.config.Plugins) == 0 {
+		return
+	}
+
+	event := w.newUpdateEvent(EventPostUpdate, workload, container, newImage, newDigest)
+	success := updateErr == nil
+	event.Success = &success
+	if updateErr != nil {
+		event.Error = updateErr.Error()
+	}
+	if err := w.runPlugins(ctx, event); err != nil {
+		logger.Warnf("Post-update %v", err)
+	}
+
+	if updateErr == nil {
+		return
+	}
+	event.Event = EventOnFailure
+	if err := w.runPlugins(ctx, event); err != nil {
+		logger.Warnf("On-failure %v", err)
+	}
+}
+
+// lastLine returns the last non-empty line of an output
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// truncateReason shortens a plugin reason to maxPluginReason bytes
+func truncateReason(reason string) string {
+	if len(reason) <= maxPluginReason {
+		return reason
+	}
+	return reason[:maxPluginReason-3] + "..."
+}
@@ -0,0 +1,62 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+)
+
+// promotionStage returns the promotion stage of a workload and its position in PromotionStages,
+// -1 if promotion is disabled or the workload is not part of the pipeline
+func (w *Watcher) promotionStage(workload k8s.WorkloadInfo) (string, int) {
+	if !w.config.Promotion {
+		return "", -1
+	}
+	stage := workload.Labels[w.config.PromotionLabel]
+	for i, s := range w.config.PromotionStages {
+		if stage != "" && s == stage {
+			return stage, i
+		}
+	}
+	return "", -1
+}
+
+// promotionImage returns the repository:tag whose digests are promoted
+func promotionImage(image string) string {
+	info := registry.ParseImage(image)
+	return info.Repository + ":" + info.Tag
+}
+
+// promotedDigest returns the newest digest of image that soaked in the stage before index,
+// the current digest if it is newer, or empty and the reason nothing can be promoted yet
+func (w *Watcher) promotedDigest(image, current string, index int) (string, string) {
+	previous := w.config.PromotionStages[index-1]
+	promotions := w.store.Promotions(image)
+	reason := ""
+	for i := len(promotions) - 1; i >= 0; i-- {
+		p := promotions[i]
+		if p.Digest == current {
+			return current, ""
+		}
+		since, ok := p.Stages[previous]
+		switch {
+		case p.Failed != "":
+			if reason == "" {
+				reason = fmt.Sprintf("promotion of %s halted: %s", shortDigest(p.Digest), p.Failed)
+			}
+		case !ok:
+		case time.Since(since) < w.config.PromotionSoak:
+			if reason == "" {
+				reason = fmt.Sprintf("soaking in %s for another %s", previous, (w.config.PromotionSoak - time.Since(since)).Round(time.Second))
+			}
+		default:
+			return p.Digest, ""
+		}
+	}
+	if reason == "" {
+		reason = fmt.Sprintf("not rolled out to %s yet", previous)
+	}
+	return "", reason
+}
@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+)
+
+// annotationReleaseNotes is a Go template of the release notes URL of a workload,
+// e.g. "https://github.com/org/app/releases/tag/{{.Tag}}"
+const annotationReleaseNotes = "kube-watchtower.io/release-notes"
+
+// releaseNotesData is the data available to the release notes template
+type releaseNotesData struct {
+	Namespace  string
+	Name       string
+	Container  string
+	Image      string
+	Repository string
+	Tag        string
+	Digest     string
+	Version    string // org.opencontainers.image.version of the new image, if known
+	Revision   string // org.opencontainers.image.revision of the new image, if known
+}
+
+// releaseNotesURL renders the release notes annotation of a workload, empty if unset or invalid
+func releaseNotesURL(workload k8s.WorkloadInfo, container k8s.ContainerInfo, newDigest string, metadata *registry.ImageMetadata) string {
+	text := workload.Annotations[annotationReleaseNotes]
+	if text == "" {
+		return ""
+	}
+
+	tmpl, err := template.New("release-notes").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		logger.Warnf("Invalid %s annotation on %s/%s: %v", annotationReleaseNotes, workload.Namespace, workload.Name, err)
+		return ""
+	}
+
+	imageInfo := registry.ParseImage(container.Image)
+	data := releaseNotesData{
+		Namespace:  workload.Namespace,
+		Name:       workload.Name,
+		Container:  container.Name,
+		Image:      container.Image,
+		Repository: imageInfo.Repository,
+		Tag:        imageInfo.Tag,
+		Digest:     newDigest,
+	}
+	if metadata != nil {
+		data.Version = metadata.Version
+		data.Revision = metadata.Revision
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		logger.Warnf("Failed to render %s annotation on %s/%s: %v", annotationReleaseNotes, workload.Namespace, workload.Name, err)
+		return ""
+	}
+	return strings.TrimSpace(sb.String())
+}
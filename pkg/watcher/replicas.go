@@ -0,0 +1,34 @@
+package watcher
+
+import (
+	"fmt"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+)
+
+// annotationAllowLowReplicas opts a workload below the replica minimum into automatic updates
+const annotationAllowLowReplicas = "kube-watchtower.io/allow-low-replicas"
+
+// checkMinReplicas refuses automatic updates of Deployments and StatefulSets with fewer replicas than configured,
+// a bad image there means downtime
+// Returns the reason and true if the update must not be applied
+func (w *Watcher) checkMinReplicas(workload k8s.WorkloadInfo) (string, bool) {
+	// Without pods (SCALED_TO_ZERO) only the template changes, the new image runs at the next scale-up
+	if workload.Annotations[annotationAllowLowReplicas] == "true" || workload.Replicas == 0 {
+		return "", false
+	}
+
+	var minimum int
+	switch workload.Type {
+	case k8s.WorkloadTypeDeployment:
+		minimum = w.config.MinReplicas
+	case k8s.WorkloadTypeStatefulSet:
+		minimum = w.config.MinStatefulSetReplicas
+	default:
+		return "", false
+	}
+	if int(workload.Replicas) >= minimum {
+		return "", false
+	}
+	return fmt.Sprintf("%d replica(s), below the minimum of %d (set %s to update anyway)", workload.Replicas, minimum, annotationAllowLowReplicas), true
+}
@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+)
+
+// robotExpiryWarning is the time before the expiry of a robot account from which it is reported
+const robotExpiryWarning = 7 * 24 * time.Hour
+
+// robotAccount is a robot account file as downloaded from Harbor (name, secret, expires_at)
+// or Quay (name, token)
+type robotAccount struct {
+	Name      string `json:"name"`
+	Secret    string `json:"secret"`
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"` // Unix time, -1 or 0 never expires
+}
+
+// loadRobotCredentials reads the robot account files of REGISTRY_ROBOTS
+// Expired accounts are left out, so the registry's other credentials or the anonymous access are used.
+func (w *Watcher) loadRobotCredentials() []k8s.RegistryAuth {
+	hosts := make([]string, 0, len(w.config.RegistryRobots))
+	for host := range w.config.RegistryRobots {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var auths []k8s.RegistryAuth
+	for _, host := range hosts {
+		robot, err := readRobotAccount(w.config.RegistryRobots[host])
+		if err != nil {
+			logger.Warnf("Failed to read robot account of %s: %v", host, err)
+			continue
+		}
+
+		if robot.ExpiresAt > 0 {
+			expiry := time.Unix(robot.ExpiresAt, 0)
+			if time.Now().After(expiry) {
+				logger.Warnf("Robot account %s of %s expired on %s, not using it", robot.Name, host, expiry.Format(time.DateOnly))
+				continue
+			}
+			if time.Until(expiry) < robotExpiryWarning {
+				logger.Warnf("Robot account %s of %s expires on %s", robot.Name, host, expiry.Format(time.DateOnly))
+			}
+		}
+		auths = append(auths, k8s.RegistryAuth{Registry: host, Username: robot.Name, Password: robot.password()})
+	}
+	return auths
+}
+
+// readRobotAccount reads and validates a robot account file
+func readRobotAccount(path string) (*robotAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var robot robotAccount
+	if err := json.Unmarshal(data, &robot); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if robot.Name == "" || robot.password() == "" {
+		return nil, fmt.Errorf("%s has no name and secret (or token)", path)
+	}
+	if registry.RobotKind(robot.Name) == "" {
+		logger.Debugf("Robot account %s is neither a Harbor (robot$...) nor a Quay (org+robot) name", robot.Name)
+	}
+	return &robot, nil
+}
+
+// password returns the Harbor secret or the Quay token of the robot account
+func (r *robotAccount) password() string {
+	if r.Secret != "" {
+		return r.Secret
+	}
+	return r.Token
+}
@@ -0,0 +1,226 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+	"github.com/qetesh/kube-watchtower/pkg/state"
+)
+
+// RollbackResult describes a completed rollback
+type RollbackResult struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Container string `json:"container"`
+	FromImage string `json:"fromImage"`
+	ToImage   string `json:"toImage"`
+}
+
+// rollbackTimeout bounds the rollout wait of a rollback
+const rollbackTimeout = 5 * time.Minute
+
+// rollbackTarget describes what a rollback reverts in one container
+type rollbackTarget struct {
+	container  string
+	fromImage  string
+	fromDigest string
+	toImage    string
+	toDigest   string
+}
+
+// image returns the image to revert to, the exact previous digest when known
+func (t *rollbackTarget) image() string {
+	if t.toDigest != "" {
+		return pinnedImage(t.toImage, t.toDigest)
+	}
+	return t.toImage
+}
+
+// Rollback reverts the most recent update of a workload to the previous images and waits for the rollout to complete
+// Without a container every container changed by that update is reverted
+// The update history is used when available, otherwise the previous-image annotations
+func (w *Watcher) Rollback(ctx context.Context, namespace, kind, name, container string) ([]RollbackResult, error) {
+	workloadType, ok := k8s.ParseWorkloadType(kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown workload kind %q: expected deployment, daemonset, statefulset or cronjob", kind)
+	}
+
+	w.mu.Lock()
+	if err := w.store.Load(ctx); err != nil {
+		w.mu.Unlock()
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	targets, err := w.findRollbackTargets(ctx, workloadType, namespace, name, container)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	return w.rollback(ctx, workloadType, namespace, name, targets)
+}
+
+// rollback reverts the targets, then waits for the rollout without holding mu so that
+// check cycles and other operations are not blocked by it (caller holds mu, released on return)
+func (w *Watcher) rollback(ctx context.Context, kind k8s.WorkloadType, namespace, name string, targets []*rollbackTarget) ([]RollbackResult, error) {
+	err := w.startRollback(ctx, kind, namespace, name, targets)
+	w.mu.Unlock()
+
+	if err == nil {
+		err = w.waitForRollback(ctx, kind, namespace, name)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.finishRollback(ctx, kind, namespace, name, targets, err)
+}
+
+// applyRollback reverts the targets and waits for the rollout (caller holds mu)
+func (w *Watcher) applyRollback(ctx context.Context, kind k8s.WorkloadType, namespace, name string, targets []*rollbackTarget) ([]RollbackResult, error) {
+	err := w.startRollback(ctx, kind, namespace, name, targets)
+	if err == nil {
+		err = w.waitForRollback(ctx, kind, namespace, name)
+	}
+	return w.finishRollback(ctx, kind, namespace, name, targets, err)
+}
+
+// startRollback applies the previous images and skips the reverted digests right away,
+// so that a check cycle running during the rollout wait does not re-apply them (caller holds mu)
+func (w *Watcher) startRollback(ctx context.Context, kind k8s.WorkloadType, namespace, name string, targets []*rollbackTarget) error {
+	for _, target := range targets {
+		logger.Infof("Rolling back %s/%s/%s (%s): %s -> %s", namespace, name, target.container, kind, target.fromImage, target.image())
+
+		if err := w.updater.UpdateWorkloadImage(ctx, kind, namespace, name, target.container, target.image(), target.fromDigest); err != nil {
+			return fmt.Errorf("failed to update %s: %w", kind, err)
+		}
+		if target.fromDigest != "" {
+			w.store.SkipDigest(state.Key(namespace, string(kind), name, target.container), target.fromDigest)
+		}
+	}
+	if err := w.store.Save(ctx); err != nil {
+		logger.Warnf("Failed to save state: %v", err)
+	}
+	return nil
+}
+
+// waitForRollback waits for the rollout of a rollback
+func (w *Watcher) waitForRollback(ctx context.Context, kind k8s.WorkloadType, namespace, name string) error {
+	if err := w.updater.WaitForRollout(ctx, kind, namespace, name, rollbackTimeout); err != nil {
+		return fmt.Errorf("rollout failed: %w", err)
+	}
+	return nil
+}
+
+// finishRollback records the rollback of every target so the reverted digests are not re-applied (caller holds mu)
+func (w *Watcher) finishRollback(ctx context.Context, kind k8s.WorkloadType, namespace, name string, targets []*rollbackTarget, err error) ([]RollbackResult, error) {
+	results := make([]RollbackResult, 0, len(targets))
+	for _, target := range targets {
+		record := state.UpdateRecord{
+			Time:      time.Now(),
+			Namespace: namespace,
+			Kind:      string(kind),
+			Name:      name,
+			Container: target.container,
+			OldImage:  target.fromImage,
+			NewImage:  target.image(),
+			OldDigest: target.fromDigest,
+			NewDigest: target.toDigest,
+			Success:   err == nil,
+			Rollback:  true,
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		w.store.RecordRollback(state.Key(namespace, string(kind), name, target.container), record)
+		w.audit.Export(ctx, record)
+
+		event := Event{Type: EventRollback, Namespace: namespace, Kind: string(kind), Workload: name, Container: target.container, Image: target.image(), Digest: target.toDigest}
+		if err != nil {
+			event.Message = err.Error()
+		}
+		w.publish(event)
+
+		results = append(results, RollbackResult{
+			Namespace: namespace,
+			Kind:      string(kind),
+			Name:      name,
+			Container: target.container,
+			FromImage: target.fromImage,
+			ToImage:   target.image(),
+		})
+	}
+	if saveErr := w.store.Save(ctx); saveErr != nil {
+		logger.Warnf("Failed to save state: %v", saveErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Rollback completed: %s/%s (%s)", namespace, name, kind)
+	return results, nil
+}
+
+// revertContainer reverts a just-updated container to the image it ran before (caller holds mu)
+// In the tag update mode the previous image string is restored without pinning its digest
+func (w *Watcher) revertContainer(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage, newDigest string) ([]RollbackResult, error) {
+	toDigest := container.CurrentDigest
+	if w.updateMode(workload) == config.UpdateModeTag {
+		toDigest = ""
+	}
+	return w.applyRollback(ctx, workload.Type, workload.Namespace, workload.Name, []*rollbackTarget{{
+		container:  container.Name,
+		fromImage:  newImage,
+		fromDigest: newDigest,
+		toImage:    container.Image,
+		toDigest:   toDigest,
+	}})
+}
+
+// findRollbackTargets determines the previous images from the update history,
+// falling back to the previous-image annotations of the workload
+func (w *Watcher) findRollbackTargets(ctx context.Context, kind k8s.WorkloadType, namespace, name, container string) ([]*rollbackTarget, error) {
+	var targets []*rollbackTarget
+	for _, last := range w.store.LastUpdate(namespace, string(kind), name, container) {
+		targets = append(targets, &rollbackTarget{
+			container:  last.Container,
+			fromImage:  last.NewImage,
+			fromDigest: last.NewDigest,
+			toImage:    last.OldImage,
+			toDigest:   last.OldDigest,
+		})
+	}
+	if len(targets) > 0 {
+		return targets, nil
+	}
+
+	template, err := w.k8sClient.GetWorkloadTemplate(ctx, kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, previous := range k8s.GetPreviousImages(template) {
+		if container != "" && previous.Container != container {
+			continue
+		}
+		currentImage := ""
+		for _, c := range template.Spec.Containers {
+			if c.Name == previous.Container {
+				currentImage = c.Image
+			}
+		}
+		targets = append(targets, &rollbackTarget{
+			container:  previous.Container,
+			fromImage:  currentImage,
+			fromDigest: registry.ParseImage(currentImage).Digest,
+			toImage:    previous.Image,
+			toDigest:   previous.Digest,
+		})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no recorded update found for %s %s/%s", kind, namespace, name)
+	}
+	return targets, nil
+}
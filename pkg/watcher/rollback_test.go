@@ -0,0 +1,80 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+	"github.com/qetesh/kube-watchtower/pkg/state"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// staticResolver resolves every tag to the same digest
+type staticResolver struct {
+	digest string
+}
+
+func (r staticResolver) CheckForUpdate(ctx context.Context, currentImage string, credentials *registry.RegistryCredentials) (bool, string, error) {
+	return true, r.digest, nil
+}
+
+func (r staticResolver) NewerTags(ctx context.Context, repository, currentTag string, credentials *registry.RegistryCredentials) ([]string, error) {
+	return nil, nil
+}
+
+func (r staticResolver) GetMetadata(ctx context.Context, repository, digest string, credentials *registry.RegistryCredentials) (*registry.ImageMetadata, error) {
+	return nil, nil
+}
+
+func TestCheckContainerSkipsRolledBackDigest(t *testing.T) {
+	const (
+		running    = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		rolledBack = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+		newer      = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+	)
+
+	tests := []struct {
+		name       string
+		skipped    string // Digest rolled back from, empty without a rollback
+		remote     string // Digest the tag points to
+		wantStatus string // Dry run reports an applied update as pending
+	}{
+		{"rolled back digest is not re-applied", rolledBack, rolledBack, ContainerSkipped},
+		{"newer digest is applied", rolledBack, newer, ContainerPending},
+		{"no rollback", "", rolledBack, ContainerPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := k8s.NewClientFromClientset(fake.NewSimpleClientset(), nil, k8s.ClientOptions{})
+			w := &Watcher{
+				config:       &config.Config{UpdateMode: config.UpdateModeDigest, DryRun: true},
+				k8sClient:    client,
+				store:        state.NewStore(client, "kube-watchtower", "kube-watchtower-state", 10),
+				imageChecker: staticResolver{digest: tt.remote},
+			}
+			workload := k8s.WorkloadInfo{Type: k8s.WorkloadTypeDeployment, Namespace: "team-a", Name: "web", Replicas: 2}
+			container := k8s.ContainerInfo{Name: "app", Image: "registry.example.com/web:1.0", Tag: "1.0", CurrentDigest: running}
+			key := state.Key(workload.Namespace, string(workload.Type), workload.Name, container.Name)
+			if tt.skipped != "" {
+				w.store.SkipDigest(key, tt.skipped)
+			}
+
+			stats := newCycleStats()
+			ok := w.checkContainer(ctx, workload, container, &config.NamespaceConfig{}, w.config, false, false, "", stats)
+			if !ok {
+				t.Fatalf("checkContainer() = false, want true")
+			}
+			status := stats.containers[key].status
+			if status.Status != tt.wantStatus {
+				t.Errorf("status = %s (%s), want %s", status.Status, status.Reason, tt.wantStatus)
+			}
+			if got := w.store.Container(key).SkippedDigest; got != tt.skipped {
+				t.Errorf("SkippedDigest = %q, want %q", got, tt.skipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"context"
+	"strings"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/notifier"
+)
+
+// Annotations routing the updates of a workload to an additional notification URL,
+// e.g. the channel of the team owning it in a shared namespace
+const (
+	annotationNotifyURL       = "kube-watchtower.io/notify-url"
+	annotationNotifyURLSecret = "kube-watchtower.io/notify-url-secret" // name/key of a Secret in the workload's namespace
+)
+
+// loadWorkloadNotificationURL records the notification URL of a workload's annotations for this cycle,
+// if enabled by WORKLOAD_NOTIFY_URLS and allowed by WORKLOAD_NOTIFY_ALLOW
+// The Secret is preferred over the plain annotation, which exposes tokens to anyone reading the workload.
+func (w *Watcher) loadWorkloadNotificationURL(ctx context.Context, workload k8s.WorkloadInfo) {
+	url := ""
+	if ref := workload.Annotations[annotationNotifyURLSecret]; ref != "" && w.config.WorkloadNotifyURLs {
+		name, key, found := strings.Cut(ref, "/")
+		if !found || name == "" || key == "" {
+			logger.Warnf("Ignoring %s of %s/%s: expected name/key, got %q", annotationNotifyURLSecret, workload.Namespace, workload.Name, ref)
+		} else if value, err := w.k8sClient.GetSecretValue(ctx, workload.Namespace, name, key); err != nil {
+			logger.Warnf("Failed to read notification URL of %s/%s: %v", workload.Namespace, workload.Name, err)
+		} else {
+			url = strings.TrimSpace(value)
+		}
+	} else if w.config.WorkloadNotifyURLs {
+		url = workload.Annotations[annotationNotifyURL]
+	}
+	if url != "" && !w.config.IsWorkloadNotifyURLAllowed(url) {
+		// The URL may hold a token, only its scheme is logged
+		scheme, _, _ := strings.Cut(url, ":")
+		logger.Warnf("Ignoring notification URL of %s/%s: %s URLs are not allowed by WORKLOAD_NOTIFY_ALLOW", workload.Namespace, workload.Name, scheme)
+		url = ""
+	}
+
+	key := workloadKey(workload.Namespace, workload.Name)
+	w.notifiersMu.Lock()
+	defer w.notifiersMu.Unlock()
+	if url == "" {
+		delete(w.workloadURLs, key)
+		return
+	}
+	w.workloadURLs[key] = url
+}
+
+// routedURLs returns the namespace and workload notification URLs of a source,
+// without the global URL and duplicates, none with SkipRoutedNotifications
+func (w *Watcher) routedURLs(nsConfig *config.NamespaceConfig, source notifier.Source) []string {
+	if w.config.SkipRoutedNotifications {
+		return nil
+	}
+	var urls []string
+	if nsConfig != nil && nsConfig.NotificationURL != "" {
+		urls = append(urls, nsConfig.NotificationURL)
+	}
+
+	w.notifiersMu.Lock()
+	url := w.workloadURLs[workloadKey(source.Namespace, source.Workload)]
+	w.notifiersMu.Unlock()
+	if url != "" && url != w.config.NotificationURL && (len(urls) == 0 || urls[0] != url) {
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+// routedNotifiers returns the notifiers of the namespace and workload notification URLs of a source
+func (w *Watcher) routedNotifiers(nsConfig *config.NamespaceConfig, source notifier.Source) []*notifier.Notifier {
+	urls := w.routedURLs(nsConfig, source)
+	notifiers := make([]*notifier.Notifier, 0, len(urls))
+	for _, url := range urls {
+		notifiers = append(notifiers, w.urlNotifier(url))
+	}
+	return notifiers
+}
+
+// urlNotifier returns the notifier of a namespace or workload notification URL
+func (w *Watcher) urlNotifier(url string) *notifier.Notifier {
+	w.notifiersMu.Lock()
+	defer w.notifiersMu.Unlock()
+	n, ok := w.nsNotifiers[url]
+	if !ok {
+		n = notifier.NewNotifier(url, notifierOptions(w.config, w.k8sClient))
+		w.nsNotifiers[url] = n
+	}
+	return n
+}
+
+// evictNotifiers closes the notifiers of the namespace and workload notification URLs no longer referenced
+// by the listed workloads, and forgets the notification URLs of workloads that are gone
+// Queued retries of an evicted notifier get notifierCloseTimeout in the background.
+func (w *Watcher) evictNotifiers(workloads []k8s.WorkloadInfo, nsConfigs map[string]*config.NamespaceConfig) {
+	listed := make(map[string]bool, len(workloads))
+	for _, workload := range workloads {
+		listed[workloadKey(workload.Namespace, workload.Name)] = true
+	}
+	referenced := make(map[string]bool)
+	for _, nsConfig := range nsConfigs {
+		if nsConfig != nil && nsConfig.NotificationURL != "" {
+			referenced[nsConfig.NotificationURL] = true
+		}
+	}
+
+	w.notifiersMu.Lock()
+	defer w.notifiersMu.Unlock()
+	for key, url := range w.workloadURLs {
+		if !listed[key] {
+			delete(w.workloadURLs, key)
+			continue
+		}
+		referenced[url] = true
+	}
+	for url, n := range w.nsNotifiers {
+		if referenced[url] {
+			continue
+		}
+		delete(w.nsNotifiers, url)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), notifierCloseTimeout)
+			defer cancel()
+			n.Close(ctx)
+		}()
+	}
+}
@@ -0,0 +1,144 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// WorkloadScope describes whether a workload is monitored, and why not
+type WorkloadScope struct {
+	Namespace  string           `json:"namespace"`
+	Kind       string           `json:"kind"`
+	Name       string           `json:"name"`
+	Excluded   string           `json:"excluded,omitempty"` // Why the workload is not monitored, empty if it is
+	Containers []ContainerScope `json:"containers"`
+}
+
+// ContainerScope describes whether a container is monitored, and why not
+type ContainerScope struct {
+	Name     string `json:"name"`
+	Image    string `json:"image"`
+	Excluded string `json:"excluded,omitempty"` // Why the container is not monitored, empty if it is
+}
+
+// Scope lists all workloads of the cluster with the reason each workload and container is excluded
+// from the checks, applying the same rules as a check cycle
+func (w *Watcher) Scope(ctx context.Context) ([]WorkloadScope, error) {
+	summaries, err := w.k8sClient.ListAllWorkloads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected map[string]bool
+	if w.config.NamespaceSelector != "" {
+		if selected, err = w.k8sClient.ListNamespacesBySelector(ctx, w.config.NamespaceSelector); err != nil {
+			return nil, err
+		}
+	}
+
+	// Namespace configs of the monitored namespaces
+	var monitored []k8s.WorkloadInfo
+	for _, summary := range summaries {
+		if w.config.IsNamespaceAllowed(summary.Namespace) {
+			monitored = append(monitored, k8s.WorkloadInfo{Namespace: summary.Namespace})
+		}
+	}
+	nsConfigs := w.loadNamespaceConfigs(ctx, monitored)
+
+	scopes := make([]WorkloadScope, 0, len(summaries))
+	for _, summary := range summaries {
+		nsConfig := nsConfigs[summary.Namespace]
+		scope := WorkloadScope{
+			Namespace:  summary.Namespace,
+			Kind:       string(summary.Type),
+			Name:       summary.Name,
+			Excluded:   w.workloadExclusion(summary, nsConfig, selected),
+			Containers: make([]ContainerScope, 0, len(summary.Containers)),
+		}
+		monitoredContainers := 0
+		injected := len(summary.Containers) > 1 || config.IsSidecarInjected(summary.PodAnnotations, summary.PodLabels)
+		for _, container := range summary.Containers {
+			cs := ContainerScope{Name: container.Name, Image: container.Image, Excluded: w.containerExclusion(summary.Annotations, container, injected, nsConfig)}
+			if cs.Excluded == "" {
+				monitoredContainers++
+			}
+			scope.Containers = append(scope.Containers, cs)
+		}
+		if scope.Excluded == "" && monitoredContainers == 0 {
+			scope.Excluded = "no monitored containers"
+		}
+		scopes = append(scopes, scope)
+	}
+
+	sort.Slice(scopes, func(i, j int) bool {
+		if scopes[i].Namespace != scopes[j].Namespace {
+			return scopes[i].Namespace < scopes[j].Namespace
+		}
+		if scopes[i].Kind != scopes[j].Kind {
+			return scopes[i].Kind < scopes[j].Kind
+		}
+		return scopes[i].Name < scopes[j].Name
+	})
+	return scopes, nil
+}
+
+// workloadExclusion returns why a workload is not checked, empty if it is
+// selected holds the namespaces matching NAMESPACE_SELECTOR, nil without selector
+func (w *Watcher) workloadExclusion(summary k8s.WorkloadSummary, nsConfig *config.NamespaceConfig, selected map[string]bool) string {
+	switch {
+	case w.config.IsNamespaceProtected(summary.Namespace):
+		return "protected namespace (PROTECTED_NAMESPACES)"
+	case !w.config.IsNamespaceAllowed(summary.Namespace):
+		return "namespace not monitored (ENABLE_NAMESPACES / DISABLE_NAMESPACES)"
+	case selected != nil && !selected[summary.Namespace]:
+		return fmt.Sprintf("namespace does not match NAMESPACE_SELECTOR %q", w.config.NamespaceSelector)
+	case summary.Type != k8s.WorkloadTypeCronJob && summary.AvailableReplicas <= 0 && !(w.config.ScaledToZero && summary.Type != k8s.WorkloadTypeDaemonSet && summary.Replicas == 0):
+		return "no available replicas"
+	case !nsConfig.IsEnabled():
+		return "disabled by namespace config"
+	case !w.config.WorkloadFilter.Allows(summary.Name):
+		return "workload filtered (INCLUDE_WORKLOADS / EXCLUDE_WORKLOADS)"
+	case !nsConfig.IsWorkloadAllowed(summary.Name):
+		return "workload filtered by namespace config"
+	case summary.Annotations[annotationUnpin] == "true":
+		return fmt.Sprintf("unpinned (%s)", annotationUnpin)
+	case w.ownedWorkloadHandling(summary.Owner) == config.OwnedWorkloadsSkip:
+		return fmt.Sprintf("owned by %s (OWNED_WORKLOADS)", summary.Owner)
+	case summary.HelmRelease != "" && w.config.HelmWorkloads == config.HelmWorkloadsSkip:
+		return fmt.Sprintf("Helm release %s (HELM_WORKLOADS)", summary.HelmRelease)
+	case summary.Suspended && !w.config.UpdateSuspendedCronJobs:
+		return "suspended CronJob (UPDATE_SUSPENDED_CRONJOBS)"
+	}
+	return ""
+}
+
+// containerExclusion returns why a container of a workload with the annotations is not checked, empty if it is
+// injected tells whether the pod has other containers or sidecar injection enabled, see config.IsSidecar.
+func (w *Watcher) containerExclusion(annotations map[string]string, container k8s.ContainerInfo, injected bool, nsConfig *config.NamespaceConfig) string {
+	repository := registry.ParseImage(container.Image).Repository
+	switch {
+	case container.ImagePullPolicy != corev1.PullAlways:
+		return fmt.Sprintf("imagePullPolicy %s, not Always", container.ImagePullPolicy)
+	case w.config.IsContainerDisabled(container.Name):
+		return "container disabled (ENABLE_CONTAINERS / DISABLE_CONTAINERS)"
+	case isContainerIgnored(annotations, container.Name):
+		return fmt.Sprintf("container ignored (%s)", annotationIgnoreContainers)
+	case w.config.IsSidecar(container.Name, repository, injected):
+		return "injected sidecar (SIDECAR_CONTAINERS / SIDECAR_IMAGES)"
+	case !nsConfig.IsTagAllowed(container.Tag):
+		return fmt.Sprintf("tag %s not allowed by namespace config", container.Tag)
+	case !w.config.ImageFilter.Allows(repository):
+		return "image filtered (INCLUDE_IMAGES / EXCLUDE_IMAGES)"
+	case !nsConfig.IsImageAllowed(repository):
+		return "image filtered by namespace config"
+	case isDigestPinned(container.Image):
+		return "pinned by digest"
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package watcher
+
+import (
+	"context"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// detectSelf finds the workload running kube-watchtower from its own pod, once per watcher
+// The key stays empty outside the cluster and when running as a CronJob.
+func (w *Watcher) detectSelf(ctx context.Context) string {
+	if w.selfDetected || w.config.PodName == "" {
+		return w.selfKey
+	}
+
+	workloadType, name, err := w.k8sClient.GetPodWorkload(ctx, w.config.PodNamespace, w.config.PodName)
+	if err != nil {
+		logger.Debugf("Unable to detect the kube-watchtower workload: %v", err)
+		return ""
+	}
+	w.selfDetected = true
+	if name != "" {
+		w.selfKey = workloadKey(w.config.PodNamespace, name)
+		logger.Debugf("Running as %s %s", workloadType, w.selfKey)
+	}
+	return w.selfKey
+}
+
+// splitSelf separates kube-watchtower's own workload from the others, so it can be checked last
+func (w *Watcher) splitSelf(ctx context.Context, workloads []k8s.WorkloadInfo) (others, self []k8s.WorkloadInfo) {
+	key := w.detectSelf(ctx)
+	if key == "" {
+		return workloads, nil
+	}
+	for _, workload := range workloads {
+		if workloadKey(workload.Namespace, workload.Name) == key {
+			self = append(self, workload)
+		} else {
+			others = append(others, workload)
+		}
+	}
+	return others, self
+}
+
+// isSelf checks if a workload is the one running kube-watchtower
+func (w *Watcher) isSelf(workload k8s.WorkloadInfo) bool {
+	return w.selfKey != "" && workloadKey(workload.Namespace, workload.Name) == w.selfKey
+}
@@ -0,0 +1,117 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/notifier"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+)
+
+// annotationRestartStalePods overrides RESTART_STALE_PODS for a DaemonSet ("true" or "false")
+const annotationRestartStalePods = "kube-watchtower.io/restart-stale-pods"
+
+// stalePodTimeout bounds the wait for the replacement of an evicted stale pod
+const stalePodTimeout = 5 * time.Minute
+
+// checkDigestSkew reports the pods of an up-to-date DaemonSet still running an outdated digest,
+// typically nodes that kept a cached image with imagePullPolicy IfNotPresent,
+// and optionally restarts just those pods, evicting one at a time
+// Returns false if restarting the stale pods failed or was deferred.
+func (w *Watcher) checkDigestSkew(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, nsConfig *config.NamespaceConfig, cfg *config.Config, monitorOnly bool, source notifier.Source, digest string, credentials *registry.RegistryCredentials, status *ContainerStatus) bool {
+	if workload.Type != k8s.WorkloadTypeDaemonSet || len(container.RunningDigests) <= 1 || workload.Selector == nil {
+		return true
+	}
+
+	stale, err := w.k8sClient.GetStalePods(ctx, workload.Namespace, workload.Selector, container.Name, digest)
+	if err != nil {
+		logger.Warnf("Failed to list stale pods of %s/%s: %v", workload.Namespace, workload.Name, err)
+		return true
+	}
+	if len(stale) == 0 {
+		return true
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Node < stale[j].Node })
+
+	nodes := make([]string, 0, len(stale))
+	for _, pod := range stale {
+		nodes = append(nodes, pod.Node)
+	}
+	status.StaleNodes = nodes
+	logger.Warnf("Nodes of %s/%s/%s run an outdated digest of %s: %s", workload.Namespace, workload.Name, container.Name, container.Image, formatStalePods(stale))
+	w.publish(Event{Type: EventDigestSkew, Namespace: workload.Namespace, Kind: string(workload.Type), Workload: workload.Name, Container: container.Name, Image: container.Image, Digest: digest, Message: "stale on " + strings.Join(nodes, ", ")})
+
+	if !w.restartStalePods(workload) {
+		return true
+	}
+	if cfg.DryRun || monitorOnly {
+		logger.Infof("Not restarting %d stale pod(s) of %s/%s (dry-run or outside the update window)", len(stale), workload.Namespace, workload.Name)
+		return true
+	}
+	if workload.OnDelete || workload.RollingOut {
+		// The old pods of an OnDelete DaemonSet or of a running rollout are not node cache skew
+		logger.Infof("Not restarting %d stale pod(s) of %s/%s (OnDelete update strategy or rollout in progress)", len(stale), workload.Namespace, workload.Name)
+		return true
+	}
+	// The skew is what the restart repairs, it must not defer it
+	settled := container
+	settled.RunningDigests = nil
+	if reason, deferred := w.shouldDefer(ctx, workload, settled, container.Image, digest, nil, credentials); deferred {
+		logger.Infof("Deferring restart of stale pods of %s/%s: %s", workload.Namespace, workload.Name, reason)
+		w.addDeferred(nsConfig, source, container.Image+" (stale pods)", reason)
+		return false
+	}
+
+	for _, pod := range stale {
+		logger.Infof("Restarting stale pod %s/%s on node %s", workload.Namespace, pod.Name, pod.Node)
+		err := w.k8sClient.EvictPod(ctx, workload.Namespace, pod.Name)
+		if errors.Is(err, k8s.ErrEvictionBlocked) {
+			logger.Infof("Deferring restart of stale pods of %s/%s: %v", workload.Namespace, workload.Name, err)
+			w.addDeferred(nsConfig, source, container.Image+" (stale pods)", k8s.ErrEvictionBlocked.Error())
+			return false
+		}
+		if err == nil {
+			err = w.k8sClient.WaitForNodePod(ctx, workload.Namespace, workload.Selector, pod.Node, pod.Name, stalePodTimeout)
+		}
+		if err != nil {
+			logger.Errorf("Failed to restart stale pods of %s/%s: %v", workload.Namespace, workload.Name, err)
+			w.addResult(nsConfig, source, container.Image, false, err)
+			return false
+		}
+	}
+	w.addResult(nsConfig, source, fmt.Sprintf("%s (restarted stale pods on %s)", container.Image, strings.Join(nodes, ", ")), true, nil)
+	return true
+}
+
+// restartStalePods reports whether the stale pods of a DaemonSet are restarted
+func (w *Watcher) restartStalePods(workload k8s.WorkloadInfo) bool {
+	switch workload.Annotations[annotationRestartStalePods] {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return w.config.RestartStalePods
+}
+
+// formatStalePods formats stale pods as "node (digest)"
+func formatStalePods(stale []k8s.StalePod) string {
+	parts := make([]string, 0, len(stale))
+	for _, pod := range stale {
+		digest := pod.Digest
+		if digest == "" {
+			digest = "unknown"
+		} else {
+			digest = shortDigest(digest)
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", pod.Node, digest))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,129 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// Smoke test annotations (set on the workload)
+const (
+	annotationSmokeTestURL     = "kube-watchtower.io/smoke-test-url"     // Full URL (e.g. Service DNS) or a path tested on every pod IP
+	annotationSmokeTestPort    = "kube-watchtower.io/smoke-test-port"    // Pod port used with a path-only URL
+	annotationSmokeTestStatus  = "kube-watchtower.io/smoke-test-status"  // Expected HTTP status
+	annotationSmokeTestTimeout = "kube-watchtower.io/smoke-test-timeout" // Time allowed for the test to pass
+)
+
+// Smoke test defaults
+const (
+	defaultSmokeTestPort    = "80"
+	defaultSmokeTestStatus  = http.StatusOK
+	defaultSmokeTestTimeout = 30 * time.Second
+	smokeTestRetryInterval  = 2 * time.Second
+	smokeTestRequestTimeout = 5 * time.Second
+)
+
+// runSmokeTest checks the workload's smoke test endpoint after a rollout
+// The test is retried until it passes or its timeout expires
+func (w *Watcher) runSmokeTest(ctx context.Context, workload k8s.WorkloadInfo) error {
+	target := workload.Annotations[annotationSmokeTestURL]
+	if target == "" {
+		return nil
+	}
+
+	expectedStatus := defaultSmokeTestStatus
+	if value := workload.Annotations[annotationSmokeTestStatus]; value != "" {
+		status, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation: %w", annotationSmokeTestStatus, err)
+		}
+		expectedStatus = status
+	}
+
+	timeout := defaultSmokeTestTimeout
+	if value := workload.Annotations[annotationSmokeTestTimeout]; value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation: %w", annotationSmokeTestTimeout, err)
+		}
+		timeout = parsed
+	}
+
+	logger.Infof("Running smoke test for %s/%s: %s", workload.Namespace, workload.Name, target)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := &http.Client{Timeout: smokeTestRequestTimeout}
+
+	var lastErr error
+	for {
+		urls, err := w.smokeTestURLs(ctx, workload, target)
+		if err == nil {
+			err = checkURLs(ctx, client, urls, expectedStatus)
+		}
+		if err == nil {
+			logger.Infof("Smoke test passed for %s/%s", workload.Namespace, workload.Name)
+			return nil
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("smoke test did not pass within %s: %w", timeout, lastErr)
+		case <-time.After(smokeTestRetryInterval):
+		}
+	}
+}
+
+// smokeTestURLs resolves the URLs to test: the URL itself, or the path on every running pod
+func (w *Watcher) smokeTestURLs(ctx context.Context, workload k8s.WorkloadInfo, target string) ([]string, error) {
+	if !strings.HasPrefix(target, "/") {
+		return []string{target}, nil
+	}
+
+	port := workload.Annotations[annotationSmokeTestPort]
+	if port == "" {
+		port = defaultSmokeTestPort
+	}
+
+	ips, err := w.k8sClient.GetRunningPodIPs(ctx, workload.Namespace, workload.Selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no running pods found")
+	}
+
+	urls := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		urls = append(urls, fmt.Sprintf("http://%s%s", net.JoinHostPort(ip, port), target))
+	}
+	return urls, nil
+}
+
+// checkURLs requests every URL and compares the response status
+func checkURLs(ctx context.Context, client *http.Client, urls []string, expectedStatus int) error {
+	for _, url := range urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("invalid smoke test url %s: %w", url, err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", url, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			return fmt.Errorf("%s: status %d, expected %d", url, resp.StatusCode, expectedStatus)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package watcher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+)
+
+// Volume snapshot annotations (set on a StatefulSet)
+const (
+	annotationVolumeSnapshot      = "kube-watchtower.io/volume-snapshot"       // "true" or "false", overrides VOLUME_SNAPSHOTS
+	annotationVolumeSnapshotClass = "kube-watchtower.io/volume-snapshot-class" // Overrides VOLUME_SNAPSHOT_CLASS
+)
+
+// volumeSnapshots is the outcome of the snapshots of a workload, taken once per check
+type volumeSnapshots struct {
+	once  sync.Once
+	names []string
+	err   error
+}
+
+// volumeSnapshots returns the snapshots of a workload in this check, so that updating several of its containers
+// snapshots the volumes only before the first update
+func (s *cycleStats) volumeSnapshots(workload k8s.WorkloadInfo) *volumeSnapshots {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := workloadKey(workload.Namespace, workload.Name)
+	snapshots, ok := s.snapshots[key]
+	if !ok {
+		snapshots = &volumeSnapshots{}
+		s.snapshots[key] = snapshots
+	}
+	return snapshots
+}
+
+// snapshotVolumes snapshots the PVCs of a StatefulSet before its update, if enabled globally or by annotation
+// The volumes are snapshotted once per check of the workload, later updates of its containers reuse the outcome.
+// Returns the names of the created VolumeSnapshots.
+func (w *Watcher) snapshotVolumes(ctx context.Context, workload k8s.WorkloadInfo, stats *cycleStats) ([]string, error) {
+	snapshots := stats.volumeSnapshots(workload)
+	snapshots.once.Do(func() {
+		snapshots.names, snapshots.err = w.createVolumeSnapshots(ctx, workload)
+	})
+	return snapshots.names, snapshots.err
+}
+
+// createVolumeSnapshots creates the snapshots of snapshotVolumes
+func (w *Watcher) createVolumeSnapshots(ctx context.Context, workload k8s.WorkloadInfo) ([]string, error) {
+	if workload.Type != k8s.WorkloadTypeStatefulSet {
+		return nil, nil
+	}
+	enabled := w.config.VolumeSnapshots
+	if value, ok := workload.Annotations[annotationVolumeSnapshot]; ok {
+		enabled = value == "true"
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	snapshotClass := w.config.VolumeSnapshotClass
+	if value := workload.Annotations[annotationVolumeSnapshotClass]; value != "" {
+		snapshotClass = value
+	}
+	return w.k8sClient.SnapshotStatefulSetVolumes(ctx, workload.Namespace, workload.Name, snapshotClass, w.config.VolumeSnapshotTimeout)
+}
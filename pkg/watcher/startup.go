@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// NotifyStartup sends the startup notification, failures are only logged
+func (w *Watcher) NotifyStartup(ctx context.Context, version string) {
+	w.loadNotificationURL(ctx)
+	message := fmt.Sprintf("%skube-watchtower %s started on %s, %s", w.emoji("title"), version, w.config.NotificationCluster, w.describeMonitoring(ctx))
+	if err := w.notifier.Send(message); err != nil {
+		logger.Warnf("Failed to send startup notification: %v", err)
+	}
+}
+
+// NotifyTest sends a test notification to validate the notification URL
+func (w *Watcher) NotifyTest(ctx context.Context, version string) error {
+	w.loadNotificationURL(ctx)
+	message := fmt.Sprintf("%skube-watchtower %s test notification from %s, %s", w.emoji("test"), version, w.config.NotificationCluster, w.describeMonitoring(ctx))
+	return w.notifier.Send(message)
+}
+
+// describeMonitoring describes the monitored namespaces
+func (w *Watcher) describeMonitoring(ctx context.Context) string {
+	count, err := w.monitoredNamespaces(ctx)
+	if err != nil {
+		logger.Debugf("Failed to count monitored namespaces: %v", err)
+		return "monitored namespaces unknown"
+	}
+	return fmt.Sprintf("monitoring %d namespaces", count)
+}
+
+// monitoredNamespaces counts the namespaces passing the namespace filters
+func (w *Watcher) monitoredNamespaces(ctx context.Context) (int, error) {
+	namespaces, err := w.k8sClient.ListNamespacesBySelector(ctx, w.config.GetNamespaceSelector())
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for namespace := range namespaces {
+		if w.config.IsNamespaceAllowed(namespace) {
+			count++
+		}
+	}
+	return count, nil
+}
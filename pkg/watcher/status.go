@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// statusDataKey is the status ConfigMap key holding the serialized cycle status
+const statusDataKey = "status.json"
+
+// CycleStatus describes the last check cycle
+type CycleStatus struct {
+	Cluster   string          `json:"cluster,omitempty"`
+	StartTime time.Time       `json:"startTime"`
+	EndTime   time.Time       `json:"endTime"`
+	DryRun    bool            `json:"dryRun,omitempty"`
+	Scanned   int             `json:"scanned"`
+	Updated   int             `json:"updated"`
+	Failed    int             `json:"failed"`
+	Pending   []PendingUpdate `json:"pending,omitempty"`
+}
+
+// PendingUpdate is an available update that was not applied in the cycle
+type PendingUpdate struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	NewDigest string `json:"newDigest"`
+	Reason    string `json:"reason"` // dry-run, monitor-only, deferral reason or error
+}
+
+// LastStatus returns the status of the last completed check cycle, nil before the first one
+func (w *Watcher) LastStatus() *CycleStatus {
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+	return w.lastStatus
+}
+
+// saveStatus keeps the cycle status and writes it to the status ConfigMap
+func (w *Watcher) saveStatus(ctx context.Context, status *CycleStatus) {
+	w.statusMu.Lock()
+	w.lastStatus = status
+	w.statusMu.Unlock()
+
+	if w.config.StatusConfigMap == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		logger.Warnf("Failed to encode status: %v", err)
+		return
+	}
+
+	err = w.k8sClient.SaveConfigMapData(ctx, w.config.PodNamespace, w.config.StatusConfigMap, map[string]string{
+		"startTime":   status.StartTime.Format(time.RFC3339),
+		"endTime":     status.EndTime.Format(time.RFC3339),
+		"scanned":     strconv.Itoa(status.Scanned),
+		"updated":     strconv.Itoa(status.Updated),
+		"failed":      strconv.Itoa(status.Failed),
+		"pending":     strconv.Itoa(len(status.Pending)),
+		statusDataKey: string(data),
+	})
+	if err != nil {
+		logger.Warnf("Failed to save status ConfigMap %s/%s: %v", w.config.PodNamespace, w.config.StatusConfigMap, err)
+	}
+}
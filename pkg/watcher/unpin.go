@@ -0,0 +1,154 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/config"
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/notifier"
+	"github.com/qetesh/kube-watchtower/pkg/registry"
+	"github.com/qetesh/kube-watchtower/pkg/state"
+)
+
+// annotationUnpin set to "true" on a workload removes its digest pins during the check,
+// instead of updating the pinned digests
+const annotationUnpin = "kube-watchtower.io/unpin"
+
+// UnpinResult describes the digest pins removed from a workload
+type UnpinResult struct {
+	Namespace string            `json:"namespace"`
+	Kind      string            `json:"kind"`
+	Name      string            `json:"name"`
+	Images    map[string]string `json:"images"` // Container -> restored repo:tag image
+}
+
+// Unpin restores the repo:tag images of a workload pinned as repo:tag@digest (optionally a single container)
+// and waits for the rollout to complete
+// Like a rollback, a manual unpin is applied right away, outside the update gates.
+func (w *Watcher) Unpin(ctx context.Context, namespace, kind, name, container string) (*UnpinResult, error) {
+	workloadType, ok := k8s.ParseWorkloadType(kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown workload kind %q: expected deployment, daemonset, statefulset or cronjob", kind)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	template, err := w.k8sClient.GetWorkloadTemplate(ctx, workloadType, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UnpinResult{
+		Namespace: namespace,
+		Kind:      string(workloadType),
+		Name:      name,
+		Images:    make(map[string]string),
+	}
+	for _, c := range template.Spec.Containers {
+		if container != "" && c.Name != container {
+			continue
+		}
+		image, digest := unpinnedImage(c.Image)
+		if digest == "" {
+			continue
+		}
+
+		logger.Infof("Unpinning %s/%s/%s (%s): %s -> %s", namespace, name, c.Name, workloadType, c.Image, image)
+		if err := w.updater.UpdateWorkloadImage(ctx, workloadType, namespace, name, c.Name, image, digest); err != nil {
+			return nil, fmt.Errorf("failed to update %s: %w", workloadType, err)
+		}
+		result.Images[c.Name] = image
+	}
+
+	if len(result.Images) == 0 {
+		return result, nil
+	}
+	if err := w.updater.WaitForRollout(ctx, workloadType, namespace, name, 5*time.Minute); err != nil {
+		return nil, fmt.Errorf("rollout failed: %w", err)
+	}
+	return result, nil
+}
+
+// unpinWorkload removes the digest pins of a workload annotated with kube-watchtower.io/unpin (caller holds mu)
+// The unpins pass the same gates as image updates (dry-run, schedule, deferrals, PodDisruptionBudgets, rollout slots).
+// Returns false if an unpin failed or was deferred.
+func (w *Watcher) unpinWorkload(ctx context.Context, workload k8s.WorkloadInfo, nsConfig *config.NamespaceConfig, cfg *config.Config, monitorOnly, noWait bool, blocked string, stats *cycleStats) bool {
+	ok := true
+	pinned := false
+	for _, container := range workload.Containers {
+		if _, digest := unpinnedImage(container.Image); digest == "" || container.SkewOnly {
+			continue
+		}
+		pinned = true
+		if !w.unpinContainer(ctx, workload, container, nsConfig, cfg, monitorOnly, noWait, blocked, stats) {
+			ok = false
+		}
+	}
+	if !pinned {
+		logger.Debugf("Skipping workload: %s/%s (unpinned)", workload.Namespace, workload.Name)
+	}
+	return ok
+}
+
+// unpinContainer restores the repo:tag image of a pinned container through applyUpdate
+// The digest the tag points to is resolved first, the rollout is verified against it.
+func (w *Watcher) unpinContainer(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, nsConfig *config.NamespaceConfig, cfg *config.Config, monitorOnly, noWait bool, blocked string, stats *cycleStats) bool {
+	source := notifier.Source{Namespace: workload.Namespace, Kind: string(workload.Type), Workload: workload.Name, Container: container.Name}
+	stats.scanned(w.routedURLs(nsConfig, source))
+	stateKey := state.Key(workload.Namespace, string(workload.Type), workload.Name, container.Name)
+	status := stats.checkedContainer(workload, container)
+
+	image, pinnedDigest := unpinnedImage(container.Image)
+	if container.CurrentDigest == "" {
+		container.CurrentDigest = pinnedDigest
+	}
+	status.CurrentDigest = container.CurrentDigest
+
+	credentials := w.containerCredentials(ctx, workload, container)
+	_, newDigest, err := w.imageChecker.CheckForUpdate(ctx, image, credentials)
+	if errors.Is(err, registry.ErrRateLimited) {
+		w.deferRateLimited(workload, container, nsConfig, source, status, err)
+		stats.incompleteCheck(workload)
+		return false
+	}
+	if errors.Is(err, registry.ErrCircuitOpen) {
+		logger.Debugf("Skipping container: %s/%s/%s (%v)", workload.Namespace, workload.Name, container.Name, err)
+		status.Status, status.Reason = ContainerSkipped, "registry unavailable"
+		stats.incompleteCheck(workload)
+		return true
+	}
+	if err != nil {
+		logger.Errorf("Failed to resolve %s for unpinning %s/%s/%s: %v", image, workload.Namespace, workload.Name, container.Name, err)
+		status.Status, status.Reason = ContainerFailed, err.Error()
+		w.addResult(nsConfig, source, container.Image, false, err)
+		w.store.RecordFailure(stateKey, err)
+		stats.failed()
+		return false
+	}
+	status.RemoteDigest = newDigest
+
+	logger.Infof("Unpinning %s/%s/%s (%s): %s -> %s", workload.Namespace, workload.Name, container.Name, workload.Type, container.Image, image)
+	metadata := w.imageMetadata(ctx, registry.ParseImage(image).Repository, newDigest, credentials)
+	label := fmt.Sprintf("%s → %s (unpinned), %s", container.Image, image, describeDigests(container.CurrentDigest, newDigest))
+	return w.applyUpdate(ctx, updateRequest{
+		workload: workload, container: container, nsConfig: nsConfig, cfg: cfg,
+		monitorOnly: monitorOnly, noWait: noWait, blocked: blocked, stats: stats, status: status,
+		source: source, stateKey: stateKey, newImage: image, newDigest: newDigest, label: label,
+		metadata: metadata, credentials: credentials,
+	})
+}
+
+// unpinnedImage splits a repo:tag@digest image into repo:tag and the digest,
+// other images (including repo@digest without a tag) are returned unchanged with an empty digest
+func unpinnedImage(image string) (string, string) {
+	name, digest, found := strings.Cut(image, "@")
+	if !found || isDigestPinned(image) {
+		return image, ""
+	}
+	return name, digest
+}
@@ -0,0 +1,101 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/state"
+)
+
+// UpdateOptions configures a targeted update
+type UpdateOptions struct {
+	DryRun    bool   // Only report the update
+	Container string // Only check this container, all if empty
+	NoWait    bool   // Return once the new image is applied, skipping the rollout wait and post-rollout steps
+}
+
+// UpdateWorkload checks a single workload and, if a newer image is available, updates it immediately,
+// outside the update schedule and regardless of workload filters
+// Pauses, policies and other safety checks still apply, as does the owner, Helm and CronJob handling:
+// skipped workloads are rejected, deferred ones are reported as pending. Updating kube-watchtower's own
+// workload does not wait for the rollout.
+func (w *Watcher) UpdateWorkload(ctx context.Context, namespace, kind, name string, opts UpdateOptions) (*WorkloadStatus, error) {
+	workloadType, ok := k8s.ParseWorkloadType(kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown workload kind %q: expected deployment, daemonset, statefulset or cronjob", kind)
+	}
+	if !w.config.IsNamespaceAllowed(namespace) {
+		return nil, fmt.Errorf("namespace %s is not monitored", namespace)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	workload, err := w.k8sClient.GetWorkload(ctx, workloadType, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if workload.Annotations[annotationUnpin] == "true" {
+		return nil, fmt.Errorf("%s %s/%s is unpinned (%s)", workloadType, namespace, name, annotationUnpin)
+	}
+	skip, blocked := w.workloadRestrictions(*workload)
+	if skip != "" {
+		return nil, fmt.Errorf("%s %s/%s is not updated: %s", workloadType, namespace, name, skip)
+	}
+	if opts.Container != "" {
+		containers := workload.Containers[:0:0]
+		for _, c := range workload.Containers {
+			if c.Name == opts.Container {
+				containers = append(containers, c)
+			}
+		}
+		if len(containers) == 0 {
+			return nil, fmt.Errorf("container %s not found in %s %s/%s", opts.Container, workloadType, namespace, name)
+		}
+		workload.Containers = containers
+	}
+
+	nsConfig := w.loadNamespaceConfigs(ctx, []k8s.WorkloadInfo{*workload})[namespace]
+	if !nsConfig.IsEnabled() {
+		return nil, fmt.Errorf("namespace %s is disabled by its namespace config", namespace)
+	}
+
+	logger.Infof("Checking %s/%s (%s) on request", namespace, name, workloadType)
+	w.cycle = cycleID(time.Now())
+	w.loadNotificationURL(ctx)
+	w.resetNotifiers()
+	if err := w.store.Load(ctx); err != nil {
+		logger.Warnf("Failed to load state: %v", err)
+	}
+	defer func() {
+		if err := w.store.Save(ctx); err != nil {
+			logger.Warnf("Failed to save state: %v", err)
+		}
+	}()
+	w.loadNamespacePause(ctx)
+	w.loadStaticCredentials()
+	w.loadWorkloadNotificationURL(ctx, *workload)
+	w.detectSelf(ctx)
+
+	// A requested update is retried now, regardless of the failure backoff
+	for _, c := range workload.Containers {
+		w.store.ResetFailures(state.Key(namespace, string(workloadType), name, c.Name))
+	}
+
+	cfg := w.config.WithNamespaceConfig(nsConfig)
+	cfg.DryRun = cfg.DryRun || opts.DryRun
+
+	stats := newCycleStats()
+	noWait := opts.NoWait || w.skipsRolloutWait(*workload)
+	w.checkContainers(ctx, *workload, nsConfig, cfg, false, noWait, blocked, stats)
+	w.sendSummaries(stats)
+
+	status := WorkloadStatus{Namespace: namespace, Kind: string(workloadType), Name: name, Containers: []ContainerStatus{}}
+	if statuses := w.workloadStatuses(stats); len(statuses) > 0 {
+		status = statuses[0]
+	}
+	return &status, nil
+}
@@ -0,0 +1,45 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// verifyRunningDigest checks that all running pods of the workload run the expected digest
+// after the rollout, catching stale cached images or a different platform digest
+func (w *Watcher) verifyRunningDigest(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, expected string) error {
+	if workload.Selector == nil {
+		return nil
+	}
+
+	digests, err := w.k8sClient.GetRunningDigests(ctx, workload.Namespace, workload.Selector, container.Name)
+	if err != nil {
+		return err
+	}
+	if len(digests) == 0 {
+		logger.Debugf("No running pods of %s/%s to verify", workload.Namespace, workload.Name)
+		return nil
+	}
+
+	var mismatched []string
+	for pod, digest := range digests {
+		if digest != expected {
+			if digest == "" {
+				digest = "unknown"
+			}
+			mismatched = append(mismatched, fmt.Sprintf("%s (%s)", pod, digest))
+		}
+	}
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		return fmt.Errorf("%d/%d pods do not run %s: %s", len(mismatched), len(digests), expected, strings.Join(mismatched, ", "))
+	}
+
+	logger.Debugf("Verified %d pods of %s/%s run %s", len(digests), workload.Namespace, workload.Name, expected)
+	return nil
+}
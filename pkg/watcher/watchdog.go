@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// watchdogInterval is how often the watchdog looks for a stuck check cycle
+const watchdogInterval = 30 * time.Second
+
+// watchdog records the progress of check cycles
+type watchdog struct {
+	cycleStart   atomic.Int64 // Unix nanoseconds, 0 while no cycle runs
+	heartbeat    atomic.Int64 // Unix nanoseconds of the last progress
+	lastDuration atomic.Int64 // Nanoseconds of the last completed cycle
+}
+
+// start records the start of a cycle
+func (d *watchdog) start() {
+	now := time.Now().UnixNano()
+	d.heartbeat.Store(now)
+	d.cycleStart.Store(now)
+}
+
+// beat records progress of the running cycle
+func (d *watchdog) beat() {
+	d.heartbeat.Store(time.Now().UnixNano())
+}
+
+// finish records the end of a cycle
+func (d *watchdog) finish() {
+	if start := d.cycleStart.Swap(0); start != 0 {
+		d.lastDuration.Store(time.Now().UnixNano() - start)
+	}
+}
+
+// Healthy returns an error if a check cycle made no progress for WatchdogFactor times
+// the duration of the last cycle (at least WatchdogMinTimeout)
+func (w *Watcher) Healthy() error {
+	if w.config.WatchdogFactor <= 0 || w.watchdog.cycleStart.Load() == 0 {
+		return nil
+	}
+
+	limit := time.Duration(float64(w.config.WatchdogFactor) * float64(w.watchdog.lastDuration.Load()))
+	if limit < w.config.WatchdogMinTimeout {
+		limit = w.config.WatchdogMinTimeout
+	}
+	stalled := time.Since(time.Unix(0, w.watchdog.heartbeat.Load()))
+	if stalled > limit {
+		return fmt.Errorf("check cycle stuck: no progress for %s (limit %s)", stalled.Round(time.Second), limit.Round(time.Second))
+	}
+	return nil
+}
+
+// watch logs stuck check cycles and, with WatchdogExit, terminates the process so it is restarted
+func (w *Watcher) watch(ctx context.Context) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	reported := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := w.Healthy()
+			if err == nil {
+				reported = false
+				continue
+			}
+			if w.config.WatchdogExit {
+				logger.Fatalf("Watchdog: %v, exiting", err)
+			}
+			if !reported {
+				logger.Errorf("Watchdog: %v", err)
+				reported = true
+			}
+		}
+	}
+}
@@ -2,192 +2,1097 @@ package watcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"os"
+	"path"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/qetesh/kube-watchtower/pkg/audit"
 	"github.com/qetesh/kube-watchtower/pkg/config"
 	"github.com/qetesh/kube-watchtower/pkg/k8s"
 	"github.com/qetesh/kube-watchtower/pkg/logger"
+	"github.com/qetesh/kube-watchtower/pkg/metrics"
 	"github.com/qetesh/kube-watchtower/pkg/notifier"
+	"github.com/qetesh/kube-watchtower/pkg/policy"
 	"github.com/qetesh/kube-watchtower/pkg/registry"
+	"github.com/qetesh/kube-watchtower/pkg/state"
 )
 
 // Watcher monitors and updates container images
 type Watcher struct {
-	config       *config.Config
-	k8sClient    *k8s.Client
-	imageChecker *registry.ImageChecker
-	notifier     *notifier.Notifier
+	config        *config.Config
+	k8sClient     *k8s.Client
+	lister        k8s.WorkloadLister
+	updater       k8s.Updater
+	imageChecker  registry.ImageResolver
+	notifier      Notifier
+	nsNotifiers   map[string]*notifier.Notifier // Keyed by namespace or workload notification URL
+	workloadURLs  map[string]string             // Workload notification URLs by workloadKey
+	store         *state.Store
+	audit         *audit.Exporter
+	policy        *policy.Client
+	rollouts      *rolloutLimiter
+	notifiersMu   sync.Mutex  // Guards nsNotifiers and workloadURLs while workloads are checked concurrently
+	pauseMu       sync.Mutex  // Guards apiPause and nsPause
+	apiPause      pauseState  // Set through Pause / Resume
+	nsPause       pauseState  // Read from the kube-watchtower namespace every check
+	draining      atomic.Bool // Set on shutdown, no new updates are started
+	statusMu      sync.Mutex  // Guards lastStatus and lastWorkloads
+	lastStatus    *CycleStatus
+	lastWorkloads []WorkloadStatus   // Checked in the last cycle, guarded by statusMu
+	staticCreds   []k8s.RegistryAuth // Read from RegistryRobots and RegistryCredentialsFile every check
+	watchdog      watchdog
+	events        eventBroker
+	selfKey       string     // Workload running kube-watchtower, set by detectSelf
+	selfDetected  bool       // Whether selfKey was looked up successfully
+	cycle         string     // Check cycle or manual update in progress, recorded with its updates (guarded by mu)
+	mu            sync.Mutex // Serializes check cycles and manual operations
 }
 
-// NewWatcher creates a new watcher
+// NewWatcher creates a new watcher with the default components
 func NewWatcher(cfg *config.Config) (*Watcher, error) {
-	k8sClient, err := k8s.NewClient()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create k8s client: %w", err)
-	}
-
-	imageChecker, err := registry.NewImageChecker()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create image checker: %w", err)
-	}
-
-	notif := notifier.NewNotifier(cfg.NotificationURL, cfg.NotificationCluster, cfg.DryRun)
-
-	return &Watcher{
-		config:       cfg,
-		k8sClient:    k8sClient,
-		imageChecker: imageChecker,
-		notifier:     notif,
-	}, nil
+	return New(cfg, Options{})
 }
 
 // Run runs the watcher
+// With a CheckInterval the watcher keeps checking until ctx is cancelled,
+// otherwise it returns after a single check
 func (w *Watcher) Run(ctx context.Context) error {
+	if w.config.WatchdogFactor > 0 {
+		go w.watch(ctx)
+	}
+
 	// Run initial check
+	if err := w.splay(ctx); err != nil {
+		return err
+	}
 	if err := w.check(ctx); err != nil {
 		logger.Errorf("Initial check failed: %v", err)
 	}
 
-	return nil
+	if w.config.CheckInterval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(w.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := w.splay(ctx); err != nil {
+				return err
+			}
+			if err := w.check(ctx); err != nil {
+				logger.Errorf("Check failed: %v", err)
+			}
+		}
+	}
+}
+
+// CheckOnce runs a single check cycle
+func (w *Watcher) CheckOnce(ctx context.Context) error {
+	return w.check(ctx)
+}
+
+// splay waits a random time up to CheckJitter, so many instances don't hit the registries at once
+func (w *Watcher) splay(ctx context.Context) error {
+	if w.config.CheckJitter <= 0 {
+		return nil
+	}
+
+	delay := rand.N(w.config.CheckJitter)
+	logger.Debugf("Delaying check by %s", delay.Round(time.Second))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ClusterName returns the kubeconfig context of the watcher, empty for the default cluster
+func (w *Watcher) ClusterName() string {
+	return w.config.KubeContext
 }
 
 // check performs one check cycle
-func (w *Watcher) check(ctx context.Context) error {
-	logger.Debug("Starting image update check...")
+func (w *Watcher) check(stop context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Let in-flight updates finish after stop is cancelled
+	ctx, cancel := w.drainContext(stop)
+	defer cancel()
+
+	startTime := time.Now()
+	w.cycle = cycleID(startTime)
+	w.watchdog.start()
+	defer w.watchdog.finish()
+	w.publish(Event{Type: EventCheckStarted})
+
+	if w.config.KubeContext != "" {
+		logger.Debugf("Starting image update check on cluster %s...", w.config.KubeContext)
+	} else {
+		logger.Debug("Starting image update check...")
+	}
 
 	// Reset notifier results for this check cycle
-	if w.notifier != nil {
-		w.notifier.Reset()
+	w.loadNotificationURL(ctx)
+	w.resetNotifiers()
+
+	// Load persisted state
+	if err := w.store.Load(ctx); err != nil {
+		logger.Warnf("Failed to load state: %v", err)
 	}
+	defer func() {
+		if err := w.store.Save(ctx); err != nil {
+			logger.Warnf("Failed to save state: %v", err)
+		}
+	}()
 
-	// List all workloads (Deployments, DaemonSets, StatefulSets)
+	// List all workloads (Deployments, DaemonSets, StatefulSets, CronJobs)
 	// Pass config for namespace filtering (whitelist or blacklist mode)
-	workloads, err := w.k8sClient.ListWorkloads(ctx, w.config)
+	workloads, err := w.lister.ListWorkloads(ctx, w.config)
 	if err != nil {
 		return fmt.Errorf("failed to list workloads: %w", err)
 	}
 
 	logger.Debugf("Found %d workloads to monitor", len(workloads))
 
-	updatedCount := 0
-	failedCount := 0
-	scannedCount := 0
+	// Load namespace-local policy
+	nsConfigs := w.loadNamespaceConfigs(ctx, workloads)
+	w.loadNamespacePause(ctx)
+	w.loadStaticCredentials()
 
-	// Check each workload
-	for _, workload := range workloads {
-		for _, container := range workload.Containers {
-			scannedCount++
-
-			logger.Debugf("Checking container: %s/%s/%s (%s)", workload.Namespace, workload.Name, container.Name, workload.Type)
-			logger.Debugf("  Image: %s", container.Image)
-			logger.Debugf("  Current Digest: %s", container.CurrentDigest)
-
-			// Get registry credentials if imagePullSecrets are defined
-			var credentials *registry.RegistryCredentials
-			if len(workload.ImagePullSecrets) > 0 {
-				logger.Debugf("  ImagePullSecrets found: \x1b[96m%v\x1b[0m", workload.ImagePullSecrets)
-				credentials = w.getCredentialsForImage(ctx, workload.Namespace, workload.ImagePullSecrets, container.Image)
-			}
+	stats := newCycleStats()
+
+	// Check kube-watchtower's own workload last, so updating it doesn't cut the cycle short
+	workloads, self := w.splitSelf(ctx, workloads)
 
-			// Check for updates
-			hasUpdate, newDigest, err := w.imageChecker.CheckForUpdate(ctx, container.Image, credentials)
-			if err != nil {
-				logger.Errorf("Failed to check image update for %s/%s/%s: %v", workload.Namespace, workload.Name, container.Name, err)
-				if w.notifier != nil {
-					w.notifier.AddResult(container.Image, false, err)
-				}
-				failedCount++
-				continue
+	// Order workloads so dependencies and earlier update groups are updated before their dependents
+	deps, workloads := newDependencyGraph(workloads, w.config.UpdateGroups, w.config.UpdateGroupDefault)
+
+	// Check workloads, CheckConcurrency at a time
+	workers := w.config.CheckConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	queue := make(chan k8s.WorkloadInfo)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for workload := range queue {
+				blocked := deps.wait(ctx, workload)
+				ok := w.safeCheckWorkload(ctx, workload, nsConfigs[workload.Namespace], stats, blocked)
+				deps.done(workload, ok, stats.completed(workload))
+				w.watchdog.beat()
 			}
+		}()
+	}
+	for _, workload := range workloads {
+		if stop.Err() != nil {
+			logger.Info("Shutting down, not checking remaining workloads")
+			break
+		}
+		queue <- workload
+	}
+	close(queue)
+	wg.Wait()
+	for _, workload := range self {
+		if stop.Err() != nil {
+			break
+		}
+		w.safeCheckWorkload(ctx, workload, nsConfigs[workload.Namespace], stats, "")
+	}
+
+	// Forget the state of containers that are gone, only once every listed workload was checked
+	if stop.Err() == nil {
+		w.store.Prune(stateKeys(workloads, self))
+	}
+
+	scannedCount, updatedCount, failedCount := stats.scannedCount, stats.updatedCount, stats.failedCount
+
+	// Send summary notification
+	undelivered := w.sendSummaries(stats)
+	w.evictNotifiers(append(workloads, self...), nsConfigs)
+
+	// Session done (like watchtower)
+	clusterInfo := ""
+	if w.config.KubeContext != "" {
+		clusterInfo = fmt.Sprintf(" Cluster=%s", w.config.KubeContext)
+	}
+	if undelivered > 0 {
+		// Expired webhooks and revoked tokens otherwise only show as a warning among the check logs
+		clusterInfo += fmt.Sprintf(" NotificationsFailed=%d", undelivered)
+	}
+	if w.config.DryRun {
+		logger.Infof("[DRY-RUN] Session done%s Scanned=%d Detected=%d Failed=%d", clusterInfo, scannedCount, updatedCount, failedCount)
+	} else {
+		logger.Infof("Session done%s Scanned=%d Updated=%d Failed=%d", clusterInfo, scannedCount, updatedCount, failedCount)
+	}
+
+	// Publish cycle status
+	metrics.ObserveCycle(time.Since(startTime), scannedCount, updatedCount, failedCount)
+	workloadStatuses := w.workloadStatuses(stats)
+	w.statusMu.Lock()
+	w.lastWorkloads = workloadStatuses
+	w.statusMu.Unlock()
+	w.saveStatus(ctx, &CycleStatus{
+		Cluster:   w.config.KubeContext,
+		StartTime: startTime,
+		EndTime:   time.Now(),
+		DryRun:    w.config.DryRun,
+		Scanned:   scannedCount,
+		Updated:   updatedCount,
+		Failed:    failedCount,
+		Pending:   stats.pending,
+	})
 
-			logger.Debugf("  Remote Digest: %s", newDigest)
+	w.publish(Event{Type: EventCheckCompleted, Message: fmt.Sprintf("scanned=%d updated=%d failed=%d", scannedCount, updatedCount, failedCount)})
 
-			// If we have current digest, use it for comparison
-			if container.CurrentDigest != "" {
-				if container.CurrentDigest == newDigest {
-					logger.Debugf("No update needed: %s/%s/%s (digest matches)", workload.Namespace, workload.Name, container.Name)
-					continue
-				}
-				hasUpdate = true
+	return nil
+}
+
+// stateKeys returns the state keys of the containers of workloads
+func stateKeys(workloads ...[]k8s.WorkloadInfo) map[string]bool {
+	keys := make(map[string]bool)
+	for _, list := range workloads {
+		for _, workload := range list {
+			for _, container := range workload.Containers {
+				keys[state.Key(workload.Namespace, string(workload.Type), workload.Name, container.Name)] = true
 			}
+		}
+	}
+	return keys
+}
+
+// resetNotifiers clears the results of the global and namespace notifiers
+func (w *Watcher) resetNotifiers() {
+	if w.notifier != nil {
+		w.notifier.Reset()
+	}
+	for _, n := range w.nsNotifiers {
+		n.Reset()
+	}
+}
+
+// sendSummaries sends the summaries of the global and namespace notifiers
+// Returns the number of summaries that could not be delivered.
+func (w *Watcher) sendSummaries(stats *cycleStats) int {
+	var undelivered int64
+	if w.notifier != nil {
+		counter, counted := w.notifier.(deliveryCounter)
+		var before int64
+		if counted {
+			before = counter.Dropped()
+		}
+		w.notifier.SendSummary(stats.scannedCount)
+		if counted {
+			undelivered += counter.Dropped() - before
+		}
+	}
+	for url, n := range w.nsNotifiers {
+		before := n.Dropped()
+		n.SendSummary(stats.nsScanned[url])
+		undelivered += n.Dropped() - before
+	}
+	return int(undelivered)
+}
+
+// safeCheckWorkload runs checkWorkload, turning a panic into a failure of that workload
+func (w *Watcher) safeCheckWorkload(ctx context.Context, workload k8s.WorkloadInfo, nsConfig *config.NamespaceConfig, stats *cycleStats, blocked string) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("Recovered from panic checking %s/%s: %v\n%s", workload.Namespace, workload.Name, r, debug.Stack())
+			stats.failed()
+			stats.incompleteCheck(workload)
+			ok = false
+		}
+	}()
+	return w.checkWorkload(ctx, workload, nsConfig, stats, blocked)
+}
+
+// checkWorkload checks and updates the containers of one workload
+// A non-empty blocked reason defers all updates of the workload.
+// Returns false if an update failed or was deferred.
+func (w *Watcher) checkWorkload(ctx context.Context, workload k8s.WorkloadInfo, nsConfig *config.NamespaceConfig, stats *cycleStats, blocked string) bool {
+	if !nsConfig.IsEnabled() {
+		logger.Debugf("Skipping namespace: %s (disabled by namespace config)", workload.Namespace)
+		return true
+	}
+	if !w.config.WorkloadFilter.Allows(workload.Name) || !nsConfig.IsWorkloadAllowed(workload.Name) {
+		logger.Debugf("Skipping workload: %s/%s (filtered)", workload.Namespace, workload.Name)
+		return true
+	}
+	skip, deferral := w.workloadRestrictions(workload)
+	if skip != "" {
+		logger.Debugf("Skipping workload: %s/%s (%s)", workload.Namespace, workload.Name, skip)
+		return true
+	}
+	if blocked == "" {
+		blocked = deferral
+	}
+	w.loadWorkloadNotificationURL(ctx, workload)
+	cfg := w.config.WithNamespaceConfig(nsConfig)
+	noWait := w.skipsRolloutWait(workload)
+	monitorOnly := !cfg.DryRun && !nsConfig.IsUpdateAllowed(time.Now())
+	if workload.Annotations[annotationUnpin] == "true" {
+		return w.unpinWorkload(ctx, workload, nsConfig, cfg, monitorOnly, noWait, blocked, stats)
+	}
+	return w.checkContainers(ctx, workload, nsConfig, cfg, monitorOnly, noWait, blocked, stats)
+}
+
+// workloadRestrictions applies the owner, Helm and CronJob handling of a workload
+// Returns why the workload is skipped, or else why its updates are deferred (empty if they are not).
+func (w *Watcher) workloadRestrictions(workload k8s.WorkloadInfo) (skip, deferral string) {
+	switch w.ownedWorkloadHandling(workload.Owner) {
+	case config.OwnedWorkloadsSkip:
+		return fmt.Sprintf("owned by %s", workload.Owner), ""
+	case config.OwnedWorkloadsDefer:
+		// The owner would revert the image, new images are only reported
+		deferral = fmt.Sprintf("managed by %s", workload.Owner)
+	}
+	if workload.HelmRelease != "" && w.config.HelmWorkloads == config.HelmWorkloadsSkip {
+		return fmt.Sprintf("Helm release %s", workload.HelmRelease), ""
+	}
+	if workload.Suspended && !w.config.UpdateSuspendedCronJobs {
+		return "suspended CronJob", ""
+	}
+	return "", deferral
+}
 
-			if !hasUpdate {
-				logger.Debugf("No update needed: %s/%s/%s", workload.Namespace, workload.Name, container.Name)
-				continue
+// skipsRolloutWait checks if updates of a workload return once the new image is applied
+// Nothing to wait for when the rollout replaces this process, or when the workload is scaled
+// to zero (SCALED_TO_ZERO) or a CronJob and only the template is patched
+func (w *Watcher) skipsRolloutWait(workload k8s.WorkloadInfo) bool {
+	return w.isSelf(workload) || workload.Replicas == 0
+}
+
+// checkContainers checks and updates the containers of a workload
+// Returns false if an update failed or was deferred.
+// noWait returns from updates once the new image is applied, without awaiting the rollout and the post-rollout steps.
+func (w *Watcher) checkContainers(ctx context.Context, workload k8s.WorkloadInfo, nsConfig *config.NamespaceConfig, cfg *config.Config, monitorOnly, noWait bool, blocked string, stats *cycleStats) bool {
+	ok := true
+	for _, container := range workload.Containers {
+		if !w.checkContainer(ctx, workload, container, nsConfig, cfg, monitorOnly, noWait, blocked, stats) {
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// checkContainer checks and updates one container of a workload
+// A panic fails only this container.
+// Returns false if an update failed or was deferred.
+func (w *Watcher) checkContainer(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, nsConfig *config.NamespaceConfig, cfg *config.Config, monitorOnly, noWait bool, blocked string, stats *cycleStats) (ok bool) {
+	source := notifier.Source{Namespace: workload.Namespace, Kind: string(workload.Type), Workload: workload.Name, Container: container.Name}
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("Recovered from panic checking %s/%s/%s: %v\n%s", workload.Namespace, workload.Name, container.Name, r, debug.Stack())
+			w.addResult(nsConfig, source, container.Image, false, fmt.Errorf("internal error: %v", r))
+			stats.failed()
+			ok = false
+		}
+	}()
+
+	if w.config.IsContainerDisabled(container.Name) {
+		logger.Debugf("Skipping container: %s/%s/%s (disabled)", workload.Namespace, workload.Name, container.Name)
+		return true
+	}
+	if isContainerIgnored(workload.Annotations, container.Name) {
+		logger.Debugf("Skipping container: %s/%s/%s (ignored by %s)", workload.Namespace, workload.Name, container.Name, annotationIgnoreContainers)
+		return true
+	}
+	if !nsConfig.IsTagAllowed(container.Tag) {
+		logger.Debugf("Skipping container: %s/%s/%s (tag %s not allowed by namespace config)", workload.Namespace, workload.Name, container.Name, container.Tag)
+		return true
+	}
+	repository := registry.ParseImage(container.Image).Repository
+	injected := workload.PodContainers > 1 || config.IsSidecarInjected(workload.PodAnnotations, workload.PodLabels)
+	if w.config.IsSidecar(container.Name, repository, injected) {
+		logger.Debugf("Skipping container: %s/%s/%s (injected sidecar)", workload.Namespace, workload.Name, container.Name)
+		return true
+	}
+	if !w.config.ImageFilter.Allows(repository) || !nsConfig.IsImageAllowed(repository) {
+		logger.Debugf("Skipping container: %s/%s/%s (image %s filtered)", workload.Namespace, workload.Name, container.Name, repository)
+		return true
+	}
+
+	stats.scanned(w.routedURLs(nsConfig, source))
+	stateKey := state.Key(workload.Namespace, string(workload.Type), workload.Name, container.Name)
+	status := stats.checkedContainer(workload, container)
+	defer func() {
+		if status.Status == "" && ok {
+			status.Status = ContainerUpToDate
+		} else if status.Status == "" {
+			status.Status = ContainerFailed
+			status.Reason = w.store.Container(stateKey).LastError
+		}
+	}()
+
+	// Without a running digest, a repo:tag@digest image is compared by its pinned digest
+	if container.CurrentDigest == "" {
+		container.CurrentDigest = registry.ParseImage(container.Image).Digest
+	}
+	status.CurrentDigest = container.CurrentDigest
+
+	logger.Debugf("Checking container: %s/%s/%s (%s)", workload.Namespace, workload.Name, container.Name, workload.Type)
+	logger.Debugf("  Image: %s", container.Image)
+	logger.Debugf("  Current Digest: %s", container.CurrentDigest)
+	if len(container.RunningDigests) > 1 {
+		logger.Warnf("Replicas of %s/%s/%s run different digests: %s", workload.Namespace, workload.Name, container.Name, formatDigestCounts(container.RunningDigests))
+	}
+
+	// Space out the checks of a container that keeps failing
+	containerState := w.store.Container(stateKey)
+	if until := w.failureBackoff(containerState); !until.IsZero() {
+		logger.Infof("Skipping container: %s/%s/%s (%d consecutive failures, retrying after %s)", workload.Namespace, workload.Name, container.Name, containerState.Failures, until.Format(time.RFC3339))
+		status.Status, status.Reason = ContainerSkipped, fmt.Sprintf("backing off after %d consecutive failures: %s", containerState.Failures, containerState.LastError)
+		stats.incompleteCheck(workload)
+		return false
+	}
+
+	// Let plugins skip the container
+	if reason, vetoed := w.checkPlugins(ctx, workload, container); vetoed {
+		logger.Infof("Skipping container: %s/%s/%s (%s)", workload.Namespace, workload.Name, container.Name, reason)
+		status.Status, status.Reason = ContainerSkipped, reason
+		return true
+	}
+
+	// Get registry credentials from imagePullSecrets or the configured credentials
+	if len(workload.ImagePullSecrets) > 0 {
+		logger.Debugf("  ImagePullSecrets found: \x1b[96m%v\x1b[0m", workload.ImagePullSecrets)
+	}
+	credentials := w.containerCredentials(ctx, workload, container)
+
+	// Report newer tags of pinned images
+	if w.config.TagAdvisory {
+		w.adviseNewerTags(ctx, nsConfig, source, stateKey, container, credentials)
+	}
+	if isDigestPinned(container.Image) {
+		logger.Debugf("Skipping update: %s/%s/%s (pinned by digest)", workload.Namespace, workload.Name, container.Name)
+		status.Status, status.Reason = ContainerSkipped, "pinned by digest"
+		return true
+	}
+
+	// In tag mode the newest version tag is the update candidate
+	mode := w.updateMode(workload)
+	checkImage := container.Image
+	if mode == config.UpdateModeTag && !container.SkewOnly {
+		newImage, err := w.newestTag(ctx, container, credentials)
+		if errors.Is(err, registry.ErrRateLimited) {
+			w.deferRateLimited(workload, container, nsConfig, source, status, err)
+			stats.incompleteCheck(workload)
+			return false
+		}
+		if errors.Is(err, registry.ErrCircuitOpen) {
+			logger.Debugf("Skipping container: %s/%s/%s (%v)", workload.Namespace, workload.Name, container.Name, err)
+			status.Status, status.Reason = ContainerSkipped, "registry unavailable"
+			stats.incompleteCheck(workload)
+			return true
+		}
+		if err != nil {
+			logger.Errorf("Failed to list tags for %s/%s/%s: %v", workload.Namespace, workload.Name, container.Name, err)
+			w.addResult(nsConfig, source, container.Image, false, err)
+			w.store.RecordFailure(stateKey, err)
+			stats.failed()
+			return false
+		}
+		if newImage == "" {
+			logger.Debugf("No update needed: %s/%s/%s (no newer version tag)", workload.Namespace, workload.Name, container.Name)
+			w.store.ResetFailures(stateKey)
+			return true
+		}
+		checkImage = newImage
+	}
+
+	// Check for updates
+	hasUpdate, newDigest, err := w.imageChecker.CheckForUpdate(ctx, checkImage, credentials)
+	if errors.Is(err, registry.ErrRateLimited) {
+		w.deferRateLimited(workload, container, nsConfig, source, status, err)
+		stats.incompleteCheck(workload)
+		return false
+	}
+	if errors.Is(err, registry.ErrCircuitOpen) {
+		// Logged once when the circuit opened
+		logger.Debugf("Skipping container: %s/%s/%s (%v)", workload.Namespace, workload.Name, container.Name, err)
+		status.Status, status.Reason = ContainerSkipped, "registry unavailable"
+		stats.incompleteCheck(workload)
+		return true
+	}
+	if err != nil {
+		logger.Errorf("Failed to check image update for %s/%s/%s: %v", workload.Namespace, workload.Name, container.Name, err)
+		w.addResult(nsConfig, source, container.Image, false, err)
+		w.store.RecordFailure(stateKey, err)
+		stats.failed()
+		return false
+	}
+
+	logger.Debugf("  Remote Digest: %s", newDigest)
+	w.store.RecordCheck(stateKey, newDigest)
+	status.RemoteDigest = newDigest
+
+	// Never re-apply a digest that was rolled back
+	if w.store.Container(stateKey).SkippedDigest == newDigest {
+		logger.Debugf("Skipping update: %s/%s/%s (digest %s was rolled back)", workload.Namespace, workload.Name, container.Name, shortDigest(newDigest))
+		status.Status, status.Reason = ContainerSkipped, "digest was rolled back"
+		return true
+	}
+
+	// Later promotion stages get the digest validated in the stage before, not the newest one
+	stage, stageIndex := w.promotionStage(workload)
+	promotion := promotionImage(checkImage)
+	promoted := ""
+	if stageIndex > 0 {
+		var reason string
+		promoted, reason = w.promotedDigest(promotion, container.CurrentDigest, stageIndex)
+		if promoted != "" {
+			newDigest = promoted
+		} else if container.CurrentDigest != newDigest && blocked == "" {
+			blocked = reason
+		}
+	}
+
+	// If we have current digest, use it for comparison
+	if container.CurrentDigest != "" {
+		if container.CurrentDigest == newDigest {
+			logger.Debugf("No update needed: %s/%s/%s (digest matches)", workload.Namespace, workload.Name, container.Name)
+			w.store.ResetFailures(stateKey)
+			if stage != "" {
+				w.store.RecordPromotion(promotion, newDigest, stage)
 			}
+			return w.checkDigestSkew(ctx, workload, container, nsConfig, cfg, monitorOnly, source, newDigest, credentials, status)
+		}
+		hasUpdate = true
+	}
+
+	if !hasUpdate {
+		logger.Debugf("No update needed: %s/%s/%s", workload.Namespace, workload.Name, container.Name)
+		return true
+	}
+	if container.SkewOnly {
+		logger.Debugf("Not updating %s/%s/%s (imagePullPolicy %s, only checked for digest skew)", workload.Namespace, workload.Name, container.Name, container.ImagePullPolicy)
+		return true
+	}
+
+	// Log new image found (like watchtower)
+	imageInfo := registry.ParseImage(checkImage)
+	if mode == config.UpdateModeRestart && imageInfo.Digest == "" && workload.Replicas == 0 && stageIndex <= 0 {
+		// There are no pods to restart, the tag is pulled again at the next scale-up
+		logger.Debugf("No update needed: %s/%s/%s (scaled to zero)", workload.Namespace, workload.Name, container.Name)
+		return true
+	}
+	logger.Infof("Found new %s:%s image (%s)", imageInfo.Repository, imageInfo.Tag, shortDigest(newDigest))
+	w.publish(Event{Type: EventUpdateAvailable, Namespace: workload.Namespace, Kind: string(workload.Type), Workload: workload.Name, Container: container.Name, Image: checkImage, Digest: newDigest})
+
+	// The image string written to the workload
+	newImage := pinnedImage(container.Image, newDigest)
+	image := container.Image
+	switch {
+	case mode == config.UpdateModeTag:
+		newImage = checkImage
+		image = fmt.Sprintf("%s → %s", container.Image, imageInfo.Tag)
+	case mode == config.UpdateModeRestart && imageInfo.Digest == "":
+		newImage = container.Image
+	case mode == config.UpdateModeRestart:
+		logger.Debugf("  Image is pinned by digest, updating the pin instead of restarting")
+	}
+	if stageIndex > 0 {
+		// The tag may have moved on since the previous stage validated the digest
+		newImage = pinnedImage(checkImage, newDigest)
+	}
+
+	// Describe the new image in notifications
+	metadata := w.imageMetadata(ctx, imageInfo.Repository, newDigest, credentials)
+	var currentMetadata *registry.ImageMetadata
+	if container.CurrentDigest != "" {
+		currentMetadata = w.imageMetadata(ctx, imageInfo.Repository, container.CurrentDigest, credentials)
+	}
+	if age := imageAge(currentMetadata, metadata); age != "" {
+		logger.Infof("  Running image is %s", age)
+	}
+	label := fmt.Sprintf("%s, %s", describeImage(image, currentMetadata, metadata), describeDigests(container.CurrentDigest, newDigest))
+	if url := releaseNotesURL(workload, container, newDigest, metadata); url != "" {
+		label = fmt.Sprintf("%s, release notes: %s", label, url)
+	}
+	if workload.HelmRelease != "" && w.config.HelmWorkloads == config.HelmWorkloadsWarn {
+		label = fmt.Sprintf("%s, reverted by the next helm upgrade of release %s", label, workload.HelmRelease)
+	}
+	if promoted != "" {
+		label = fmt.Sprintf("%s, validated in %s", label, w.config.PromotionStages[stageIndex-1])
+	}
+	if workload.Suspended {
+		label = fmt.Sprintf("%s, CronJob suspended: the new image does not run until it is resumed", label)
+	}
+
+	return w.applyUpdate(ctx, updateRequest{
+		workload: workload, container: container, nsConfig: nsConfig, cfg: cfg,
+		monitorOnly: monitorOnly, noWait: noWait, blocked: blocked, stats: stats, status: status,
+		source: source, stateKey: stateKey, newImage: newImage, newDigest: newDigest, label: label,
+		metadata: metadata, credentials: credentials, stage: stage, promotion: promotion,
+	})
+}
+
+// updateRequest is an available update of a container, applied by applyUpdate
+type updateRequest struct {
+	workload    k8s.WorkloadInfo
+	container   k8s.ContainerInfo
+	nsConfig    *config.NamespaceConfig
+	cfg         *config.Config
+	monitorOnly bool
+	noWait      bool
+	blocked     string // Why the update is deferred, empty if it is not
+	stats       *cycleStats
+	status      *ContainerStatus
+	source      notifier.Source
+	stateKey    string
+	newImage    string // The image string written to the workload
+	newDigest   string
+	label       string // Describes the update in notifications
+	metadata    *registry.ImageMetadata
+	credentials *registry.RegistryCredentials
+	stage       string // Promotion stage of the workload, empty without promotion
+	promotion   string // Image tracked by the promotion, see promotionImage
+}
 
-			// Log new image found (like watchtower)
-			imageInfo := registry.ParseImage(container.Image)
-			logger.Infof("Found new %s:%s image (%s)", imageInfo.Repository, imageInfo.Tag, newDigest[:12])
-
-			// Perform update
-			if w.config.DryRun {
-				logger.Infof("[DRY-RUN] Would update %s/%s/%s (%s)", workload.Namespace, workload.Name, container.Name, workload.Type)
-				updatedCount++
-				if w.notifier != nil {
-					w.notifier.AddResult(container.Image, true, nil)
-				}
-			} else {
-				if err := w.updateContainer(ctx, workload, container, newDigest); err != nil {
-					logger.Errorf("Update failed: %v", err)
-					if w.notifier != nil {
-						w.notifier.AddResult(container.Image, false, err)
-					}
-					failedCount++
-					continue
-				}
-
-				updatedCount++
-
-				if w.notifier != nil {
-					w.notifier.AddResult(container.Image, true, nil)
-				}
+// applyUpdate runs the gates of an available update (dry-run, schedule, replicas, deferrals, drain and
+// PodDisruptionBudgets), then applies it in a rollout slot
+// Returns false if the update failed or was deferred.
+func (w *Watcher) applyUpdate(ctx context.Context, u updateRequest) bool {
+	if u.cfg.DryRun {
+		logger.Infof("[DRY-RUN] Would update %s/%s/%s (%s)", u.workload.Namespace, u.workload.Name, u.container.Name, u.workload.Type)
+		u.stats.addPending(u.workload, u.container, u.newDigest, "dry-run")
+		u.stats.updated()
+		w.addDetectedOnce(u.nsConfig, u.source, u.stateKey, u.label, u.newDigest)
+	} else if u.monitorOnly {
+		logger.Infof("[MONITOR-ONLY] Outside update schedule, not updating %s/%s/%s (%s)", u.workload.Namespace, u.workload.Name, u.container.Name, u.workload.Type)
+		u.stats.addPending(u.workload, u.container, u.newDigest, "monitor-only: outside update schedule")
+		w.addDetectedOnce(u.nsConfig, u.source, u.stateKey, u.label, u.newDigest)
+	} else if reason, unsafe := w.checkMinReplicas(u.workload); unsafe {
+		logger.Infof("Not updating %s/%s/%s (%s): %s", u.workload.Namespace, u.workload.Name, u.container.Name, u.workload.Type, reason)
+		u.stats.addPending(u.workload, u.container, u.newDigest, reason)
+		w.addDetectedOnce(u.nsConfig, u.source, u.stateKey, u.label, u.newDigest)
+	} else if u.blocked != "" {
+		logger.Warnf("Deferring update of %s/%s/%s (%s): %s", u.workload.Namespace, u.workload.Name, u.container.Name, u.workload.Type, u.blocked)
+		u.stats.addPending(u.workload, u.container, u.newDigest, u.blocked)
+		w.addDeferred(u.nsConfig, u.source, u.label, u.blocked)
+		return false
+	} else if reason, deferred := w.shouldDefer(ctx, u.workload, u.container, u.newImage, u.newDigest, u.metadata, u.credentials); deferred {
+		logger.Infof("Deferring update of %s/%s/%s (%s): %s", u.workload.Namespace, u.workload.Name, u.container.Name, u.workload.Type, reason)
+		u.stats.addPending(u.workload, u.container, u.newDigest, reason)
+		w.addDeferred(u.nsConfig, u.source, u.label, reason)
+		return false
+	} else if w.draining.Load() {
+		logger.Infof("Shutting down, not updating %s/%s/%s (%s)", u.workload.Namespace, u.workload.Name, u.container.Name, u.workload.Type)
+		u.stats.addPending(u.workload, u.container, u.newDigest, "shutting down")
+		w.addDeferred(u.nsConfig, u.source, u.label, "shutting down")
+		return false
+	} else if reason := w.blockingPDBs(ctx, u.workload); reason != "" {
+		// Checked before taking a rollout slot, which an exhausted budget would otherwise hold
+		logger.Infof("Deferring update of %s/%s/%s (%s): %s", u.workload.Namespace, u.workload.Name, u.container.Name, u.workload.Type, reason)
+		u.stats.addPending(u.workload, u.container, u.newDigest, reason)
+		w.addDeferred(u.nsConfig, u.source, u.label, reason)
+		return false
+	} else {
+		// Wait for a free rollout slot
+		if err := w.rollouts.acquire(ctx, u.workload.Namespace); err != nil {
+			w.addResult(u.nsConfig, u.source, u.label, false, err)
+			u.stats.failed()
+			return false
+		}
+		var snapshots []string
+		var rollout time.Duration
+		err := func() error {
+			defer w.rollouts.release(u.workload.Namespace)
+			var err error
+			if snapshots, err = w.snapshotVolumes(ctx, u.workload, u.stats); err != nil {
+				return fmt.Errorf("volume snapshot failed, update aborted: %w", err)
+			}
+			started := time.Now()
+			defer func() { rollout = time.Since(started) }()
+			return w.updateContainer(ctx, u.cfg, u.workload, u.container, u.newImage, u.newDigest, u.noWait)
+		}()
+		w.recordUpdate(ctx, u.stateKey, u.workload, u.container, u.newImage, u.newDigest, u.metadata, snapshots, err)
+		w.callPostUpdateWebhooks(ctx, u.workload, u.container, u.newImage, u.newDigest, err)
+		w.callPostUpdatePlugins(ctx, u.workload, u.container, u.newImage, u.newDigest, err)
+		if err != nil {
+			logger.Errorf("Update failed: %v", err)
+			if u.stage != "" {
+				w.store.FailPromotion(u.newDigest, fmt.Sprintf("update of %s/%s failed", u.workload.Namespace, u.workload.Name))
 			}
+			u.stats.addPending(u.workload, u.container, u.newDigest, err.Error())
+			w.addResult(u.nsConfig, u.source, u.label, false, err)
+			u.stats.failed()
+			return false
+		}
+
+		u.stats.updated()
+		u.status.Status = ContainerUpdated
+		if u.stage != "" {
+			w.store.RecordPromotion(u.promotion, u.newDigest, u.stage)
 		}
+		w.publish(Event{Type: EventUpdateApplied, Namespace: u.workload.Namespace, Kind: string(u.workload.Type), Workload: u.workload.Name, Container: u.container.Name, Image: u.newImage, Digest: u.newDigest})
+		w.addResult(u.nsConfig, u.source, fmt.Sprintf("%s, rolled out in %s", u.label, rollout.Round(time.Second)), true, nil)
 	}
 
-	// Session done (like watchtower)
-	if w.config.DryRun {
-		logger.Infof("[DRY-RUN] Session done Scanned=%d Detected=%d Failed=%d", scannedCount, updatedCount, failedCount)
+	return true
+}
+
+// loadNamespaceConfigs reads the namespace ConfigMaps of all namespaces with workloads
+func (w *Watcher) loadNamespaceConfigs(ctx context.Context, workloads []k8s.WorkloadInfo) map[string]*config.NamespaceConfig {
+	nsConfigs := make(map[string]*config.NamespaceConfig)
+	seen := make(map[string]bool)
+
+	for _, workload := range workloads {
+		if seen[workload.Namespace] {
+			continue
+		}
+		seen[workload.Namespace] = true
+
+		data, err := w.k8sClient.GetConfigMapData(ctx, workload.Namespace, w.config.NamespaceConfigName)
+		if err != nil {
+			logger.Debugf("Failed to get namespace config for %s: %v", workload.Namespace, err)
+			continue
+		}
+		if data == nil {
+			continue
+		}
+
+		nsConfig, err := config.ParseNamespaceConfig(data)
+		if err != nil {
+			logger.Warnf("Ignoring namespace config %s/%s: %v", workload.Namespace, w.config.NamespaceConfigName, err)
+			continue
+		}
+		if nsConfig.NotificationURL != "" && !w.config.IsWorkloadNotifyURLAllowed(nsConfig.NotificationURL) {
+			// Namespace owners choose these URLs like workload owners do; the URL may hold a token and is not logged
+			logger.Warnf("Ignoring NOTIFICATION_URL of namespace config %s/%s: not allowed by WORKLOAD_NOTIFY_ALLOW", workload.Namespace, w.config.NamespaceConfigName)
+			nsConfig.NotificationURL = ""
+		}
+
+		logger.Debugf("Loaded namespace config: %s/%s", workload.Namespace, w.config.NamespaceConfigName)
+		nsConfigs[workload.Namespace] = nsConfig
+	}
+
+	return nsConfigs
+}
+
+// notifierOptions returns the notifier options of the configuration, client provides the kubernetes:// backend
+func notifierOptions(cfg *config.Config, client *k8s.Client) notifier.Options {
+	return notifier.Options{
+		ClusterName: cfg.NotificationCluster,
+		DryRun:      cfg.DryRun,
+		Retries:     cfg.NotificationRetries,
+		RetryDelay:  cfg.NotificationRetryDelay,
+		RateLimit:   cfg.NotificationRateLimit,
+		Title:       cfg.NotificationTitle,
+		Footer:      cfg.NotificationFooter,
+		Context:     footerContext(cfg, client),
+		Emoji:       cfg.NotificationEmoji,
+		NoEmoji:     cfg.NotificationNoEmoji,
+		Backends:    map[string]notifier.BackendFactory{notifier.KubernetesScheme: kubeEventBackendFactory(client)},
+		Failover:    cfg.NotificationFailover,
+	}
+}
+
+// addResult records an update result in the global and namespace notifiers
+func (w *Watcher) addResult(nsConfig *config.NamespaceConfig, source notifier.Source, image string, success bool, err error) {
+	if success {
+		metrics.ObserveUpdate(source.Namespace, metrics.ResultUpdated)
 	} else {
-		logger.Infof("Session done Scanned=%d Updated=%d Failed=%d", scannedCount, updatedCount, failedCount)
+		metrics.ObserveUpdate(source.Namespace, metrics.ResultFailed)
+		w.publish(Event{Type: EventUpdateFailed, Namespace: source.Namespace, Workload: source.Workload, Container: source.Container, Image: image, Message: err.Error()})
 	}
 
-	// Send summary notification
 	if w.notifier != nil {
-		w.notifier.SendSummary(scannedCount)
+		w.notifier.AddResult(source, image, success, err)
 	}
+	for _, n := range w.routedNotifiers(nsConfig, source) {
+		n.AddResult(source, image, success, err)
+	}
+}
 
-	return nil
+// addDetected records a detected but not applied update in the global and namespace notifiers
+func (w *Watcher) addDetected(nsConfig *config.NamespaceConfig, source notifier.Source, image string) {
+	metrics.ObserveUpdate(source.Namespace, metrics.ResultDetected)
+
+	if w.notifier != nil {
+		w.notifier.AddDetected(source, image)
+	}
+	for _, n := range w.routedNotifiers(nsConfig, source) {
+		n.AddDetected(source, image)
+	}
 }
 
-// updateContainer updates a container in a workload
-func (w *Watcher) updateContainer(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newDigest string) error {
-	// Build new image name
-	imageInfo := registry.ParseImage(container.Image)
-	newImage := fmt.Sprintf("%s:%s@%s", imageInfo.Repository, imageInfo.Tag, newDigest)
+// deferRateLimited reports a container whose registry is rate limiting as deferred instead of failed
+func (w *Watcher) deferRateLimited(workload k8s.WorkloadInfo, container k8s.ContainerInfo, nsConfig *config.NamespaceConfig, source notifier.Source, status *ContainerStatus, err error) {
+	logger.Infof("Deferring check of %s/%s/%s (%s): %v", workload.Namespace, workload.Name, container.Name, workload.Type, err)
+	status.Status, status.Reason = ContainerSkipped, "rate limited by registry"
+	w.addDeferred(nsConfig, source, container.Image, "rate limited")
+}
+
+// addDeferred records a held back update in the global and namespace notifiers
+func (w *Watcher) addDeferred(nsConfig *config.NamespaceConfig, source notifier.Source, image, reason string) {
+	metrics.ObserveUpdate(source.Namespace, metrics.ResultDeferred)
+	w.publish(Event{Type: EventUpdateDeferred, Namespace: source.Namespace, Workload: source.Workload, Container: source.Container, Image: image, Message: reason})
+
+	if w.notifier != nil {
+		w.notifier.AddDeferred(source, image, reason)
+	}
+	for _, n := range w.routedNotifiers(nsConfig, source) {
+		n.AddDeferred(source, image, reason)
+	}
+}
+
+// addAdvisory records the newer tags of a pinned image in the global and namespace notifiers
+func (w *Watcher) addAdvisory(nsConfig *config.NamespaceConfig, source notifier.Source, image string, tags []string) {
+	if w.notifier != nil {
+		w.notifier.AddAdvisory(source, image, tags)
+	}
+	for _, n := range w.routedNotifiers(nsConfig, source) {
+		n.AddAdvisory(source, image, tags)
+	}
+}
+
+// addDetectedOnce records a detected update unless the same digest was already reported
+func (w *Watcher) addDetectedOnce(nsConfig *config.NamespaceConfig, source notifier.Source, stateKey, image, digest string) {
+	if w.store.Container(stateKey).NotifiedDigest == digest {
+		logger.Debugf("  Update %s already reported, not notifying again", shortDigest(digest))
+		return
+	}
+	w.addDetected(nsConfig, source, image)
+	w.store.RecordNotified(stateKey, digest)
+}
+
+// recordUpdate records an update attempt in the state store
+func (w *Watcher) recordUpdate(ctx context.Context, stateKey string, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage, newDigest string, metadata *registry.ImageMetadata, snapshots []string, err error) {
+	record := state.UpdateRecord{
+		Time:      time.Now(),
+		Namespace: workload.Namespace,
+		Kind:      string(workload.Type),
+		Name:      workload.Name,
+		Container: container.Name,
+		OldImage:  container.Image,
+		NewImage:  newImage,
+		OldDigest: container.CurrentDigest,
+		NewDigest: newDigest,
+		Success:   err == nil,
+		Snapshots: snapshots,
+		Cycle:     w.cycle,
+
+		HelmRelease: workload.HelmRelease,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if metadata != nil {
+		record.Version = metadata.Version
+		record.Revision = metadata.Revision
+		record.Source = metadata.Source
+		if !metadata.Created.IsZero() {
+			record.Created = metadata.Created.Format(time.RFC3339)
+		}
+	}
+	w.store.RecordUpdate(stateKey, record)
+	w.audit.Export(ctx, record)
+}
+
+// cycleID identifies a check cycle or manual update by its start time
+func cycleID(start time.Time) string {
+	return start.UTC().Format(time.RFC3339Nano)
+}
+
+// imageMetadata fetches the OCI metadata of the new image, nil if unavailable
+func (w *Watcher) imageMetadata(ctx context.Context, repository, digest string, credentials *registry.RegistryCredentials) *registry.ImageMetadata {
+	metadata, err := w.imageChecker.GetMetadata(ctx, repository, digest, credentials)
+	if err != nil {
+		logger.Debugf("  Failed to get image metadata: %v", err)
+		return nil
+	}
+	return metadata
+}
+
+// describeImage formats an image with the metadata of its new version for notifications,
+// e.g. "nginx:latest → 1.27.1 (built 2024-06-02, github.com/nginx/nginx), running image is 43 days older"
+func describeImage(image string, current, metadata *registry.ImageMetadata) string {
+	if description := metadata.String(); description != "" {
+		image = fmt.Sprintf("%s → %s", image, description)
+	}
+	if age := imageAge(current, metadata); age != "" {
+		image = fmt.Sprintf("%s, running image is %s", image, age)
+	}
+	return image
+}
+
+// describeDigests describes the digest change of an update with shortened digests, e.g. digest 3f4e1a2b9c0d → 7d2c9e8f1a3b
+func describeDigests(current, remote string) string {
+	if current == "" {
+		return "digest → " + shortDigest(remote)
+	}
+	return fmt.Sprintf("digest %s → %s", shortDigest(current), shortDigest(remote))
+}
+
+// shortDigest shortens a digest to its first 12 hex characters, like docker image IDs
+func shortDigest(digest string) string {
+	if _, hex, found := strings.Cut(digest, ":"); found {
+		digest = hex
+	}
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+	return digest
+}
+
+// imageAge describes how much older the running image is than the remote one,
+// empty if either creation time is unknown
+func imageAge(current, remote *registry.ImageMetadata) string {
+	if current == nil || remote == nil || current.Created.IsZero() || remote.Created.IsZero() {
+		return ""
+	}
+
+	age := remote.Created.Sub(current.Created)
+	switch {
+	case age < 0:
+		return "newer than remote"
+	case age < time.Hour:
+		return "less than an hour older than remote"
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%d hours older than remote", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%d days older than remote", int(age.Hours()/24))
+	}
+}
+
+// formatDigestCounts lists short digests with their pod counts, most common first
+func formatDigestCounts(counts map[string]int) string {
+	digests := make([]string, 0, len(counts))
+	for digest := range counts {
+		digests = append(digests, digest)
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		if counts[digests[i]] != counts[digests[j]] {
+			return counts[digests[i]] > counts[digests[j]]
+		}
+		return digests[i] < digests[j]
+	})
+
+	parts := make([]string, len(digests))
+	for i, digest := range digests {
+		parts[i] = fmt.Sprintf("%s (%d pods)", shortDigest(digest), counts[digest])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// pinnedImage builds the repo:tag@digest image string
+func pinnedImage(image, digest string) string {
+	imageInfo := registry.ParseImage(image)
+	return fmt.Sprintf("%s:%s@%s", imageInfo.Repository, imageInfo.Tag, digest)
+}
 
+// updateContainer updates a container in a workload
+// With noWait it returns once the new image is applied, skipping the rollout wait and the post-rollout steps.
+func (w *Watcher) updateContainer(ctx context.Context, cfg *config.Config, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage, newDigest string, noWait bool) error {
 	logger.Debugf("Updating image: %s -> %s", container.Image, newImage)
 
+	// Ask external systems for approval
+	if err := w.callPreUpdateWebhooks(ctx, workload, container, newImage, newDigest); err != nil {
+		return err
+	}
+	if err := w.runPlugins(ctx, w.newUpdateEvent(EventPreUpdate, workload, container, newImage, newDigest)); err != nil {
+		return fmt.Errorf("vetoed by %w", err)
+	}
+
+	// Run pre-update Job (e.g. migrations) with the new image
+	if err := w.runPreUpdateJob(ctx, workload, container, newImage); err != nil {
+		return fmt.Errorf("pre-update job failed, update aborted: %w", err)
+	}
+
+	// Run pre-update hook in the old pods
+	if err := w.runHook(ctx, workload, container, annotationPreUpdateCommand); err != nil {
+		return fmt.Errorf("pre-update hook failed, update aborted: %w", err)
+	}
+
+	// Remember the nodes running the old image for cleanup
+	nodes := w.workloadNodes(ctx, workload)
+	w.logCachedNodes(ctx, workload, container, newDigest, nodes)
+
 	// Update workload
-	err := w.k8sClient.UpdateWorkloadImage(ctx, workload.Type, workload.Namespace, workload.Name, container.Name, newImage)
+	err := w.updater.UpdateWorkloadImage(ctx, workload.Type, workload.Namespace, workload.Name, container.Name, newImage, container.CurrentDigest)
 	if err != nil {
 		return fmt.Errorf("failed to update %s: %w", workload.Type, err)
 	}
 
+	w.publish(Event{Type: EventRolloutStarted, Namespace: workload.Namespace, Kind: string(workload.Type), Workload: workload.Name, Container: container.Name, Image: newImage, Digest: newDigest})
+	if noWait {
+		logger.Infof("Rolling update started, not waiting for it: %s/%s (%s)", workload.Namespace, workload.Name, workload.Type)
+		return nil
+	}
+
 	// Wait for rollout to complete
 	logger.Infof("Waiting for rolling update to complete: %s/%s (%s)", workload.Namespace, workload.Name, workload.Type)
-	err = w.k8sClient.WaitForRollout(ctx, workload.Type, workload.Namespace, workload.Name, 5*time.Minute)
+	err = w.updater.WaitForRollout(ctx, workload.Type, workload.Namespace, workload.Name, 5*time.Minute)
 	if err != nil {
+		if reason := w.blockingPDBs(ctx, workload); reason != "" {
+			return fmt.Errorf("rollout failed: %w (%s)", err, reason)
+		}
 		return fmt.Errorf("rollout failed: %w", err)
 	}
+	w.publish(Event{Type: EventRolloutCompleted, Namespace: workload.Namespace, Kind: string(workload.Type), Workload: workload.Name, Container: container.Name, Image: newImage, Digest: newDigest})
+
+	// Verify the pods actually run the new digest
+	if err := w.verifyRunningDigest(ctx, workload, container, newDigest); err != nil {
+		return fmt.Errorf("digest verification failed: %w", err)
+	}
+
+	// Verify the new version, reverting on failure
+	if err := w.runSmokeTest(ctx, workload); err != nil {
+		if _, rollbackErr := w.revertContainer(ctx, workload, container, newImage, newDigest); rollbackErr != nil {
+			return fmt.Errorf("smoke test failed: %w (rollback failed: %v)", err, rollbackErr)
+		}
+		return fmt.Errorf("smoke test failed, rolled back: %w", err)
+	}
+
+	// Run post-update hook in the new pods
+	if err := w.runHook(ctx, workload, container, annotationPostUpdateCommand); err != nil {
+		return fmt.Errorf("post-update hook failed: %w", err)
+	}
+
+	// Remove the superseded image from the nodes
+	if w.config.Cleanup {
+		w.cleanupImage(ctx, workload, container, nodes)
+	}
+	w.pruneRevisions(ctx, workload)
 
 	logger.Infof("Update completed: %s/%s/%s (%s)", workload.Namespace, workload.Name, container.Name, workload.Type)
 	return nil
 }
 
+// containerCredentials gets the registry credentials for the image of a container
+func (w *Watcher) containerCredentials(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo) *registry.RegistryCredentials {
+	if w.config.RegistryAuth == config.RegistryAuthK8sChain {
+		return w.getKeychainCredentials(ctx, workload, container.Image)
+	}
+	// Matching credentials are used for public images too, e.g. for the authenticated Docker Hub rate limit
+	return w.getCredentialsForImage(ctx, workload.Namespace, w.pullSecrets(ctx, workload), container.Image)
+}
+
 // getCredentialsForImage gets the appropriate registry credentials for an image
 func (w *Watcher) getCredentialsForImage(ctx context.Context, namespace string, secretNames []string, image string) *registry.RegistryCredentials {
 	// Parse image to extract registry
@@ -203,22 +1108,119 @@ func (w *Watcher) getCredentialsForImage(ctx context.Context, namespace string,
 		}
 
 		// Find matching registry
-		for _, auth := range auths {
-			if matchesRegistry(imageRegistry, auth.Registry) {
-				logger.Debugf("  Found matching credentials for registry: %s", auth.Registry)
-				return &registry.RegistryCredentials{
-					Registry: auth.Registry,
-					Username: auth.Username,
-					Password: auth.Password,
-				}
-			}
+		if credentials := findCredentials(imageRegistry, auths); credentials != nil {
+			logger.Debugf("  Found matching credentials for registry: %s", credentials.Registry)
+			return credentials
 		}
 	}
 
+	// Fall back to the credentials of the config
+	if credentials := findCredentials(imageRegistry, w.staticCreds); credentials != nil {
+		logger.Debugf("  Using configured credentials for registry: %s", credentials.Registry)
+		return credentials
+	}
+
 	logger.Debugf("  No matching credentials found for registry: %s", imageRegistry)
 	return nil
 }
 
+// pullSecrets returns the imagePullSecrets of a workload followed by those of its service account
+func (w *Watcher) pullSecrets(ctx context.Context, workload k8s.WorkloadInfo) []string {
+	saSecrets, err := w.k8sClient.GetServiceAccountPullSecrets(ctx, workload.Namespace, workload.ServiceAccount)
+	if err != nil {
+		logger.Debugf("  Failed to get imagePullSecrets of service account of %s/%s: %v", workload.Namespace, workload.Name, err)
+		return workload.ImagePullSecrets
+	}
+
+	secrets := append([]string(nil), workload.ImagePullSecrets...)
+	for _, secret := range saSecrets {
+		if !contains(secrets, secret) {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// getKeychainCredentials resolves the credentials for an image through the k8schain keychain of the workload,
+// falling back to the credentials of the config
+func (w *Watcher) getKeychainCredentials(ctx context.Context, workload k8s.WorkloadInfo, image string) *registry.RegistryCredentials {
+	imageRegistry := extractRegistry(registry.ParseImage(image).Repository)
+
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		logger.Debugf("  Failed to parse image %s: %v", image, err)
+		return nil
+	}
+
+	keychain, err := w.k8sClient.Keychain(ctx, workload.Namespace, workload.ServiceAccount, workload.ImagePullSecrets)
+	if err != nil {
+		logger.Debugf("  Failed to create keychain for %s/%s: %v", workload.Namespace, workload.Name, err)
+	} else if auth, err := authn.Resolve(ctx, keychain, ref.Context()); err != nil {
+		logger.Debugf("  Failed to resolve credentials for %s: %v", imageRegistry, err)
+	} else if auth != authn.Anonymous {
+		logger.Debugf("  Found keychain credentials for registry: %s", imageRegistry)
+		return &registry.RegistryCredentials{Registry: imageRegistry, Authenticator: auth}
+	}
+
+	if credentials := findCredentials(imageRegistry, w.staticCreds); credentials != nil {
+		logger.Debugf("  Using configured credentials for registry: %s", credentials.Registry)
+		return credentials
+	}
+
+	logger.Debugf("  No matching credentials found for registry: %s", imageRegistry)
+	return nil
+}
+
+// findCredentials returns the auth entry of the image registry, or else the first entry matching it
+// through a wildcard, a missing port or a Docker Hub alias
+func findCredentials(imageRegistry string, auths []k8s.RegistryAuth) *registry.RegistryCredentials {
+	match := -1
+	for i, auth := range auths {
+		if normalizeRegistry(auth.Registry) == normalizeRegistry(imageRegistry) {
+			match = i
+			break
+		}
+		if match == -1 && matchesRegistry(imageRegistry, auth.Registry) {
+			match = i
+		}
+	}
+	if match == -1 {
+		return nil
+	}
+
+	auth := auths[match]
+	return &registry.RegistryCredentials{
+		Registry: auth.Registry,
+		Username: auth.Username,
+		Password: auth.Password,
+		Token:    auth.Token,
+	}
+}
+
+// loadStaticCredentials reads the REGISTRY_ROBOTS files and RegistryCredentialsFile, so rotated Secrets are picked up every check
+func (w *Watcher) loadStaticCredentials() {
+	if w.config.RegistryCredentialsFile == "" && len(w.config.RegistryRobots) == 0 {
+		return
+	}
+
+	// Robot accounts are configured per registry and take precedence
+	auths := w.loadRobotCredentials()
+	if w.config.RegistryCredentialsFile != "" {
+		data, err := os.ReadFile(w.config.RegistryCredentialsFile)
+		if err != nil {
+			logger.Warnf("Failed to read registry credentials: %v", err)
+			return
+		}
+		fileAuths, err := k8s.ParseDockerConfig(data)
+		if err != nil {
+			logger.Warnf("Failed to read registry credentials %s: %v", w.config.RegistryCredentialsFile, err)
+			return
+		}
+		auths = append(auths, fileAuths...)
+	}
+	w.staticCreds = auths
+}
+
 // extractRegistry extracts the registry host from a repository string
 func extractRegistry(repository string) string {
 	// Docker Hub images don't have registry prefix
@@ -237,6 +1239,8 @@ func extractRegistry(repository string) string {
 }
 
 // matchesRegistry checks if image registry matches secret registry
+// Secret registries may use wildcards per DNS label (*.dkr.ecr.eu-west-1.amazonaws.com) and omit the port
+// (registry.example.com also matches registry.example.com:5000).
 func matchesRegistry(imageRegistry, secretRegistry string) bool {
 	// Normalize registries
 	imageRegistry = normalizeRegistry(imageRegistry)
@@ -257,17 +1261,54 @@ func matchesRegistry(imageRegistry, secretRegistry string) bool {
 
 	imageIsDockerHub := contains(dockerHubRegistries, imageRegistry)
 	secretIsDockerHub := contains(dockerHubRegistries, secretRegistry)
+	if imageIsDockerHub || secretIsDockerHub {
+		return imageIsDockerHub && secretIsDockerHub
+	}
+
+	imageHost, imagePort := splitRegistryPort(imageRegistry)
+	secretHost, secretPort := splitRegistryPort(secretRegistry)
+	if secretPort != "" && secretPort != imagePort {
+		return false
+	}
+	return matchesHost(imageHost, secretHost)
+}
 
-	return imageIsDockerHub && secretIsDockerHub
+// matchesHost matches a host against a pattern whose "*" labels match exactly one DNS label
+func matchesHost(host, pattern string) bool {
+	if !strings.Contains(pattern, "*") {
+		return host == pattern
+	}
+
+	hostLabels := strings.Split(host, ".")
+	patternLabels := strings.Split(pattern, ".")
+	if len(hostLabels) != len(patternLabels) {
+		return false
+	}
+	for i, label := range patternLabels {
+		if matched, err := path.Match(label, hostLabels[i]); err != nil || !matched {
+			return false
+		}
+	}
+	return true
 }
 
-// normalizeRegistry normalizes a registry URL
+// splitRegistryPort splits the port off a registry host, dropping the default HTTPS port
+func splitRegistryPort(registry string) (string, string) {
+	host, port, found := strings.Cut(registry, ":")
+	if !found || port == "443" {
+		return host, ""
+	}
+	return host, port
+}
+
+// normalizeRegistry normalizes a registry URL to its lower case host and port
+// (https://index.docker.io/v1/ -> index.docker.io)
 func normalizeRegistry(registry string) string {
 	// Remove https:// or http:// prefix
 	registry = strings.TrimPrefix(registry, "https://")
 	registry = strings.TrimPrefix(registry, "http://")
-	// Remove trailing slash
-	registry = strings.TrimSuffix(registry, "/")
+	// Remove the path, e.g. /v1/ of Docker Hub entries
+	registry, _, _ = strings.Cut(registry, "/")
 	return strings.ToLower(registry)
 }
 
@@ -281,10 +1322,21 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-// Close closes the watcher
+// notifierCloseTimeout bounds the wait for queued notification retries when the watcher is closed
+const notifierCloseTimeout = 30 * time.Second
+
+// Close releases the watcher, giving queued notification retries a last chance to be delivered
 func (w *Watcher) Close() error {
-	if w.imageChecker != nil {
-		return w.imageChecker.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), notifierCloseTimeout)
+	defer cancel()
+
+	if closer, ok := w.notifier.(notifierCloser); ok {
+		closer.Close(ctx)
+	}
+	w.notifiersMu.Lock()
+	defer w.notifiersMu.Unlock()
+	for _, n := range w.nsNotifiers {
+		n.Close(ctx)
 	}
 	return nil
 }
@@ -0,0 +1,128 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/logger"
+)
+
+// Webhook annotations (set on the workload, used in addition to the global webhooks)
+const (
+	annotationPreUpdateWebhook  = "kube-watchtower.io/pre-update-webhook"
+	annotationPostUpdateWebhook = "kube-watchtower.io/post-update-webhook"
+)
+
+// Update event names
+const (
+	EventPreUpdate  = "pre-update"
+	EventPostUpdate = "post-update"
+)
+
+// UpdateEvent is the JSON payload describing an update
+type UpdateEvent struct {
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	Cluster   string    `json:"cluster"`
+	Namespace string    `json:"namespace"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Container string    `json:"container"`
+	OldImage  string    `json:"oldImage"`
+	NewImage  string    `json:"newImage"`
+	OldDigest string    `json:"oldDigest,omitempty"`
+	NewDigest string    `json:"newDigest"`
+	Success   *bool     `json:"success,omitempty"` // Set for post-update events
+	Error     string    `json:"error,omitempty"`
+}
+
+// newUpdateEvent builds an update event for a container
+func (w *Watcher) newUpdateEvent(event string, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage, newDigest string) UpdateEvent {
+	return UpdateEvent{
+		Event:     event,
+		Time:      time.Now(),
+		Cluster:   w.config.NotificationCluster,
+		Namespace: workload.Namespace,
+		Kind:      string(workload.Type),
+		Name:      workload.Name,
+		Container: container.Name,
+		OldImage:  container.Image,
+		NewImage:  newImage,
+		OldDigest: container.CurrentDigest,
+		NewDigest: newDigest,
+	}
+}
+
+// callPreUpdateWebhooks calls the global and workload pre-update webhooks
+// Any failure or non-2xx response vetoes the update
+func (w *Watcher) callPreUpdateWebhooks(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage, newDigest string) error {
+	event := w.newUpdateEvent(EventPreUpdate, workload, container, newImage, newDigest)
+	for _, url := range webhookURLs(w.config.PreUpdateWebhook, workload.Annotations[annotationPreUpdateWebhook]) {
+		if err := w.callWebhook(ctx, url, event); err != nil {
+			return fmt.Errorf("vetoed by pre-update webhook: %w", err)
+		}
+	}
+	return nil
+}
+
+// callPostUpdateWebhooks calls the global and workload post-update webhooks, failures are only logged
+func (w *Watcher) callPostUpdateWebhooks(ctx context.Context, workload k8s.WorkloadInfo, container k8s.ContainerInfo, newImage, newDigest string, updateErr error) {
+	event := w.newUpdateEvent(EventPostUpdate, workload, container, newImage, newDigest)
+	success := updateErr == nil
+	event.Success = &success
+	if updateErr != nil {
+		event.Error = updateErr.Error()
+	}
+
+	for _, url := range webhookURLs(w.config.PostUpdateWebhook, workload.Annotations[annotationPostUpdateWebhook]) {
+		if err := w.callWebhook(ctx, url, event); err != nil {
+			logger.Warnf("Post-update webhook failed for %s/%s: %v", workload.Namespace, workload.Name, err)
+		}
+	}
+}
+
+// callWebhook posts an event to url and checks for a 2xx response
+func (w *Watcher) callWebhook(ctx context.Context, url string, event UpdateEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.config.WebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned status %d: %s", url, resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// webhookURLs returns the non-empty webhook URLs
+func webhookURLs(urls ...string) []string {
+	var result []string
+	for _, url := range urls {
+		if url != "" {
+			result = append(result, url)
+		}
+	}
+	return result
+}
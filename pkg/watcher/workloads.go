@@ -0,0 +1,103 @@
+package watcher
+
+import (
+	"sort"
+	"time"
+
+	"github.com/qetesh/kube-watchtower/pkg/k8s"
+	"github.com/qetesh/kube-watchtower/pkg/state"
+)
+
+// Container statuses of the last check cycle
+const (
+	ContainerUpToDate = "up-to-date"
+	ContainerUpdated  = "updated"
+	ContainerPending  = "pending" // Update available but not applied, see Reason
+	ContainerFailed   = "failed"
+	ContainerSkipped  = "skipped" // Not checked, see Reason
+)
+
+// WorkloadStatus describes a monitored workload as of the last check cycle
+type WorkloadStatus struct {
+	Namespace  string            `json:"namespace"`
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name"`
+	Suspended  bool              `json:"suspended,omitempty"` // Suspended CronJob, its new image has not run yet
+	Containers []ContainerStatus `json:"containers"`
+}
+
+// ContainerStatus describes a checked container as of the last check cycle
+type ContainerStatus struct {
+	Name          string              `json:"name"`
+	Image         string              `json:"image"`
+	CurrentDigest string              `json:"currentDigest,omitempty"`
+	RemoteDigest  string              `json:"remoteDigest,omitempty"` // Last checked remote digest
+	LastChecked   time.Time           `json:"lastChecked"`
+	Status        string              `json:"status"`
+	Reason        string              `json:"reason,omitempty"`
+	LastUpdate    *state.UpdateRecord `json:"lastUpdate,omitempty"` // Most recent update attempt
+	StaleNodes    []string            `json:"staleNodes,omitempty"` // Nodes of a DaemonSet running an outdated digest
+}
+
+// Workloads returns the workloads checked in the last check cycle, nil before the first one
+func (w *Watcher) Workloads() []WorkloadStatus {
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+	return w.lastWorkloads
+}
+
+// checkedContainer is a container checked in a cycle
+type checkedContainer struct {
+	namespace string
+	kind      string
+	name      string
+	suspended bool
+	status    ContainerStatus
+}
+
+// checkedContainer registers a container checked in the cycle, the returned status is updated by its check
+func (s *cycleStats) checkedContainer(workload k8s.WorkloadInfo, container k8s.ContainerInfo) *ContainerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checked := &checkedContainer{
+		namespace: workload.Namespace,
+		kind:      string(workload.Type),
+		name:      workload.Name,
+		suspended: workload.Suspended,
+		status: ContainerStatus{
+			Name:        container.Name,
+			Image:       container.Image,
+			LastChecked: time.Now(),
+		},
+	}
+	s.containers[state.Key(workload.Namespace, string(workload.Type), workload.Name, container.Name)] = checked
+	return &checked.status
+}
+
+// workloadStatuses groups the checked containers by workload, with their last update attempts
+func (w *Watcher) workloadStatuses(stats *cycleStats) []WorkloadStatus {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	keys := make([]string, 0, len(stats.containers))
+	for key := range stats.containers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var workloads []WorkloadStatus
+	for _, key := range keys {
+		checked := stats.containers[key]
+		cs := checked.status
+		if record, ok := w.store.LastAttempt(checked.namespace, checked.kind, checked.name, cs.Name); ok {
+			cs.LastUpdate = &record
+		}
+
+		if n := len(workloads); n == 0 || workloads[n-1].Namespace != checked.namespace || workloads[n-1].Kind != checked.kind || workloads[n-1].Name != checked.name {
+			workloads = append(workloads, WorkloadStatus{Namespace: checked.namespace, Kind: checked.kind, Name: checked.name, Suspended: checked.suspended})
+		}
+		last := &workloads[len(workloads)-1]
+		last.Containers = append(last.Containers, cs)
+	}
+	return workloads
+}